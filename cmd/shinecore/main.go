@@ -0,0 +1,18 @@
+// ShineCore headless CLI.
+// Exposes install, sync and launch without the Wails UI, for build agents and test boxes.
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"shinecore/internal/cli"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := cli.Run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
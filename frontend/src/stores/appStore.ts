@@ -1,6 +1,7 @@
 import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
 import * as App from '@wailsjs/go/app/App'
+import { EventsOn } from '@wailsjs/runtime/runtime'
 
 export interface UpdateInfo {
   PrimaryAction: string
@@ -20,6 +21,36 @@ export interface UpdateStatus {
   download_bps?: number
 }
 
+export interface ManifestState {
+  status: 'live' | 'revalidated' | 'cached' | 'stale'
+  age_seconds: number
+  fetched_at: number
+}
+
+export interface ReleaseFailure {
+  version: string
+  reason: 'install' | 'crash' | 'manual'
+  error?: string
+  at: string
+}
+
+export interface ReleaseState {
+  installed: string
+  lkg: string
+  failed?: ReleaseFailure
+  rolled_back: boolean
+  can_rollback: boolean
+}
+
+export interface ServerProfile {
+  id: string
+  name: string
+  url: string
+  auth: string
+  channel: string
+  selected: boolean
+}
+
 export interface FeedArticle {
   id: string
   title: string
@@ -32,10 +63,15 @@ export const useAppStore = defineStore('app', () => {
   // State
   const currentChannel = ref<string>('')
   const allowedChannels = ref<string[]>([])
+  const serverProfiles = ref<ServerProfile[]>([])
   const gameVersion = ref<string | null>(null)
   const lastKnownGoodVersion = ref<string | null>(null)
+  const manifestState = ref<ManifestState | null>(null)
+  const releaseState = ref<ReleaseState | null>(null)
+  const isRollingBack = ref(false)
   const updateInfo = ref<UpdateInfo | null>(null)
   const updateRunning = ref(false)
+  const launcherRestarting = ref(false)
   const updateStatus = ref<UpdateStatus>({
     message: { id: 'update_status.checking_for_updates' },
     progress: 0
@@ -65,6 +101,21 @@ export const useAppStore = defineStore('app', () => {
   async function setChannel(channel: string) {
     await App.SetChannel(channel)
     currentChannel.value = channel
+    await fetchGameVersion()
+  }
+
+  async function fetchServerProfiles() {
+    try {
+      serverProfiles.value = await App.GetServerProfiles()
+    } catch (error) {
+      console.error('Failed to fetch server profiles:', error)
+    }
+  }
+
+  async function selectServerProfile(id: string) {
+    await App.SelectServerProfile(id)
+    await fetchServerProfiles()
+    await fetchInstallInfo()
   }
 
   async function fetchGameVersion() {
@@ -73,6 +124,7 @@ export const useAppStore = defineStore('app', () => {
       if (state?.dependencies?.game) {
         gameVersion.value = state.dependencies.game.version
       }
+      manifestState.value = (state?.manifest as ManifestState) ?? null
     } catch (error) {
       console.error('Failed to fetch game version:', error)
     }
@@ -84,6 +136,7 @@ export const useAppStore = defineStore('app', () => {
       if (state?.dependencies?.lkg) {
         lastKnownGoodVersion.value = state.dependencies.lkg.version
       }
+      releaseState.value = (state?.release as ReleaseState) ?? null
     } catch (error) {
       console.error('Failed to fetch LKG version:', error)
     }
@@ -95,6 +148,16 @@ export const useAppStore = defineStore('app', () => {
     await fetchLastKnownGoodVersion()
   }
 
+  async function rollback() {
+    isRollingBack.value = true
+    try {
+      await App.RollbackGame()
+      await fetchInstallInfo()
+    } finally {
+      isRollingBack.value = false
+    }
+  }
+
   async function checkForUpdates(force = false) {
     try {
       // CheckForUpdates returns a number status code
@@ -137,6 +200,37 @@ export const useAppStore = defineStore('app', () => {
     }
   }
 
+  // Скачивает новую сборку лаунчера; после проверки бэкенд перезапускает
+  // лаунчер, а при неудачном запуске новой сборки возвращает ошибку.
+  async function applyLauncherUpdate() {
+    updateRunning.value = true
+    canCancel.value = false
+    launcherRestarting.value = false
+    updateStatus.value = { message: { id: 'downloading' }, progress: 0 }
+    const stopProgress = EventsOn('launcher-update:progress', (data: any) => {
+      updateStatus.value = {
+        message: { id: 'downloading' },
+        progress: data?.progress ?? 0,
+        download_progress: data?.bytesDone,
+        download_total: data?.bytesTotal,
+        download_bps: data?.speed
+      }
+    })
+    const stopRestarting = EventsOn('launcher-update:restarting', () => {
+      updateRunning.value = false
+      launcherRestarting.value = true
+    })
+    try {
+      await App.ApplyLauncherUpdate()
+    } finally {
+      stopProgress()
+      stopRestarting()
+      updateRunning.value = false
+      launcherRestarting.value = false
+      canCancel.value = true
+    }
+  }
+
   async function cancelUpdates() {
     isCancellingUpdate.value = true
     cancellationStatus.value = { id: 'update_status.cancelling_updates' }
@@ -169,10 +263,15 @@ export const useAppStore = defineStore('app', () => {
     // State
     currentChannel,
     allowedChannels,
+    serverProfiles,
     gameVersion,
     lastKnownGoodVersion,
+    manifestState,
+    releaseState,
+    isRollingBack,
     updateInfo,
     updateRunning,
+    launcherRestarting,
     updateStatus,
     isCancellingUpdate,
     canCancel,
@@ -184,12 +283,16 @@ export const useAppStore = defineStore('app', () => {
     // Actions
     fetchChannels,
     setChannel,
+    fetchServerProfiles,
+    selectServerProfile,
     fetchGameVersion,
     fetchLastKnownGoodVersion,
     fetchInstallInfo,
+    rollback,
     checkForUpdates,
     checkForFreestandingLauncherUpdate,
     applyUpdates,
+    applyLauncherUpdate,
     cancelUpdates,
     fetchNewsFeed,
     uninstall,
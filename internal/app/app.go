@@ -7,21 +7,30 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 
+	"shinecore/internal/build"
 	"shinecore/internal/launcher"
 	"shinecore/internal/launcher/config"
 	"shinecore/internal/launcher/server"
 	"shinecore/internal/system"
 	"shinecore/internal/models/account"
+	"shinecore/internal/selfupdate"
 )
 
 // App handles the launcher backend logic.
 type App struct {
 	ctx      context.Context
 	launcher *launcher.Launcher
+	// updater — самообновление лаунчера; nil — недоступно.
+	updater *selfupdate.Updater
+
+	updateMu sync.Mutex
+	// launcherRelease — найденная CheckForUpdates сборка лаунчера.
+	launcherRelease *server.LauncherRelease
 }
 
 type DependencyVersion struct {
@@ -34,12 +43,24 @@ type Dependencies struct {
 }
 
 type State struct {
-	Channel      string        `json:"channel"`
-	Dependencies *Dependencies `json:"dependencies"`
+	Channel      string         `json:"channel"`
+	Dependencies *Dependencies  `json:"dependencies"`
+	Manifest     *ManifestState `json:"manifest"`
+	// Release — установленная версия сборки, последняя рабочая и сломанная.
+	Release *launcher.ReleaseInfo `json:"release"`
+}
+
+// ManifestState — актуальность манифеста: live, revalidated, cached или
+// stale (сервер недоступен, игра идёт на сохранённой сборке возрастом age_seconds).
+type ManifestState struct {
+	Status     string `json:"status"`
+	AgeSeconds int64  `json:"age_seconds"`
+	FetchedAt  int64  `json:"fetched_at"`
 }
 
 type LaunchParams struct {
 	PlayerName string `json:"playerName"`
+	InstanceID string `json:"instanceId"` // пусто — выбранная сборка
 }
 
 type MemorySettings struct {
@@ -48,9 +69,28 @@ type MemorySettings struct {
 	MaxMB     int `json:"maxMB"`
 }
 
+// JavaSettings — Java выбранной сборки и вся найденная Java.
+type JavaSettings struct {
+	Selected string            `json:"selected"` // пусто — выбирает лаунчер
+	Runtimes []JavaRuntimeInfo `json:"runtimes"`
+}
+
+type JavaRuntimeInfo struct {
+	Path    string `json:"path"`
+	Major   int    `json:"major"`
+	Version string `json:"version"`
+	Vendor  string `json:"vendor"`
+	Arch    string `json:"arch"`
+	Is64Bit bool   `json:"is64Bit"`
+	Source  string `json:"source"`
+}
+
 func New() *App {
+	// Без пути к бинарнику или каталога настроек самообновление отключено.
+	updater, _ := selfupdate.New(build.Version)
 	return &App{
 		launcher: &launcher.Launcher{},
+		updater:  updater,
 	}
 }
 
@@ -59,46 +99,198 @@ func (a *App) Startup(ctx context.Context) {
 	go func() {
 		timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		_, _ = a.launcher.RefreshFromServer(timeout)
+		_, _ = a.launcher.RefreshFromServer(timeout, "")
 	}()
 }
 
-func (a *App) DomReady(ctx context.Context) {}
+// DomReady: интерфейс загрузился, значит обновлённый лаунчер запустился.
+func (a *App) DomReady(ctx context.Context) {
+	if a.updater != nil {
+		a.updater.Confirm()
+	}
+}
 
-// Frontend stubs to keep the UI running without backend logic.
+// GetUserChannels — каналы, которые объявляет сервер выбранного профиля.
+// Без связи с сервером доступен только текущий канал.
 func (a *App) GetUserChannels() []string {
-	return []string{"stable"}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	channels, current, err := a.launcher.Channels(ctx)
+	if err != nil {
+		return []string{current}
+	}
+	return channels
 }
 
 func (a *App) GetState() *State {
-	cfg, _ := a.launcher.LoadConfig()
+	inst := a.selectedInstance()
 	game := ""
-	if cfg != nil {
-		game = cfg.GameVersion
-		if serverCfg, err := config.LoadServer(""); err == nil {
-			client := &server.Client{BaseURL: serverCfg.ServerBaseURL, Secret: serverCfg.ServerSecret}
-			if manifest, err := client.FetchManifest(context.Background()); err == nil {
-				if manifest.Version != "" {
-					game = manifest.Version
-				} else if manifest.Dependencies.GameVersion != "" {
-					game = manifest.Dependencies.GameVersion
-				}
+	var status *ManifestState
+	var manifest *server.Manifest
+	var release *launcher.ReleaseInfo
+	var lkg *DependencyVersion
+	if inst != nil {
+		game = inst.GameVersion
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		result, err := a.launcher.FetchManifest(ctx, inst.ID)
+		cancel()
+		if err == nil {
+			manifest = result.Manifest
+			if manifest.Version != "" {
+				game = manifest.Version
+			} else if manifest.Dependencies.GameVersion != "" {
+				game = manifest.Dependencies.GameVersion
+			}
+			status = &ManifestState{
+				Status:     string(result.Status),
+				AgeSeconds: int64(result.Age.Seconds()),
+				FetchedAt:  result.FetchedAt.Unix(),
+			}
+		}
+		if info, err := a.launcher.Release(inst.ID); err == nil {
+			release = info
+			if info.LKG != "" {
+				lkg = &DependencyVersion{Version: info.LKG}
+			}
+			// После отката играется LKG, а не версия с сервера.
+			if info.RolledBack && info.Installed != "" {
+				game = info.Installed
 			}
 		}
 	}
 	return &State{
-		Channel: "stable",
+		Channel: a.launcher.Channel(manifest),
 		Dependencies: &Dependencies{
 			Game: &DependencyVersion{Version: game},
-			LKG:  nil,
+			LKG:  lkg,
 		},
+		Manifest: status,
+		Release:  release,
+	}
+}
+
+// SetChannel выбирает канал сборки (stable, beta, dev) в текущем профиле.
+func (a *App) SetChannel(channel string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return a.launcher.SetChannel(ctx, channel)
+}
+
+// ServerProfileInfo — профиль сервера для интерфейса, без секретов.
+type ServerProfileInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Auth     string `json:"auth"`
+	Channel  string `json:"channel"`
+	Selected bool   `json:"selected"`
+}
+
+func (a *App) GetServerProfiles() []ServerProfileInfo {
+	serverCfg, err := a.launcher.ServerProfiles()
+	if err != nil {
+		return nil
+	}
+	profiles := make([]ServerProfileInfo, 0, len(serverCfg.Profiles))
+	for _, profile := range serverCfg.Profiles {
+		profiles = append(profiles, serverProfileInfo(&profile, serverCfg.SelectedProfile))
+	}
+	return profiles
+}
+
+// SaveServerProfile добавляет или изменяет профиль; пустой секрет не меняет сохранённый.
+func (a *App) SaveServerProfile(profile config.ServerProfile) (*ServerProfileInfo, error) {
+	saved, err := a.launcher.SaveServerProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	info := serverProfileInfo(saved, a.selectedServerProfile())
+	return &info, nil
+}
+
+func (a *App) SelectServerProfile(id string) error {
+	return a.launcher.SelectServerProfile(id)
+}
+
+func (a *App) RemoveServerProfile(id string) error {
+	return a.launcher.RemoveServerProfile(id)
+}
+
+func (a *App) selectedServerProfile() string {
+	serverCfg, err := a.launcher.ServerProfiles()
+	if err != nil {
+		return ""
 	}
+	return serverCfg.SelectedProfile
 }
 
-func (a *App) SetChannel(channel string) {}
+func serverProfileInfo(profile *config.ServerProfile, selected string) ServerProfileInfo {
+	return ServerProfileInfo{
+		ID:       profile.ID,
+		Name:     profile.Name,
+		URL:      profile.ServerBaseURL,
+		Auth:     profile.Auth,
+		Channel:  profile.Channel,
+		Selected: profile.ID == selected,
+	}
+}
 
+// CheckForUpdates: 2 — есть новая сборка лаунчера, 1 — сервер раздаёт новую
+// версию сборки игры, 0 — обновлений нет.
 func (a *App) CheckForUpdates(force bool) int {
-	return 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if a.updater != nil {
+		release, err := a.launcher.LauncherUpdate(ctx, a.updater)
+		a.updateMu.Lock()
+		a.launcherRelease = release
+		a.updateMu.Unlock()
+		if err == nil && release != nil {
+			return 2
+		}
+	}
+	available, err := a.launcher.UpdateAvailable(ctx, "")
+	if err != nil || !available {
+		return 0
+	}
+	return 1
+}
+
+// ApplyLauncherUpdate скачивает найденную сборку лаунчера и перезапускает
+// лаунчер уже новой. Если новая сборка не запустилась, работает прежняя,
+// а интерфейс получает launcher-update:error.
+func (a *App) ApplyLauncherUpdate() error {
+	if a.ctx == nil {
+		return errors.New("app not ready")
+	}
+	if a.updater == nil {
+		return errors.New("launcher self-update is unavailable")
+	}
+	a.updateMu.Lock()
+	release := a.launcherRelease
+	a.updateMu.Unlock()
+	if release == nil {
+		var err error
+		if release, err = a.launcher.LauncherUpdate(a.ctx, a.updater); err != nil {
+			return err
+		}
+		if release == nil {
+			return errors.New("no launcher update available")
+		}
+	}
+	err := a.launcher.StageLauncherUpdate(a.ctx, a.updater, release, func(evt launcher.ProgressEvent) {
+		runtime.EventsEmit(a.ctx, "launcher-update:progress", progressPayload(evt))
+	})
+	if err == nil {
+		runtime.EventsEmit(a.ctx, "launcher-update:restarting", release.Version)
+		err = a.updater.Restart(os.Args[1:])
+	}
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "launcher-update:error", err.Error())
+		return err
+	}
+	runtime.Quit(a.ctx)
+	return nil
 }
 
 func (a *App) RefreshNewsFeed() {}
@@ -139,6 +331,24 @@ func (a *App) IsLoggedIn() bool {
 
 func (a *App) Logout() {}
 
+// ServerLogin входит в аккаунт на сервере сборок (доступ к закрытым сборкам).
+func (a *App) ServerLogin(username, password string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return a.launcher.Login(ctx, username, password)
+}
+
+func (a *App) ServerLogout() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return a.launcher.Logout(ctx)
+}
+
+// GetServerUser — аккаунт на сервере сборок; пусто — вход не выполнен.
+func (a *App) GetServerUser() string {
+	return a.launcher.ServerUser()
+}
+
 func (a *App) SetUserProfile(uuid string) error {
 	profile, err := config.LoadProfile("")
 	if err != nil {
@@ -156,25 +366,20 @@ func (a *App) LaunchGame(params LaunchParams) error {
 		_ = a.OpenConsoleWindow()
 	}
 	{
-		err := a.prepareForLaunch(func(evt launcher.ProgressEvent) {
+		err := a.prepareForLaunch(params.InstanceID, func(evt launcher.ProgressEvent) {
 			if a.ctx == nil {
 				return
 			}
-			payload := map[string]any{
-				"progress": evt.Progress * 100,
-				"step":     evt.Step,
-				"done":     evt.Done,
-				"total":    evt.Total,
-			}
-			runtime.EventsEmit(a.ctx, "sync:progress", payload)
+			runtime.EventsEmit(a.ctx, "sync:progress", progressPayload(evt))
 		})
 		if err != nil {
+			a.emitClockSkew(err)
 			runtime.EventsEmit(a.ctx, "sync:error", err.Error())
 			return err
 		}
 		runtime.EventsEmit(a.ctx, "sync:complete")
 	}
-	if err := a.launcher.Launch(a.ctx, params.PlayerName); err != nil {
+	if err := a.launcher.Launch(a.ctx, params.InstanceID, params.PlayerName); err != nil {
 		runtime.EventsEmit(a.ctx, "launch:error", err.Error())
 		return err
 	}
@@ -237,10 +442,10 @@ func (a *App) OpenConsoleWindow() error {
 }
 
 func (a *App) GetMemorySettings() *MemorySettings {
-	cfg, _ := a.launcher.LoadConfig()
+	inst := a.selectedInstance()
 	current := 4096
-	if cfg != nil && cfg.MemoryMB > 0 {
-		current = cfg.MemoryMB
+	if inst != nil && inst.MemoryMB > 0 {
+		current = inst.MemoryMB
 	}
 	max := system.SystemMemoryMB()
 	if max < 512 {
@@ -264,6 +469,10 @@ func (a *App) SetMemoryMB(value int) error {
 	if err != nil {
 		return err
 	}
+	inst, err := cfg.Instance("")
+	if err != nil {
+		return err
+	}
 	if value < 512 {
 		value = 512
 	}
@@ -271,35 +480,91 @@ func (a *App) SetMemoryMB(value int) error {
 	if max > 0 && value > max {
 		value = max
 	}
-	cfg.MemoryMB = value
+	inst.MemoryMB = value
 	return cfg.Save(a.launcher.ConfigPath)
 }
 
+func (a *App) GetJavaSettings() (*JavaSettings, error) {
+	settings := &JavaSettings{Runtimes: []JavaRuntimeInfo{}}
+	if inst := a.selectedInstance(); inst != nil {
+		settings.Selected = inst.JavaPath
+	}
+	runtimes, err := a.launcher.JavaRuntimes()
+	if err != nil {
+		return nil, err
+	}
+	for _, rt := range runtimes {
+		settings.Runtimes = append(settings.Runtimes, JavaRuntimeInfo{
+			Path:    rt.Path,
+			Major:   rt.Major,
+			Version: rt.Version,
+			Vendor:  rt.Vendor,
+			Arch:    rt.Arch,
+			Is64Bit: rt.Is64Bit,
+			Source:  rt.Source,
+		})
+	}
+	return settings, nil
+}
+
+// SetJavaPath закрепляет Java за выбранной сборкой; пустой путь — выбирает лаунчер.
+func (a *App) SetJavaPath(path string) error {
+	_, err := a.launcher.SetInstanceJava("", path)
+	return err
+}
+
+// SelectJavaDir выбирает каталог JDK диалогом и закрепляет его за сборкой.
+func (a *App) SelectJavaDir() (string, error) {
+	if a.ctx == nil {
+		return "", errors.New("app not ready")
+	}
+	dir, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{})
+	if err != nil || dir == "" {
+		return "", err
+	}
+	info, err := a.launcher.SetInstanceJava("", dir)
+	if err != nil {
+		return "", err
+	}
+	return info.Path, nil
+}
+
 func (a *App) IsGameInstalled() bool {
-	ok, err := a.launcher.IsInstalled()
+	ok, err := a.launcher.IsInstalled("")
 	if err != nil {
 		return false
 	}
 	return ok
 }
 
+// progressPayload — данные событий install:progress и sync:progress.
+// progress в процентах, speed в байтах в секунду, eta в секундах.
+func progressPayload(evt launcher.ProgressEvent) map[string]any {
+	return map[string]any{
+		"progress":   evt.Progress * 100,
+		"step":       evt.Step,
+		"done":       evt.Done,
+		"total":      evt.Total,
+		"bytesDone":  evt.BytesDone,
+		"bytesTotal": evt.BytesTotal,
+		"speed":      evt.Speed,
+		"eta":        int64(evt.ETA.Seconds()),
+		"file":       evt.File,
+	}
+}
+
 func (a *App) InstallGame() error {
 	if a.ctx == nil {
 		return errors.New("app not ready")
 	}
-	_, err := a.launcher.Install(a.ctx, func(evt launcher.ProgressEvent) {
+	_, err := a.launcher.Install(a.ctx, "", func(evt launcher.ProgressEvent) {
 		if a.ctx == nil {
 			return
 		}
-		payload := map[string]any{
-			"progress": evt.Progress * 100,
-			"step":     evt.Step,
-			"done":     evt.Done,
-			"total":    evt.Total,
-		}
-		runtime.EventsEmit(a.ctx, "install:progress", payload)
+		runtime.EventsEmit(a.ctx, "install:progress", progressPayload(evt))
 	})
 	if err != nil {
+		a.emitClockSkew(err)
 		runtime.EventsEmit(a.ctx, "install:error", err.Error())
 		return err
 	}
@@ -307,23 +572,78 @@ func (a *App) InstallGame() error {
 	return nil
 }
 
-func (a *App) prepareForLaunch(onProgress func(launcher.ProgressEvent)) error {
+// VerifyGame проверяет файлы выбранной сборки, ничего не меняя.
+func (a *App) VerifyGame() (*launcher.VerifyReport, error) {
+	if a.ctx == nil {
+		return nil, errors.New("app not ready")
+	}
+	return a.launcher.Verify(a.ctx, "", func(evt launcher.ProgressEvent) {
+		runtime.EventsEmit(a.ctx, "verify:progress", progressPayload(evt))
+	})
+}
+
+// RepairGame — кнопка «Починить»: проверяет сборку и перекачивает
+// только повреждённые и отсутствующие файлы.
+func (a *App) RepairGame() (*launcher.VerifyReport, error) {
+	if a.ctx == nil {
+		return nil, errors.New("app not ready")
+	}
+	report, err := a.launcher.Repair(a.ctx, "", func(evt launcher.ProgressEvent) {
+		runtime.EventsEmit(a.ctx, "repair:progress", progressPayload(evt))
+	})
+	if err != nil {
+		a.emitClockSkew(err)
+		runtime.EventsEmit(a.ctx, "repair:error", err.Error())
+		return nil, err
+	}
+	runtime.EventsEmit(a.ctx, "repair:complete", report)
+	return report, nil
+}
+
+// RollbackGame возвращает выбранную сборку к последней рабочей версии.
+func (a *App) RollbackGame() error {
 	if a.ctx == nil {
 		return errors.New("app not ready")
 	}
-	return a.launcher.PrepareForLaunch(a.ctx, onProgress)
+	err := a.launcher.Rollback(a.ctx, "", func(evt launcher.ProgressEvent) {
+		runtime.EventsEmit(a.ctx, "rollback:progress", progressPayload(evt))
+	})
+	if err != nil {
+		a.emitClockSkew(err)
+		runtime.EventsEmit(a.ctx, "rollback:error", err.Error())
+		return err
+	}
+	runtime.EventsEmit(a.ctx, "rollback:complete")
+	return nil
+}
+
+// emitClockSkew сообщает интерфейсу о неверных системных часах отдельным
+// событием clock:skew: offset — расхождение с сервером в секундах (0 — неизвестно).
+func (a *App) emitClockSkew(err error) {
+	var skew *server.ClockSkewError
+	if !errors.As(err, &skew) {
+		return
+	}
+	runtime.EventsEmit(a.ctx, "clock:skew", map[string]any{"offset": int64(skew.Offset.Seconds())})
+}
+
+func (a *App) prepareForLaunch(instanceID string, onProgress func(launcher.ProgressEvent)) error {
+	if a.ctx == nil {
+		return errors.New("app not ready")
+	}
+	return a.launcher.PrepareForLaunch(a.ctx, instanceID, onProgress)
 }
 
 func (a *App) OpenGameDirectory() {
 	if a.ctx == nil {
 		return
 	}
-	cfg, err := a.launcher.LoadConfig()
-	if err != nil {
-		runtime.EventsEmit(a.ctx, "open:error", err.Error())
+	inst := a.selectedInstance()
+	if inst == nil {
+		runtime.EventsEmit(a.ctx, "open:error", "instance not found")
 		return
 	}
-	openDirectory(cfg.InstallDir)
+	openDirectory(inst.Dir)
 }
 
 func (a *App) GetInstallDir() string {
@@ -342,10 +662,85 @@ func (a *App) SetInstallDir(path string) error {
 	if err != nil {
 		return err
 	}
+	// Сборки, лежащие прямо в общей папке (перенесённая "default"), переезжают вместе с ней.
+	for i := range cfg.Instances {
+		if cfg.Instances[i].Dir == cfg.InstallDir {
+			cfg.Instances[i].Dir = path
+		}
+	}
 	cfg.InstallDir = path
 	return cfg.Save(a.launcher.ConfigPath)
 }
 
+func (a *App) GetInstances() []config.Instance {
+	cfg, err := a.launcher.LoadConfig()
+	if err != nil {
+		return nil
+	}
+	return cfg.Instances
+}
+
+func (a *App) GetSelectedInstance() string {
+	cfg, err := a.launcher.LoadConfig()
+	if err != nil {
+		return ""
+	}
+	return cfg.SelectedInstance
+}
+
+func (a *App) SelectInstance(id string) error {
+	cfg, err := a.launcher.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if err := cfg.SelectInstance(id); err != nil {
+		return err
+	}
+	return cfg.Save(a.launcher.ConfigPath)
+}
+
+func (a *App) CreateInstance(name, manifestURL string) (*config.Instance, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, errors.New("instance name required")
+	}
+	cfg, err := a.launcher.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	inst, err := cfg.AddInstance(config.Instance{Name: name, ManifestURL: manifestURL})
+	if err != nil {
+		return nil, err
+	}
+	created := *inst
+	if err := cfg.Save(a.launcher.ConfigPath); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (a *App) RemoveInstance(id string) error {
+	cfg, err := a.launcher.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if err := cfg.RemoveInstance(id); err != nil {
+		return err
+	}
+	return cfg.Save(a.launcher.ConfigPath)
+}
+
+func (a *App) selectedInstance() *config.Instance {
+	cfg, err := a.launcher.LoadConfig()
+	if err != nil {
+		return nil
+	}
+	inst, err := cfg.Instance("")
+	if err != nil {
+		return nil
+	}
+	return inst
+}
+
 func (a *App) SelectInstallDir() (string, error) {
 	if a.ctx == nil {
 		return "", errors.New("app not ready")
@@ -0,0 +1,42 @@
+// Package build holds the launcher version and update source stamped at link time:
+//
+//	wails build -ldflags "-X shinecore/internal/build.Version=1.2.0 -X shinecore/internal/build.Release=stable"
+package build
+
+import (
+	"runtime"
+	"strings"
+)
+
+var (
+	// Version — версия лаунчера (semver без "v"); "dev" — локальная сборка.
+	Version = "dev"
+	// Release — канал сборки лаунчера.
+	Release = "stable"
+	// UpdateURL — сервер обновлений лаунчера (GET /launcher/latest). Профили
+	// из server.json на самообновление не влияют.
+	UpdateURL = ""
+	// UpdatePublicKey — открытый ключ подписи сборок лаунчера (Ed25519 в
+	// base64 или ключ minisign "RW...").
+	UpdatePublicKey = ""
+)
+
+// OS — платформа в терминах сервера обновлений.
+func OS() string {
+	return runtime.GOOS
+}
+
+// Arch — архитектура в терминах сервера обновлений.
+func Arch() string {
+	return runtime.GOARCH
+}
+
+// IsDev — локальная сборка без версии: самообновление для неё отключено.
+func IsDev() bool {
+	return Version == "" || Version == "dev"
+}
+
+// UpdatesEnabled — в сборку встроены сервер обновлений и ключ их подписи.
+func UpdatesEnabled() bool {
+	return !IsDev() && strings.TrimSpace(UpdateURL) != "" && strings.TrimSpace(UpdatePublicKey) != ""
+}
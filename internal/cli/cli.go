@@ -0,0 +1,626 @@
+// Package cli implements the headless command-line front-end of the launcher.
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"shinecore/internal/launcher"
+	"shinecore/internal/logging"
+)
+
+// Exit codes returned by Run.
+const (
+	ExitOK           = 0
+	ExitFailure      = 1
+	ExitUsage        = 2
+	ExitNotInstalled = 3
+	ExitCorrupt      = 4
+	ExitInterrupted  = 130
+)
+
+const usageText = `Usage: shinecore [global flags] <command> [flags]
+
+Commands:
+  install    install or update the game from the server manifest
+  sync       synchronize mods with the server manifest
+  launch     start the game (--player NAME); waits until a new release is confirmed
+  status     print the current installation state
+  verify     check installed files against their expected hashes (--deep)
+  repair     re-download missing or corrupt files and remove extra mods (--deep)
+  rollback   return the instance to its last-known-good release
+  instances  list registered game instances
+  servers    list server profiles (--select ID, --channel NAME)
+  gc         remove unreferenced files from the shared store
+  java       list found Java runtimes (--set PATH pins one to the instance, --auto unpins)
+  login      log in to the modpack server (--user NAME, password on stdin)
+  logout     revoke the server session and forget saved tokens
+
+Global flags:
+  --config PATH   launcher config file (default: shinecore/launcher.json in the
+                  OS user config directory, e.g. %APPDATA% or ~/.config)
+  --instance ID   game instance to operate on (default: selected instance)
+  --json          print progress and results as JSON lines
+  --verbose       duplicate launcher logs to stderr
+`
+
+type command struct {
+	name string
+	run  func(ctx context.Context, env *env, args []string) int
+}
+
+var commands = []command{
+	{name: "install", run: runInstall},
+	{name: "sync", run: runSync},
+	{name: "launch", run: runLaunch},
+	{name: "status", run: runStatus},
+	{name: "verify", run: runVerify},
+	{name: "repair", run: runRepair},
+	{name: "rollback", run: runRollback},
+	{name: "instances", run: runInstances},
+	{name: "servers", run: runServers},
+	{name: "gc", run: runGC},
+	{name: "java", run: runJava},
+	{name: "login", run: runLogin},
+	{name: "logout", run: runLogout},
+}
+
+type env struct {
+	launcher *launcher.Launcher
+	instance string
+	out      *printer
+	stderr   io.Writer
+}
+
+// Run parses args (without the program name), executes the requested command
+// and returns the process exit code.
+func Run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("shinecore", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { fmt.Fprint(stderr, usageText) }
+	configPath := global.String("config", "", "launcher config file")
+	instanceID := global.String("instance", "", "game instance id")
+	jsonOutput := global.Bool("json", false, "print JSON lines")
+	verbose := global.Bool("verbose", false, "duplicate logs to stderr")
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	rest := global.Args()
+	if len(rest) == 0 {
+		global.Usage()
+		return ExitUsage
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == rest[0] {
+			cmd = &commands[i]
+			break
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown command: %s\n\n", rest[0])
+		global.Usage()
+		return ExitUsage
+	}
+
+	if *verbose {
+		logging.SetConsole(stderr)
+	} else {
+		logging.SetConsole(io.Discard)
+	}
+	logging.Init()
+
+	e := &env{
+		launcher: &launcher.Launcher{ConfigPath: *configPath},
+		instance: *instanceID,
+		out:      &printer{w: stdout, errW: stderr, json: *jsonOutput, command: cmd.name},
+		stderr:   stderr,
+	}
+	return cmd.run(ctx, e, rest[1:])
+}
+
+func newFlagSet(e *env, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	return fs
+}
+
+func runInstall(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "install")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	inst, err := e.launcher.Install(ctx, e.instance, e.out.progress)
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	return e.out.result(map[string]any{
+		"instance":       inst.ID,
+		"game_version":   inst.GameVersion,
+		"loader":         inst.Loader,
+		"loader_version": inst.LoaderVersion,
+		"instance_dir":   inst.Dir,
+	})
+}
+
+func runSync(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "sync")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	if err := e.launcher.SyncMods(ctx, e.instance, e.out.progress); err != nil {
+		return e.fail(ctx, err)
+	}
+	return e.out.result(nil)
+}
+
+func runLaunch(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "launch")
+	player := fs.String("player", "", "player name (default: saved profile)")
+	skipPrepare := fs.Bool("no-prepare", false, "skip sync and install checks before launch")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	if !*skipPrepare {
+		if err := e.launcher.PrepareForLaunch(ctx, e.instance, e.out.progress); err != nil {
+			return e.fail(ctx, err)
+		}
+	}
+	if err := e.launcher.LaunchAndWait(ctx, e.instance, *player); err != nil {
+		return e.fail(ctx, err)
+	}
+	return e.out.result(nil)
+}
+
+func runStatus(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "status")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	cfg, err := e.launcher.LoadConfig()
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	inst, err := cfg.Instance(e.instance)
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	installed, err := e.launcher.IsInstalled(inst.ID)
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	fields := map[string]any{
+		"installed":      installed,
+		"instance":       inst.ID,
+		"instance_dir":   inst.Dir,
+		"install_dir":    cfg.InstallDir,
+		"game_version":   inst.GameVersion,
+		"loader":         inst.Loader,
+		"loader_version": inst.LoaderVersion,
+		"memory_mb":      inst.MemoryMB,
+	}
+	if release, err := e.launcher.Release(inst.ID); err == nil {
+		fields["release"] = release.Installed
+		fields["lkg"] = release.LKG
+		fields["rolled_back"] = release.RolledBack
+		if release.Failed != nil {
+			fields["failed_release"] = release.Failed.Version + " (" + release.Failed.Reason + ")"
+		}
+	}
+	code := e.out.result(fields)
+	if code == ExitOK && !installed {
+		return ExitNotInstalled
+	}
+	return code
+}
+
+func runVerify(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "verify")
+	deep := fs.Bool("deep", false, "rehash every file instead of trusting the file index")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	e.launcher.DeepVerify = *deep
+	report, err := e.launcher.Verify(ctx, e.instance, e.out.progress)
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	return e.reportResult(report)
+}
+
+func runRepair(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "repair")
+	deep := fs.Bool("deep", false, "rehash every file instead of trusting the file index")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	e.launcher.DeepVerify = *deep
+	report, err := e.launcher.Repair(ctx, e.instance, e.out.progress)
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	return e.reportResult(report)
+}
+
+func runRollback(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "rollback")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	if err := e.launcher.Rollback(ctx, e.instance, e.out.progress); err != nil {
+		return e.fail(ctx, err)
+	}
+	release, err := e.launcher.Release(e.instance)
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	return e.out.result(map[string]any{"release": release.Installed, "lkg": release.LKG})
+}
+
+// reportResult печатает отчёт проверки; код выхода отражает его итог.
+func (e *env) reportResult(report *launcher.VerifyReport) int {
+	fields := map[string]any{
+		"instance":   report.Instance,
+		"version_id": report.VersionID,
+		"checked":    report.Checked,
+		"missing":    len(report.Missing),
+		"corrupt":    len(report.Corrupt),
+		"extra":      len(report.Extra),
+	}
+	if report.Repaired > 0 {
+		fields["repaired"] = report.Repaired
+	}
+	if len(report.Skipped) > 0 {
+		fields["skipped"] = strings.Join(report.Skipped, "; ")
+	}
+	if e.out.json {
+		fields["missing"] = report.Missing
+		fields["corrupt"] = report.Corrupt
+		fields["extra"] = report.Extra
+		fields["skipped"] = report.Skipped
+	} else {
+		e.out.issues("missing", report.Missing)
+		e.out.issues("corrupt", report.Corrupt)
+		e.out.issues("extra", report.Extra)
+	}
+	if report.OK() {
+		return e.out.result(fields)
+	}
+	for _, issue := range report.Missing {
+		if issue.Kind == launcher.FileVersion {
+			e.out.errorWith(errors.New("game is not installed"), fields)
+			return ExitNotInstalled
+		}
+	}
+	e.out.errorWith(fmt.Errorf("%d missing, %d corrupt, %d extra files", len(report.Missing), len(report.Corrupt), len(report.Extra)), fields)
+	return ExitCorrupt
+}
+
+func runInstances(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "instances")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	cfg, err := e.launcher.LoadConfig()
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	list := make([]map[string]any, 0, len(cfg.Instances))
+	for _, inst := range cfg.Instances {
+		list = append(list, map[string]any{
+			"id":           inst.ID,
+			"name":         inst.Name,
+			"dir":          inst.Dir,
+			"game_version": inst.GameVersion,
+			"loader":       inst.Loader,
+			"selected":     inst.ID == cfg.SelectedInstance,
+		})
+	}
+	if e.out.json {
+		return e.out.result(map[string]any{"instances": list})
+	}
+	fields := map[string]any{}
+	for _, inst := range cfg.Instances {
+		mark := ""
+		if inst.ID == cfg.SelectedInstance {
+			mark = " (selected)"
+		}
+		fields[inst.ID] = fmt.Sprintf("%s, %s %s%s, %s", inst.Name, inst.GameVersion, inst.Loader, mark, inst.Dir)
+	}
+	return e.out.result(fields)
+}
+
+func runServers(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "servers")
+	selectID := fs.String("select", "", "switch to the server profile with this id")
+	channel := fs.String("channel", "", "switch the selected profile to this channel")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	if *selectID != "" {
+		if err := e.launcher.SelectServerProfile(*selectID); err != nil {
+			return e.fail(ctx, err)
+		}
+	}
+	if *channel != "" {
+		if err := e.launcher.SetChannel(ctx, *channel); err != nil {
+			return e.fail(ctx, err)
+		}
+	}
+	serverCfg, err := e.launcher.ServerProfiles()
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	list := make([]map[string]any, 0, len(serverCfg.Profiles))
+	for _, profile := range serverCfg.Profiles {
+		list = append(list, map[string]any{
+			"id":       profile.ID,
+			"name":     profile.Name,
+			"url":      profile.ServerBaseURL,
+			"channel":  profile.Channel,
+			"selected": profile.ID == serverCfg.SelectedProfile,
+		})
+	}
+	if e.out.json {
+		return e.out.result(map[string]any{"servers": list})
+	}
+	fields := map[string]any{}
+	for _, profile := range serverCfg.Profiles {
+		mark := ""
+		if profile.ID == serverCfg.SelectedProfile {
+			mark = " (selected)"
+		}
+		channel := profile.Channel
+		if channel == "" {
+			channel = "default channel"
+		}
+		fields[profile.ID] = fmt.Sprintf("%s, %s, %s%s", profile.Name, profile.ServerBaseURL, channel, mark)
+	}
+	return e.out.result(fields)
+}
+
+func runGC(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "gc")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	result, err := e.launcher.GC()
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	return e.out.result(map[string]any{
+		"removed_objects": result.RemovedObjects,
+		"freed_bytes":     result.FreedBytes,
+		"kept_objects":    result.KeptObjects,
+	})
+}
+
+func runJava(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "java")
+	set := fs.String("set", "", "use this java (or JDK directory) for the instance")
+	auto := fs.Bool("auto", false, "let the launcher pick java for the instance")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	if *set != "" || *auto {
+		if _, err := e.launcher.SetInstanceJava(e.instance, *set); err != nil {
+			return e.fail(ctx, err)
+		}
+	}
+	cfg, err := e.launcher.LoadConfig()
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	inst, err := cfg.Instance(e.instance)
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	runtimes, err := e.launcher.JavaRuntimes()
+	if err != nil {
+		return e.fail(ctx, err)
+	}
+	selected := inst.JavaPath
+	if e.out.json {
+		return e.out.result(map[string]any{"runtimes": runtimes, "selected": selected})
+	}
+	if selected == "" {
+		selected = "auto"
+	}
+	fields := map[string]any{"selected": selected}
+	for _, rt := range runtimes {
+		bits := "64-bit"
+		if !rt.Is64Bit {
+			bits = "32-bit"
+		}
+		fields[rt.Path] = fmt.Sprintf("Java %s, %s, %s %s, %s", rt.Version, rt.Vendor, rt.Arch, bits, rt.Source)
+	}
+	return e.out.result(fields)
+}
+
+func runLogin(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "login")
+	user := fs.String("user", "", "server account name")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	if strings.TrimSpace(*user) == "" {
+		fmt.Fprintln(e.stderr, "login: --user is required")
+		return ExitUsage
+	}
+	// Пароль читаем из stdin, чтобы он не попадал в историю команд и список процессов.
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return e.fail(ctx, err)
+	}
+	if err := e.launcher.Login(ctx, *user, strings.TrimRight(password, "\r\n")); err != nil {
+		return e.fail(ctx, err)
+	}
+	return e.out.result(map[string]any{"user": e.launcher.ServerUser()})
+}
+
+func runLogout(ctx context.Context, e *env, args []string) int {
+	fs := newFlagSet(e, "logout")
+	if err := fs.Parse(args); err != nil {
+		return usageExit(err)
+	}
+	if err := e.launcher.Logout(ctx); err != nil {
+		return e.fail(ctx, err)
+	}
+	return e.out.result(nil)
+}
+
+func (e *env) fail(ctx context.Context, err error) int {
+	e.out.error(err)
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return ExitInterrupted
+	}
+	return ExitFailure
+}
+
+func usageExit(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	return ExitUsage
+}
+
+// printer пишет события либо в человекочитаемом виде, либо JSON-строками.
+// В текстовом режиме ошибки идут в errW (stderr), в JSON — в общий поток.
+type printer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	errW    io.Writer
+	json    bool
+	command string
+}
+
+func (p *printer) progress(evt launcher.ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.json {
+		p.writeJSON(map[string]any{
+			"type":        "progress",
+			"step":        evt.Step,
+			"done":        evt.Done,
+			"total":       evt.Total,
+			"progress":    evt.Progress * 100,
+			"bytes_done":  evt.BytesDone,
+			"bytes_total": evt.BytesTotal,
+			"speed":       evt.Speed,
+			"eta_seconds": int64(evt.ETA.Seconds()),
+			"file":        evt.File,
+		})
+		return
+	}
+	line := fmt.Sprintf("[%s] %d/%d %5.1f%%", evt.Step, evt.Done, evt.Total, evt.Progress*100)
+	if evt.BytesTotal > 0 {
+		line += fmt.Sprintf(" %s/%s", formatBytes(evt.BytesDone), formatBytes(evt.BytesTotal))
+	}
+	if evt.Speed > 0 {
+		line += fmt.Sprintf(" %s/s", formatBytes(int64(evt.Speed)))
+	}
+	if evt.ETA > 0 {
+		line += " ETA " + evt.ETA.String()
+	}
+	if evt.File != "" {
+		line += " " + evt.File
+	}
+	fmt.Fprintln(p.w, line)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func (p *printer) result(fields map[string]any) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.json {
+		payload := map[string]any{"type": "result", "command": p.command, "ok": true}
+		for key, value := range fields {
+			payload[key] = value
+		}
+		p.writeJSON(payload)
+		return ExitOK
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(p.w, "%s: %v\n", key, fields[key])
+	}
+	fmt.Fprintf(p.w, "%s: ok\n", p.command)
+	return ExitOK
+}
+
+func (p *printer) issues(label string, list []launcher.FileIssue) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, issue := range list {
+		line := fmt.Sprintf("%s %s: %s", label, issue.Kind, issue.Path)
+		if issue.Detail != "" {
+			line += " (" + issue.Detail + ")"
+		}
+		fmt.Fprintln(p.w, line)
+	}
+}
+
+func (p *printer) error(err error) {
+	p.errorWith(err, nil)
+}
+
+// errorWith сообщает об ошибке вместе с полями результата (например, отчётом verify).
+func (p *printer) errorWith(err error, fields map[string]any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.json {
+		payload := map[string]any{"type": "error", "command": p.command, "ok": false, "message": err.Error()}
+		for key, value := range fields {
+			payload[key] = value
+		}
+		p.writeJSON(payload)
+		return
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(p.w, "%s: %v\n", key, fields[key])
+	}
+	fmt.Fprintf(p.errW, "%s: error: %s\n", p.command, strings.TrimSpace(err.Error()))
+}
+
+func (p *printer) writeJSON(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "json encode failed:", err)
+		return
+	}
+	p.w.Write(append(data, '\n'))
+}
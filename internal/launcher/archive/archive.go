@@ -0,0 +1,241 @@
+// Package archive распаковывает zip и tar-архивы (JDK, рантаймы) с правами
+// доступа файлов и ссылками. Ни файл, ни ссылка не выходят за каталог
+// распаковки.
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnsupported = errors.New("unsupported archive format")
+
+// Extract распаковывает архив, формат берётся из имени: zip, tar,
+// tar.gz/tgz, tar.xz/txz, tar.zst/tzst.
+func Extract(src, dst string) error {
+	name := strings.ToLower(src)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ExtractZip(src, dst)
+	case IsTar(name):
+		return ExtractTar(src, dst)
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupported, filepath.Base(src))
+}
+
+// IsTar — имя tar-архива, который умеет распаковывать ExtractTar.
+func IsTar(name string) bool {
+	return hasSuffix(strings.ToLower(name), ".tar", ".tar.gz", ".tgz", ".tar.xz", ".txz", ".tar.zst", ".tzst")
+}
+
+func hasSuffix(name string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// safePath — путь записи архива внутри dst.
+func safePath(dst, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if clean == "" {
+		return "", errors.New("invalid archive entry: " + name)
+	}
+	// "./" в начале tar-архивов — сам каталог распаковки.
+	if clean == "." {
+		return dst, nil
+	}
+	if !filepath.IsLocal(clean) {
+		return "", errors.New("unsafe archive entry: " + name)
+	}
+	return filepath.Join(dst, clean), nil
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && (rel == "." || filepath.IsLocal(rel))
+}
+
+type link struct {
+	path   string
+	target string
+}
+
+// extractor — общая часть распаковки: проверка путей и отложенное создание
+// ссылок (после файлов, чтобы при копировании вместо ссылки цель уже была).
+type extractor struct {
+	dst       string
+	symlinks  []link
+	hardlinks []link
+}
+
+func newExtractor(dst string) (*extractor, error) {
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return nil, err
+	}
+	return &extractor{dst: dst}, nil
+}
+
+func (x *extractor) dir(path string, mode fs.FileMode) error {
+	// Каталог без права записи не дал бы распаковать в него файлы.
+	return os.MkdirAll(path, mode.Perm()|0o700)
+}
+
+func (x *extractor) file(path string, mode fs.FileMode, r io.Reader) error {
+	if err := prepare(x.dst, path); err != nil {
+		return err
+	}
+	// Без права записи файл не удалить при переустановке в Windows.
+	perm := mode.Perm() | 0o200
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// symlink запоминает ссылку path -> target (target относительно каталога ссылки).
+func (x *extractor) symlink(path, target string) {
+	x.symlinks = append(x.symlinks, link{path: path, target: target})
+}
+
+// hardlink запоминает жёсткую ссылку path на уже распакованный target.
+func (x *extractor) hardlink(path, target string) {
+	x.hardlinks = append(x.hardlinks, link{path: path, target: target})
+}
+
+func (x *extractor) finish() error {
+	for _, l := range x.symlinks {
+		if err := Symlink(x.dst, l.path, l.target); err != nil {
+			return err
+		}
+	}
+	for _, l := range x.hardlinks {
+		if err := x.link(l.path, l.target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) link(path, target string) error {
+	root, err := filepath.EvalSymlinks(x.dst)
+	if err != nil {
+		return err
+	}
+	real, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return err
+	}
+	if !within(root, real) {
+		return errors.New("archive link escapes destination: " + target)
+	}
+	info, err := os.Lstat(real)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return errors.New("archive hard link to non-regular file: " + target)
+	}
+	if err := prepare(x.dst, path); err != nil {
+		return err
+	}
+	if err := os.Link(real, path); err == nil {
+		return nil
+	}
+	return copyFile(real, path, info.Mode())
+}
+
+// prepare создаёт каталог для path и проверяет, что он (с раскрытыми
+// ссылками) лежит внутри root. Файл или ссылка на месте path удаляются:
+// запись пошла бы по ссылке.
+func prepare(root, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if !within(realRoot, parent) {
+		return errors.New("archive entry escapes destination: " + path)
+	}
+	if info, err := os.Lstat(path); err == nil && !info.IsDir() {
+		return os.Remove(path)
+	}
+	return nil
+}
+
+// Symlink создаёт ссылку path -> target (относительно каталога ссылки),
+// которая не выходит за root. Где ссылки создавать нельзя (Windows без
+// режима разработчика), файл-цель копируется, а ссылки на каталоги
+// пропускаются.
+func Symlink(root, path, target string) error {
+	target = filepath.FromSlash(target)
+	if target == "" || filepath.IsAbs(target) || filepath.VolumeName(target) != "" || strings.HasPrefix(target, string(filepath.Separator)) {
+		return errors.New("archive link must be relative: " + path + " -> " + target)
+	}
+	// Записываем очищенную цель: "a/../x" через ссылку a вела бы не туда,
+	// куда указывает при проверке.
+	target = filepath.Clean(target)
+	if err := prepare(root, path); err != nil {
+		return err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	resolved := filepath.Join(parent, target)
+	if !within(realRoot, resolved) {
+		return errors.New("archive link escapes destination: " + path + " -> " + target)
+	}
+	if err := os.Symlink(target, path); err == nil {
+		return nil
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		slog.Warn("archive: cannot create directory link, skipping", "path", path, "target", target)
+		return nil
+	}
+	return copyFile(resolved, path, info.Mode())
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()|0o200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
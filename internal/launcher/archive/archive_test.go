@@ -0,0 +1,231 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// entry — запись тестового архива: файл, каталог, ссылка или жёсткая ссылка.
+type entry struct {
+	name     string
+	body     string
+	mode     int64
+	dir      bool
+	symlink  string
+	hardlink string
+}
+
+func file(name, body string, mode int64) entry { return entry{name: name, body: body, mode: mode} }
+func symlink(name, target string) entry        { return entry{name: name, symlink: target} }
+func hardlink(name, target string) entry       { return entry{name: name, hardlink: target} }
+
+func writeTar(t *testing.T, w io.Writer, entries []entry) {
+	t.Helper()
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: e.mode, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		switch {
+		case e.dir:
+			header.Typeflag, header.Mode = tar.TypeDir, 0o755
+		case e.symlink != "":
+			header.Typeflag, header.Linkname, header.Mode = tar.TypeSymlink, e.symlink, 0o777
+		case e.hardlink != "":
+			header.Typeflag, header.Linkname, header.Mode = tar.TypeLink, e.hardlink, 0o644
+		}
+		if header.Typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := io.WriteString(tw, e.body); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeArchive сохраняет записи в архив name, формат — по расширению.
+func writeArchive(t *testing.T, dir, name string, entries []entry) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	var buf bytes.Buffer
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		writeZip(t, &buf, entries)
+	case strings.HasSuffix(name, ".gz"):
+		gz := gzip.NewWriter(&buf)
+		writeTar(t, gz, entries)
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case strings.HasSuffix(name, ".xz"):
+		xw, err := xz.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTar(t, xw, entries)
+		if err := xw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case strings.HasSuffix(name, ".zst"):
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTar(t, zw, entries)
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		writeTar(t, &buf, entries)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writeZip(t *testing.T, w io.Writer, entries []entry) {
+	t.Helper()
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		body := e.body
+		switch {
+		case e.dir:
+			header.Name = strings.TrimSuffix(e.name, "/") + "/"
+			header.SetMode(fs.ModeDir | 0o755)
+		case e.symlink != "":
+			header.SetMode(fs.ModeSymlink | 0o777)
+			body = e.symlink
+		case e.hardlink != "":
+			t.Fatal("zip has no hard links")
+		default:
+			header.SetMode(fs.FileMode(e.mode))
+		}
+		out, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(out, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractFormats(t *testing.T) {
+	entries := []entry{
+		{name: "jdk/", dir: true},
+		file("jdk/bin/java", "#!/bin/sh\n", 0o755),
+		file("jdk/release", "JAVA_VERSION=\"21\"\n", 0o644),
+		symlink("jdk/bin/java-link", "java"),
+	}
+	for _, name := range []string{"jdk.tar", "jdk.tar.gz", "jdk.tar.xz", "jdk.tar.zst", "jdk.zip"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := writeArchive(t, dir, name, entries)
+			dst := filepath.Join(dir, "out")
+			if err := Extract(src, dst); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(dst, "jdk", "release"))
+			if err != nil || string(data) != "JAVA_VERSION=\"21\"\n" {
+				t.Fatalf("release = %q, %v", data, err)
+			}
+			if runtime.GOOS == "windows" {
+				return
+			}
+			info, err := os.Stat(filepath.Join(dst, "jdk", "bin", "java"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm()&0o111 == 0 {
+				t.Errorf("bin/java mode = %v, want executable", info.Mode())
+			}
+			if target, err := os.Readlink(filepath.Join(dst, "jdk", "bin", "java-link")); err != nil || target != "java" {
+				t.Errorf("java-link -> %q, %v; want java", target, err)
+			}
+		})
+	}
+}
+
+func TestExtractRejectsEscapes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need developer mode on Windows")
+	}
+	tests := []struct {
+		name    string
+		entries []entry
+		wantErr string
+	}{
+		{name: "parent traversal", entries: []entry{file("../evil", "x", 0o644)}, wantErr: "unsafe archive entry"},
+		{name: "nested traversal", entries: []entry{file("jdk/../../evil", "x", 0o644)}, wantErr: "unsafe archive entry"},
+		{name: "absolute path", entries: []entry{file("/tmp/evil", "x", 0o644)}, wantErr: "unsafe archive entry"},
+		{name: "symlink to parent", entries: []entry{symlink("up", "../outside")}, wantErr: "escapes destination"},
+		{name: "symlink cleaned to parent", entries: []entry{{name: "a/", dir: true}, symlink("a/up", "b/../../..")}, wantErr: "escapes destination"},
+		{name: "absolute symlink", entries: []entry{symlink("passwd", "/etc/passwd")}, wantErr: "must be relative"},
+		{
+			name:    "file under escaping symlinked dir",
+			entries: []entry{symlink("lib", "../outside"), file("lib/evil", "x", 0o644)},
+			wantErr: "escapes destination",
+		},
+		{name: "hard link traversal", entries: []entry{file("a", "x", 0o644), hardlink("b", "../outside/secret")}, wantErr: "unsafe archive entry"},
+		{
+			name:    "hard link through escaping symlink",
+			entries: []entry{symlink("out", ".."), hardlink("b", "out/secret")},
+			wantErr: "escapes destination",
+		},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{"tar", "zip"} {
+			hasHardlink := false
+			for _, e := range tt.entries {
+				hasHardlink = hasHardlink || e.hardlink != ""
+			}
+			if format == "zip" && hasHardlink {
+				continue
+			}
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				dir := t.TempDir()
+				outside := filepath.Join(dir, "outside")
+				if err := os.MkdirAll(outside, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				src := writeArchive(t, dir, "evil."+format, tt.entries)
+				dst := filepath.Join(dir, "out")
+				err := Extract(src, dst)
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Extract() error = %v, want %q", err, tt.wantErr)
+				}
+				for _, leaked := range []string{filepath.Join(dir, "evil"), filepath.Join(outside, "evil"), filepath.Join(dst, "b")} {
+					if _, err := os.Lstat(leaked); err == nil {
+						t.Errorf("%s was written", leaked)
+					}
+				}
+			})
+		}
+	}
+}
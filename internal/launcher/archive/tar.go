@@ -6,10 +6,13 @@ import (
 	"errors"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
 )
 
+// ExtractTar распаковывает tar, tar.gz/tgz, tar.xz/txz и tar.zst/tzst.
 func ExtractTar(src, dst string) error {
 	file, err := os.Open(src)
 	if err != nil {
@@ -18,19 +21,39 @@ func ExtractTar(src, dst string) error {
 	defer file.Close()
 
 	var reader io.Reader = file
-	if strings.HasSuffix(strings.ToLower(src), ".gz") || strings.HasSuffix(strings.ToLower(src), ".tgz") {
+	name := strings.ToLower(src)
+	switch {
+	case hasSuffix(name, ".gz", ".tgz"):
 		gz, err := gzip.NewReader(file)
 		if err != nil {
 			return err
 		}
 		defer gz.Close()
 		reader = gz
+	case hasSuffix(name, ".xz", ".txz"):
+		if reader, err = xz.NewReader(file); err != nil {
+			return err
+		}
+	case hasSuffix(name, ".zst", ".tzst"):
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		reader = zr
+	}
+	return extractTar(tar.NewReader(reader), dst)
+}
+
+func extractTar(tr *tar.Reader, dst string) error {
+	x, err := newExtractor(dst)
+	if err != nil {
+		return err
 	}
-	tr := tar.NewReader(reader)
 	for {
 		header, err := tr.Next()
 		if err == io.EOF {
-			return nil
+			return x.finish()
 		}
 		if err != nil {
 			return err
@@ -38,43 +61,34 @@ func ExtractTar(src, dst string) error {
 		if header == nil {
 			continue
 		}
-		target, err := safeTarPath(dst, header.Name)
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		target, err := safePath(dst, header.Name)
 		if err != nil {
 			return err
 		}
+		mode := header.FileInfo().Mode()
 		switch header.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(target, 0o755); err != nil {
+			if err := x.dir(target, mode); err != nil {
 				return err
 			}
 		case tar.TypeReg:
-			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			if err := x.file(target, mode, tr); err != nil {
 				return err
 			}
-			out, err := os.Create(target)
+		case tar.TypeSymlink:
+			x.symlink(target, header.Linkname)
+		case tar.TypeLink:
+			// Цель жёсткой ссылки — путь от корня архива.
+			linked, err := safePath(dst, header.Linkname)
 			if err != nil {
 				return err
 			}
-			if _, err := io.Copy(out, tr); err != nil {
-				out.Close()
-				return err
-			}
-			if err := out.Close(); err != nil {
-				return err
-			}
+			x.hardlink(target, linked)
 		default:
 			return errors.New("unsupported tar entry type: " + string(header.Typeflag))
 		}
 	}
 }
-
-func safeTarPath(dst, name string) (string, error) {
-	clean := filepath.Clean(filepath.FromSlash(name))
-	if clean == "." || clean == "" {
-		return "", errors.New("invalid tar entry: " + name)
-	}
-	if strings.HasPrefix(clean, "..") || filepath.IsAbs(clean) {
-		return "", errors.New("unsafe tar entry: " + name)
-	}
-	return filepath.Join(dst, clean), nil
-}
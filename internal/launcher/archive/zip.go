@@ -2,13 +2,12 @@ package archive
 
 import (
 	"archive/zip"
-	"errors"
 	"io"
-	"os"
-	"path/filepath"
-	"strings"
+	"io/fs"
 )
 
+// ExtractZip распаковывает zip; права и ссылки берутся из Unix-атрибутов
+// записей, если архив собран не в Windows.
 func ExtractZip(src, dst string) error {
 	reader, err := zip.OpenReader(src)
 	if err != nil {
@@ -16,35 +15,40 @@ func ExtractZip(src, dst string) error {
 	}
 	defer reader.Close()
 
+	x, err := newExtractor(dst)
+	if err != nil {
+		return err
+	}
 	for _, file := range reader.File {
-		if err := extractFile(file, dst); err != nil {
+		if err := extractFile(x, file); err != nil {
 			return err
 		}
 	}
-	return nil
+	return x.finish()
 }
 
-func extractFile(file *zip.File, dst string) error {
-	if strings.Contains(file.Name, "..") {
-		return errors.New("unsafe zip entry: " + file.Name)
-	}
-	target := filepath.Join(dst, filepath.FromSlash(file.Name))
-	if file.FileInfo().IsDir() {
-		return os.MkdirAll(target, 0o755)
-	}
-	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+func extractFile(x *extractor, file *zip.File) error {
+	target, err := safePath(x.dst, file.Name)
+	if err != nil {
 		return err
 	}
+	mode := file.Mode()
+	if mode.IsDir() {
+		return x.dir(target, mode)
+	}
 	in, err := file.Open()
 	if err != nil {
 		return err
 	}
 	defer in.Close()
-	out, err := os.Create(target)
-	if err != nil {
-		return err
+	if mode&fs.ModeSymlink != 0 {
+		// Содержимое записи-ссылки — её цель.
+		linkTarget, err := io.ReadAll(io.LimitReader(in, 4096))
+		if err != nil {
+			return err
+		}
+		x.symlink(target, string(linkTarget))
+		return nil
 	}
-	defer out.Close()
-	_, err = io.Copy(out, in)
-	return err
+	return x.file(target, mode, in)
 }
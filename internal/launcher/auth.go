@@ -0,0 +1,124 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"shinecore/internal/launcher/config"
+	"shinecore/internal/launcher/server"
+)
+
+// Login входит в аккаунт на сервере и сохраняет токены; дальнейшие
+// запросы к серверу идут с токеном аккаунта вместо общего секрета.
+func (l *Launcher) Login(ctx context.Context, username, password string) error {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return errors.New("username and password are required")
+	}
+	serverCfg, err := loadServerProfile()
+	if err != nil {
+		return err
+	}
+	token, err := server.Login(ctx, newHTTPClient(), serverCfg.ServerBaseURL, username, password)
+	if err != nil {
+		if errors.Is(err, server.ErrNotLoggedIn) {
+			return errors.New("invalid username or password")
+		}
+		return err
+	}
+	creds := &config.Credentials{
+		Username:     username,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    token.ExpiresAt,
+	}
+	path, err := config.ProfileCredentialsPath(serverCfg.ID)
+	if err != nil {
+		return err
+	}
+	if err := creds.Save(path); err != nil {
+		return err
+	}
+	slog.Info("launcher: server login", "user", username, "server", serverCfg.ServerBaseURL)
+	return nil
+}
+
+// Logout отзывает сессию на сервере и удаляет сохранённые токены.
+// Локальный выход выполняется, даже если сервер недоступен.
+func (l *Launcher) Logout(ctx context.Context) error {
+	serverCfg, err := loadServerProfile()
+	if err != nil {
+		return err
+	}
+	path, err := config.ProfileCredentialsPath(serverCfg.ID)
+	if err != nil {
+		return err
+	}
+	creds, err := config.LoadCredentials(path)
+	if err != nil || creds == nil {
+		return config.RemoveCredentials(path)
+	}
+	if err := server.Logout(ctx, newHTTPClient(), serverCfg.ServerBaseURL, creds.RefreshToken); err != nil {
+		slog.Warn("launcher: server logout failed", "error", err)
+	}
+	slog.Info("launcher: server logout", "user", creds.Username)
+	return config.RemoveCredentials(path)
+}
+
+// ServerUser — имя аккаунта на сервере выбранного профиля; пусто — входа нет.
+func (l *Launcher) ServerUser() string {
+	serverCfg, err := loadServerProfile()
+	if err != nil {
+		return ""
+	}
+	creds, _ := loadProfileCredentials(serverCfg)
+	if creds == nil {
+		return ""
+	}
+	return creds.Username
+}
+
+func loadProfileCredentials(serverCfg *config.ServerProfile) (*config.Credentials, string) {
+	path, err := config.ProfileCredentialsPath(serverCfg.ID)
+	if err != nil {
+		return nil, ""
+	}
+	creds, err := config.LoadCredentials(path)
+	if err != nil {
+		slog.Warn("launcher: read credentials failed", "profile", serverCfg.ID, "error", err)
+	}
+	return creds, path
+}
+
+// serverAuth выбирает схему авторизации по профилю сервера: hmac — общий
+// секрет, token — аккаунт; без явной схемы токен используется после входа.
+func serverAuth(serverCfg *config.ServerProfile, client *http.Client) server.Authenticator {
+	mode := strings.ToLower(strings.TrimSpace(serverCfg.Auth))
+	if mode == server.AuthHMAC {
+		return server.NewHMACAuth(serverCfg.ServerSecret, serverCfg.SignatureVersion)
+	}
+	creds, path := loadProfileCredentials(serverCfg)
+	if creds == nil {
+		if mode == server.AuthToken {
+			// Запросы завершатся ErrNotLoggedIn до обращения к серверу.
+			return server.NewTokenAuth(serverCfg.ServerBaseURL, client, nil, nil)
+		}
+		return server.NewHMACAuth(serverCfg.ServerSecret, serverCfg.SignatureVersion)
+	}
+	token := &server.Token{AccessToken: creds.AccessToken, RefreshToken: creds.RefreshToken, ExpiresAt: creds.ExpiresAt}
+	username := creds.Username
+	return server.NewTokenAuth(serverCfg.ServerBaseURL, client, token, func(token server.Token) {
+		updated := &config.Credentials{
+			Username:     username,
+			AccessToken:  token.AccessToken,
+			RefreshToken: token.RefreshToken,
+			ExpiresAt:    token.ExpiresAt,
+		}
+		if err := updated.Save(path); err != nil {
+			slog.Warn("launcher: save refreshed token failed", "error", err)
+		}
+	})
+}
@@ -5,23 +5,74 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 )
 
 const (
 	defaultInstallDirName = "shinecore"
 	configFileName        = "launcher.json"
 	defaultServerBaseURL  = "https://api.be-sunshainy.ru"
+	defaultInstanceID     = "default"
+	defaultProfileID      = "default"
+	defaultInstanceName   = "ShineCore"
+	defaultMemoryMB       = 4096
+	minMemoryMB           = 512
+
+	defaultManifestMaxAge   = 10 * time.Minute
+	defaultManifestMaxStale = 14 * 24 * time.Hour
+)
+
+var (
+	ErrInstanceNotFound = errors.New("instance not found")
+	ErrInstanceExists   = errors.New("instance already exists")
+	ErrProfileNotFound  = errors.New("server profile not found")
+
+	instanceIDRe = regexp.MustCompile(`[^a-z0-9_-]+`)
 )
 
+// Config хранит общие настройки лаунчера и реестр игровых сборок.
+// InstallDir — общий корень для versions, libraries, assets и java,
+// которые переиспользуются всеми сборками.
 type Config struct {
-	InstallDir    string `json:"install_dir"`
-	GameVersion   string `json:"game_version"`
-	Loader        string `json:"loader"`         // fabric|forge|neoforge
-	LoaderVersion string `json:"loader_version"` // optional for latest
+	InstallDir string `json:"install_dir"`
+	// StoreDir — общее хранилище файлов по хешу; не зависит от InstallDir,
+	// чтобы переиспользоваться при смене папки установки.
+	StoreDir string `json:"store_dir,omitempty"`
+
+	// Устаревшие поля одиночной установки; переносятся в сборку "default".
+	GameVersion   string `json:"game_version,omitempty"`
+	Loader        string `json:"loader,omitempty"`
+	LoaderVersion string `json:"loader_version,omitempty"`
 
-	MemoryMB       int  `json:"memory_mb"`
+	MemoryMB       int  `json:"memory_mb"` // значение по умолчанию для новых сборок
 	ConsoleEnabled bool `json:"console_enabled"`
+	// DeepVerify — при каждой проверке хешировать файлы целиком, не доверяя
+	// индексу по размеру и mtime.
+	DeepVerify bool `json:"deep_verify,omitempty"`
+	// AllowUnverifiedJava — ставить архивы Java из java_urls манифеста без
+	// sha256. По умолчанию такие архивы не используются.
+	AllowUnverifiedJava bool `json:"allow_unverified_java,omitempty"`
+
+	Instances        []Instance `json:"instances"`
+	SelectedInstance string     `json:"selected_instance"`
+}
+
+// Instance — именованная сборка со своей игровой папкой (моды, миры, конфиги)
+// и собственным источником манифеста, загрузчиком и настройками JVM.
+type Instance struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Dir           string   `json:"dir"`
+	ManifestURL   string   `json:"manifest_url,omitempty"` // пусто — <server>/manifest
+	GameVersion   string   `json:"game_version"`
+	Loader        string   `json:"loader"`         // fabric|forge|neoforge
+	LoaderVersion string   `json:"loader_version"` // optional for latest
+	MemoryMB      int      `json:"memory_mb"`
+	JVMArgs       []string `json:"jvm_args,omitempty"`
+	// JavaPath — Java, выбранная игроком для сборки; пусто — выбирает лаунчер.
+	JavaPath string `json:"java_path,omitempty"`
 }
 
 func DefaultInstallDir() (string, error) {
@@ -32,6 +83,14 @@ func DefaultInstallDir() (string, error) {
 	return filepath.Join(dir, defaultInstallDirName), nil
 }
 
+func DefaultStoreDir() (string, error) {
+	base, err := DefaultInstallDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "store"), nil
+}
+
 func ConfigPath() (string, error) {
 	base, err := DefaultInstallDir()
 	if err != nil {
@@ -48,6 +107,27 @@ func ServerConfigPath() (string, error) {
 	return filepath.Join(base, "server.json"), nil
 }
 
+func CredentialsPath() (string, error) {
+	base, err := DefaultInstallDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "credentials.json"), nil
+}
+
+// ProfileCredentialsPath — вход в аккаунт своего сервера для каждого профиля;
+// профиль "default" использует прежний credentials.json.
+func ProfileCredentialsPath(profileID string) (string, error) {
+	if profileID == "" || profileID == defaultProfileID {
+		return CredentialsPath()
+	}
+	base, err := DefaultInstallDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "credentials-"+profileID+".json"), nil
+}
+
 func ProfilePath() (string, error) {
 	base, err := DefaultInstallDir()
 	if err != nil {
@@ -107,24 +187,199 @@ func (c *Config) applyDefaults() (*Config, error) {
 		}
 		c.InstallDir = installDir
 	}
-	if c.MemoryMB <= 0 {
-		c.MemoryMB = 4096
+	if strings.TrimSpace(c.StoreDir) == "" {
+		storeDir, err := DefaultStoreDir()
+		if err != nil {
+			return nil, err
+		}
+		c.StoreDir = storeDir
+	}
+	c.MemoryMB = clampMemory(c.MemoryMB)
+
+	// Миграция со старого формата: единственная установка становится сборкой
+	// "default" в прежней папке, чтобы миры и настройки остались на месте.
+	if len(c.Instances) == 0 {
+		c.Instances = append(c.Instances, Instance{
+			ID:            defaultInstanceID,
+			Name:          defaultInstanceName,
+			Dir:           c.InstallDir,
+			GameVersion:   c.GameVersion,
+			Loader:        c.Loader,
+			LoaderVersion: c.LoaderVersion,
+			MemoryMB:      c.MemoryMB,
+		})
+	}
+	c.GameVersion = ""
+	c.Loader = ""
+	c.LoaderVersion = ""
+
+	seen := map[string]struct{}{}
+	for i := range c.Instances {
+		inst := &c.Instances[i]
+		if err := c.normalizeInstance(inst); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[inst.ID]; ok {
+			return nil, errors.New("duplicate instance id: " + inst.ID)
+		}
+		seen[inst.ID] = struct{}{}
+	}
+	if _, ok := seen[c.SelectedInstance]; !ok {
+		c.SelectedInstance = c.Instances[0].ID
+	}
+	return c, nil
+}
+
+func (c *Config) normalizeInstance(inst *Instance) error {
+	inst.ID = instanceID(inst.ID)
+	if inst.ID == "" {
+		inst.ID = instanceID(inst.Name)
+	}
+	if inst.ID == "" {
+		return errors.New("instance id required")
 	}
-	if c.MemoryMB < 512 {
-		c.MemoryMB = 512
+	if strings.TrimSpace(inst.Name) == "" {
+		inst.Name = inst.ID
 	}
-	c.Loader = strings.ToLower(strings.TrimSpace(c.Loader))
-	switch c.Loader {
+	if strings.TrimSpace(inst.Dir) == "" {
+		inst.Dir = filepath.Join(c.InstallDir, "instances", inst.ID)
+	}
+	if inst.MemoryMB <= 0 {
+		inst.MemoryMB = c.MemoryMB
+	}
+	inst.MemoryMB = clampMemory(inst.MemoryMB)
+	inst.ManifestURL = strings.TrimSpace(inst.ManifestURL)
+	inst.Loader = strings.ToLower(strings.TrimSpace(inst.Loader))
+	switch inst.Loader {
 	case "", "fabric", "forge", "neoforge":
 	default:
-		return nil, errors.New("unsupported loader: " + c.Loader)
+		return errors.New("unsupported loader: " + inst.Loader)
 	}
-	return c, nil
+	return nil
 }
 
-type ServerConfig struct {
+// Instance возвращает сборку по ID; пустой ID означает выбранную сборку.
+// Указатель ссылается на элемент c.Instances и действителен до изменения реестра.
+func (c *Config) Instance(id string) (*Instance, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		id = c.SelectedInstance
+	}
+	for i := range c.Instances {
+		if c.Instances[i].ID == id {
+			return &c.Instances[i], nil
+		}
+	}
+	return nil, ErrInstanceNotFound
+}
+
+// AddInstance регистрирует новую сборку. ID выводится из имени, если не задан.
+func (c *Config) AddInstance(inst Instance) (*Instance, error) {
+	if err := c.normalizeInstance(&inst); err != nil {
+		return nil, err
+	}
+	if _, err := c.Instance(inst.ID); err == nil {
+		return nil, ErrInstanceExists
+	}
+	c.Instances = append(c.Instances, inst)
+	return &c.Instances[len(c.Instances)-1], nil
+}
+
+// RemoveInstance убирает сборку из реестра. Файлы сборки на диске не удаляются.
+func (c *Config) RemoveInstance(id string) error {
+	for i := range c.Instances {
+		if c.Instances[i].ID != id {
+			continue
+		}
+		if len(c.Instances) == 1 {
+			return errors.New("cannot remove the last instance")
+		}
+		c.Instances = append(c.Instances[:i], c.Instances[i+1:]...)
+		if c.SelectedInstance == id {
+			c.SelectedInstance = c.Instances[0].ID
+		}
+		return nil
+	}
+	return ErrInstanceNotFound
+}
+
+func (c *Config) SelectInstance(id string) error {
+	inst, err := c.Instance(id)
+	if err != nil {
+		return err
+	}
+	c.SelectedInstance = inst.ID
+	return nil
+}
+
+func instanceID(raw string) string {
+	id := strings.ToLower(strings.TrimSpace(raw))
+	id = instanceIDRe.ReplaceAllString(id, "-")
+	return strings.Trim(id, "-")
+}
+
+func clampMemory(value int) int {
+	if value <= 0 {
+		return defaultMemoryMB
+	}
+	if value < minMemoryMB {
+		return minMemoryMB
+	}
+	return value
+}
+
+// ServerProfile — сервер сборок: адрес, авторизация, ключи подписи и канал.
+type ServerProfile struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
 	ServerBaseURL string `json:"server_base_url"`
 	ServerSecret  string `json:"server_secret"`
+	// Auth — схема авторизации: "hmac" (общий секрет) или "token" (аккаунт).
+	// Пусто — token после входа, иначе hmac.
+	Auth string `json:"auth,omitempty"`
+	// SignatureVersion — версия HMAC-подписи (1 или 2); 0 — согласовать с сервером.
+	SignatureVersion int `json:"signature_version,omitempty"`
+	// ManifestPublicKeys — закреплённые открытые ключи подписи манифеста
+	// (Ed25519 в base64 или ключи minisign) в дополнение к встроенному.
+	ManifestPublicKeys []string `json:"manifest_public_keys,omitempty"`
+	// Channel — канал сборки (stable, beta, dev); пусто — канал сервера по умолчанию.
+	Channel string `json:"channel,omitempty"`
+	// ManifestMaxAgeMinutes — сколько минут сохранённый манифест считается
+	// свежим без запроса к серверу, если сервер не задал Cache-Control: max-age.
+	// 0 — 10 минут.
+	ManifestMaxAgeMinutes int `json:"manifest_max_age_minutes,omitempty"`
+	// ManifestMaxStaleDays — сколько дней можно играть на сохранённом
+	// манифесте, пока сервер недоступен. 0 — 14 дней.
+	ManifestMaxStaleDays int `json:"manifest_max_stale_days,omitempty"`
+}
+
+// ManifestMaxAge — время свежести сохранённого манифеста.
+func (p *ServerProfile) ManifestMaxAge() time.Duration {
+	if p.ManifestMaxAgeMinutes > 0 {
+		return time.Duration(p.ManifestMaxAgeMinutes) * time.Minute
+	}
+	return defaultManifestMaxAge
+}
+
+// ManifestMaxStale — предельный возраст сохранённого манифеста без связи с сервером.
+func (p *ServerProfile) ManifestMaxStale() time.Duration {
+	if p.ManifestMaxStaleDays > 0 {
+		return time.Duration(p.ManifestMaxStaleDays) * 24 * time.Hour
+	}
+	return defaultManifestMaxStale
+}
+
+// ServerConfig — реестр профилей серверов и выбранный профиль.
+type ServerConfig struct {
+	// Устаревшие поля одиночного сервера; переносятся в профиль "default".
+	ServerBaseURL      string   `json:"server_base_url,omitempty"`
+	ServerSecret       string   `json:"server_secret,omitempty"`
+	Auth               string   `json:"auth,omitempty"`
+	SignatureVersion   int      `json:"signature_version,omitempty"`
+	ManifestPublicKeys []string `json:"manifest_public_keys,omitempty"`
+
+	Profiles        []ServerProfile `json:"profiles"`
+	SelectedProfile string          `json:"selected_profile"`
 }
 
 func LoadServer(path string) (*ServerConfig, error) {
@@ -142,18 +397,132 @@ func LoadServer(path string) (*ServerConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return cfg, nil
+			return cfg.applyDefaults()
 		}
 		return nil, err
 	}
 	if err := json.Unmarshal(data, cfg); err != nil {
 		return nil, err
 	}
-	cfg.ServerBaseURL = normalizeServerBaseURL(cfg.ServerBaseURL)
-	if strings.TrimSpace(cfg.ServerBaseURL) == "" {
-		cfg.ServerBaseURL = defaultServerBaseURL
+	return cfg.applyDefaults()
+}
+
+func (c *ServerConfig) applyDefaults() (*ServerConfig, error) {
+	// Миграция со старого формата: единственный сервер становится профилем "default".
+	if len(c.Profiles) == 0 {
+		profile := ServerProfile{
+			ID:                 defaultProfileID,
+			Name:               defaultInstanceName,
+			ServerBaseURL:      c.ServerBaseURL,
+			ServerSecret:       c.ServerSecret,
+			Auth:               c.Auth,
+			SignatureVersion:   c.SignatureVersion,
+			ManifestPublicKeys: c.ManifestPublicKeys,
+		}
+		if strings.TrimSpace(profile.ServerBaseURL) == "" {
+			profile.ServerBaseURL = defaultServerBaseURL
+		}
+		c.Profiles = append(c.Profiles, profile)
+	}
+	c.ServerBaseURL = ""
+	c.ServerSecret = ""
+	c.Auth = ""
+	c.SignatureVersion = 0
+	c.ManifestPublicKeys = nil
+
+	seen := map[string]struct{}{}
+	for i := range c.Profiles {
+		profile := &c.Profiles[i]
+		if err := normalizeProfile(profile); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[profile.ID]; ok {
+			return nil, errors.New("duplicate server profile id: " + profile.ID)
+		}
+		seen[profile.ID] = struct{}{}
+	}
+	if _, ok := seen[c.SelectedProfile]; !ok {
+		c.SelectedProfile = c.Profiles[0].ID
 	}
-	return cfg, nil
+	return c, nil
+}
+
+func normalizeProfile(profile *ServerProfile) error {
+	profile.ID = instanceID(profile.ID)
+	if profile.ID == "" {
+		profile.ID = instanceID(profile.Name)
+	}
+	if profile.ID == "" {
+		return errors.New("server profile id required")
+	}
+	if strings.TrimSpace(profile.Name) == "" {
+		profile.Name = profile.ID
+	}
+	profile.ServerBaseURL = normalizeServerBaseURL(profile.ServerBaseURL)
+	if profile.ServerBaseURL == "" {
+		return errors.New("server url required for profile " + profile.ID)
+	}
+	profile.Channel = strings.ToLower(strings.TrimSpace(profile.Channel))
+	return nil
+}
+
+// Profile возвращает профиль по ID; пустой ID — выбранный профиль.
+// Указатель ссылается на элемент c.Profiles и действителен до изменения реестра.
+func (c *ServerConfig) Profile(id string) (*ServerProfile, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		id = c.SelectedProfile
+	}
+	for i := range c.Profiles {
+		if c.Profiles[i].ID == id {
+			return &c.Profiles[i], nil
+		}
+	}
+	return nil, ErrProfileNotFound
+}
+
+// PutProfile добавляет профиль или заменяет профиль с тем же ID.
+// Пустой секрет у существующего профиля сохраняет прежний.
+func (c *ServerConfig) PutProfile(profile ServerProfile) (*ServerProfile, error) {
+	if err := normalizeProfile(&profile); err != nil {
+		return nil, err
+	}
+	if existing, err := c.Profile(profile.ID); err == nil {
+		if profile.ServerSecret == "" {
+			profile.ServerSecret = existing.ServerSecret
+		}
+		*existing = profile
+		return existing, nil
+	}
+	c.Profiles = append(c.Profiles, profile)
+	return &c.Profiles[len(c.Profiles)-1], nil
+}
+
+// RemoveProfile убирает профиль из реестра; последний профиль удалить нельзя.
+func (c *ServerConfig) RemoveProfile(id string) error {
+	for i := range c.Profiles {
+		if c.Profiles[i].ID != id {
+			continue
+		}
+		if len(c.Profiles) == 1 {
+			return errors.New("cannot remove the last server profile")
+		}
+		c.Profiles = append(c.Profiles[:i], c.Profiles[i+1:]...)
+		if c.SelectedProfile == id {
+			c.SelectedProfile = c.Profiles[0].ID
+		}
+		return nil
+	}
+	return ErrProfileNotFound
+}
+
+func (c *ServerConfig) SelectProfile(id string) error {
+	profile, err := c.Profile(id)
+	if err != nil {
+		return err
+	}
+	c.SelectedProfile = profile.ID
+	return nil
 }
 
 func (c *ServerConfig) Save(path string) error {
@@ -229,3 +598,69 @@ func (p *Profile) Save(path string) error {
 	}
 	return os.WriteFile(path, payload, 0o600)
 }
+
+// Credentials — сессия аккаунта на сервере (токенная авторизация).
+type Credentials struct {
+	Username     string    `json:"username"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+}
+
+// LoadCredentials читает сохранённый вход; nil — вход не выполнялся.
+func LoadCredentials(path string) (*Credentials, error) {
+	if strings.TrimSpace(path) == "" {
+		var err error
+		path, err = CredentialsPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	creds := &Credentials{}
+	if err := json.Unmarshal(data, creds); err != nil {
+		return nil, err
+	}
+	if creds.AccessToken == "" {
+		return nil, nil
+	}
+	return creds, nil
+}
+
+func (c *Credentials) Save(path string) error {
+	if strings.TrimSpace(path) == "" {
+		var err error
+		path, err = CredentialsPath()
+		if err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	payload, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, payload, 0o600)
+}
+
+func RemoveCredentials(path string) error {
+	if strings.TrimSpace(path) == "" {
+		var err error
+		path, err = CredentialsPath()
+		if err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
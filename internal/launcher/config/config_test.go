@@ -0,0 +1,139 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeJSON(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMigratesSingleInstall(t *testing.T) {
+	installDir := t.TempDir()
+	path := writeJSON(t, `{
+		"install_dir": "`+filepath.ToSlash(installDir)+`",
+		"store_dir": "`+filepath.ToSlash(filepath.Join(installDir, "store"))+`",
+		"game_version": "1.20.1",
+		"loader": " Forge ",
+		"loader_version": "47.2.0",
+		"memory_mb": 100
+	}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Instances) != 1 {
+		t.Fatalf("Instances = %+v, want one migrated instance", cfg.Instances)
+	}
+	inst := cfg.Instances[0]
+	// Старая установка остаётся в прежней папке, чтобы миры не потерялись.
+	if inst.ID != defaultInstanceID || filepath.Clean(inst.Dir) != filepath.Clean(installDir) {
+		t.Errorf("instance = %q in %q, want %q in %q", inst.ID, inst.Dir, defaultInstanceID, installDir)
+	}
+	if inst.GameVersion != "1.20.1" || inst.Loader != "forge" || inst.LoaderVersion != "47.2.0" {
+		t.Errorf("instance version = %q %q %q, want 1.20.1 forge 47.2.0", inst.GameVersion, inst.Loader, inst.LoaderVersion)
+	}
+	if inst.MemoryMB != minMemoryMB || cfg.MemoryMB != minMemoryMB {
+		t.Errorf("memory = %d/%d, want clamped to %d", inst.MemoryMB, cfg.MemoryMB, minMemoryMB)
+	}
+	if cfg.GameVersion != "" || cfg.Loader != "" || cfg.LoaderVersion != "" {
+		t.Errorf("legacy fields kept: %q %q %q", cfg.GameVersion, cfg.Loader, cfg.LoaderVersion)
+	}
+	if cfg.SelectedInstance != defaultInstanceID {
+		t.Errorf("SelectedInstance = %q, want %q", cfg.SelectedInstance, defaultInstanceID)
+	}
+}
+
+func TestLoadInstances(t *testing.T) {
+	installDir := t.TempDir()
+	prefix := `{"install_dir": "` + filepath.ToSlash(installDir) + `", "store_dir": "` + filepath.ToSlash(installDir) + `", `
+	tests := []struct {
+		name     string
+		data     string
+		wantErr  string
+		selected string
+		dir      string
+	}{
+		{
+			name:     "id from name and default dir",
+			data:     prefix + `"instances": [{"name": "My Pack!"}], "selected_instance": "missing"}`,
+			selected: "my-pack",
+			dir:      filepath.Join(installDir, "instances", "my-pack"),
+		},
+		{
+			name:    "duplicate id",
+			data:    prefix + `"instances": [{"id": "a"}, {"id": "A"}]}`,
+			wantErr: "duplicate instance id: a",
+		},
+		{
+			name:    "unknown loader",
+			data:    prefix + `"instances": [{"id": "a", "loader": "quilt"}]}`,
+			wantErr: "unsupported loader: quilt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeJSON(t, tt.data))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			inst, err := cfg.Instance("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inst.ID != tt.selected || inst.Dir != tt.dir {
+				t.Errorf("selected instance = %q in %q, want %q in %q", inst.ID, inst.Dir, tt.selected, tt.dir)
+			}
+		})
+	}
+}
+
+func TestLoadServerMigratesSingleServer(t *testing.T) {
+	path := writeJSON(t, `{
+		"server_base_url": "mods.example.com",
+		"server_secret": "secret",
+		"auth": "hmac",
+		"signature_version": 2,
+		"manifest_public_keys": ["key"]
+	}`)
+	cfg, err := LoadServer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := cfg.Profile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.ID != defaultProfileID || profile.ServerBaseURL != "https://mods.example.com" || profile.ServerSecret != "secret" ||
+		profile.Auth != "hmac" || profile.SignatureVersion != 2 || len(profile.ManifestPublicKeys) != 1 {
+		t.Errorf("migrated profile = %+v", profile)
+	}
+	if cfg.ServerBaseURL != "" || cfg.ServerSecret != "" || cfg.Auth != "" || cfg.SignatureVersion != 0 || cfg.ManifestPublicKeys != nil {
+		t.Errorf("legacy fields kept: %+v", cfg)
+	}
+	if profile.ManifestMaxAge() != defaultManifestMaxAge || profile.ManifestMaxStale() != defaultManifestMaxStale {
+		t.Errorf("manifest ages = %s/%s, want defaults", profile.ManifestMaxAge(), profile.ManifestMaxStale())
+	}
+
+	// Файла нет — профиль сервера по умолчанию.
+	cfg, err = LoadServer(filepath.Join(t.TempDir(), "absent.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Profiles) != 1 || cfg.Profiles[0].ServerBaseURL != defaultServerBaseURL {
+		t.Errorf("default profiles = %+v", cfg.Profiles)
+	}
+}
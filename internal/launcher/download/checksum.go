@@ -0,0 +1,70 @@
+package download
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"strings"
+	"sync"
+)
+
+const (
+	AlgoSHA1   = "sha1"
+	AlgoSHA256 = "sha256"
+	AlgoSHA512 = "sha512"
+)
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+var (
+	hashesMu sync.RWMutex
+	hashes   = map[string]func() hash.Hash{
+		AlgoSHA1:   sha1.New,
+		AlgoSHA256: sha256.New,
+		AlgoSHA512: sha512.New,
+	}
+)
+
+// Checksum — ожидаемый хеш файла. Пустое значение отключает проверку.
+type Checksum struct {
+	Algo  string
+	Value string
+}
+
+func SHA1(value string) Checksum   { return newChecksum(AlgoSHA1, value) }
+func SHA256(value string) Checksum { return newChecksum(AlgoSHA256, value) }
+func SHA512(value string) Checksum { return newChecksum(AlgoSHA512, value) }
+
+func newChecksum(algo, value string) Checksum {
+	return Checksum{Algo: algo, Value: strings.ToLower(strings.TrimSpace(value))}
+}
+
+func (c Checksum) IsZero() bool {
+	return c.Value == ""
+}
+
+func (c Checksum) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	return c.Algo + ":" + c.Value
+}
+
+// RegisterHash добавляет (или заменяет) алгоритм хеширования.
+func RegisterHash(algo string, fn func() hash.Hash) {
+	hashesMu.Lock()
+	defer hashesMu.Unlock()
+	hashes[strings.ToLower(algo)] = fn
+}
+
+func NewHash(algo string) (hash.Hash, error) {
+	hashesMu.RLock()
+	fn, ok := hashes[strings.ToLower(algo)]
+	hashesMu.RUnlock()
+	if !ok {
+		return nil, errors.New("unsupported hash algorithm: " + algo)
+	}
+	return fn(), nil
+}
@@ -2,9 +2,10 @@ package download
 
 import (
 	"context"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"os"
@@ -15,35 +16,87 @@ import (
 type Progress struct {
 	BytesDownloaded int64
 	BytesTotal      int64
+	// File — имя файла, который качается сейчас (заполняет Scheduler).
+	File string
 }
 
 type ProgressFunc func(Progress)
 
-func EnsureFile(ctx context.Context, client *http.Client, url string, dst string, expectedSize int64, expectedSha256 string, onProgress ProgressFunc) error {
+func EnsureFile(ctx context.Context, client *http.Client, url string, dst string, expectedSize int64, sum Checksum, onProgress ProgressFunc) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return err
 	}
-	return EnsureFileWithRequest(ctx, client, req, dst, expectedSize, expectedSha256, onProgress)
+	return EnsureFileWithRequest(ctx, client, req, dst, expectedSize, sum, onProgress)
 }
 
-func EnsureFileWithRequest(ctx context.Context, client *http.Client, req *http.Request, dst string, expectedSize int64, expectedSha256 string, onProgress ProgressFunc) error {
+// Cache — хранилище файлов по хешу, общее для всех установок (см. пакет store).
+type Cache interface {
+	// Link кладёт объект в dst; false — объекта в кэше нет.
+	Link(algo, hash, dst string) (bool, error)
+	// Put проверяет хеш скачанного файла и забирает его в кэш.
+	Put(algo, hash, src string) error
+}
+
+// EnsureFileCached берёт файл из кэша по хешу, а при промахе скачивает его
+// и кладёт в кэш. Без кэша или без хеша работает как EnsureFile.
+func EnsureFileCached(ctx context.Context, client *http.Client, cache Cache, url string, dst string, expectedSize int64, sum Checksum, onProgress ProgressFunc) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return ensureFileCachedWith(ctx, client, cache, cloneRequest(req), dst, expectedSize, sum, onProgress)
+}
+
+// requestFunc строит запрос на каждую попытку: подписанный запрос
+// с одноразовым nonce нельзя отправить повторно.
+type requestFunc func(ctx context.Context) (*http.Request, error)
+
+func cloneRequest(req *http.Request) requestFunc {
+	return func(ctx context.Context) (*http.Request, error) {
+		cloned := req.Clone(ctx)
+		cloned.Body = nil
+		return cloned, nil
+	}
+}
+
+func ensureFileCachedWith(ctx context.Context, client *http.Client, cache Cache, newRequest requestFunc, dst string, expectedSize int64, sum Checksum, onProgress ProgressFunc) error {
+	if cache == nil || sum.IsZero() {
+		return ensureFileWith(ctx, client, newRequest, dst, expectedSize, sum, onProgress)
+	}
+	if ok, err := cache.Link(sum.Algo, sum.Value, dst); err == nil && ok {
+		VerificationFrom(ctx).record(dst, sum)
+		return nil
+	}
+	if err := ensureFileWith(ctx, client, newRequest, dst, expectedSize, sum, onProgress); err != nil {
+		return err
+	}
+	return cache.Put(sum.Algo, sum.Value, dst)
+}
+
+func EnsureFileWithRequest(ctx context.Context, client *http.Client, req *http.Request, dst string, expectedSize int64, sum Checksum, onProgress ProgressFunc) error {
+	return ensureFileWith(ctx, client, cloneRequest(req), dst, expectedSize, sum, onProgress)
+}
+
+func ensureFileWith(ctx context.Context, client *http.Client, newRequest requestFunc, dst string, expectedSize int64, sum Checksum, onProgress ProgressFunc) error {
 	attempts := 3
 	var lastErr error
 	for attempt := 1; attempt <= attempts; attempt++ {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
-		cloned := req.Clone(ctx)
-		cloned.Body = nil
-		err := ensureFileOnce(ctx, client, cloned, dst, expectedSize, expectedSha256, onProgress)
+		req, err := newRequest(ctx)
+		if err != nil {
+			return err
+		}
+		err = ensureFileOnce(ctx, client, req, dst, expectedSize, sum, onProgress)
 		if err == nil {
 			return nil
 		}
 		lastErr = err
 		// retry on transient errors only
 		var httpErr *httpError
-		if errors.As(err, &httpErr) && httpErr.StatusCode >= 400 && httpErr.StatusCode < 500 {
+		if errors.As(err, &httpErr) && !httpErr.Retryable && httpErr.StatusCode >= 400 && httpErr.StatusCode < 500 {
 			break
 		}
 		if attempt < attempts {
@@ -56,66 +109,126 @@ func EnsureFileWithRequest(ctx context.Context, client *http.Client, req *http.R
 type httpError struct {
 	StatusCode int
 	Status     string
+	Retryable  bool
 }
 
 func (e *httpError) Error() string {
 	return "download failed: " + e.Status
 }
 
-func ensureFileOnce(ctx context.Context, client *http.Client, req *http.Request, dst string, expectedSize int64, expectedSha256 string, onProgress ProgressFunc) error {
-	if ok, _ := checkFile(dst, expectedSize, expectedSha256); ok {
+func ensureFileOnce(ctx context.Context, client *http.Client, req *http.Request, dst string, expectedSize int64, sum Checksum, onProgress ProgressFunc) error {
+	if ok, _ := VerificationFrom(ctx).VerifyFile(dst, expectedSize, sum); ok {
 		return nil
 	}
+	hasher, err := checksumHasher(sum)
+	if err != nil {
+		return err
+	}
 
 	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
 		return err
 	}
 
 	tmp := dst + ".tmp"
-	_ = os.Remove(tmp)
+	offset, partial := resumeOffset(tmp, req.URL.String(), expectedSize, sum)
+	if offset > 0 {
+		setRangeHeaders(req, offset, partial)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// Сервер не поддерживает Range или файл изменился — качаем целиком.
+		offset = 0
+	case http.StatusPartialContent:
+		start, err := contentRangeStart(resp.Header.Get("Content-Range"))
+		if err != nil || start != offset {
+			removePartial(tmp)
+			return errors.New("download: unexpected content-range: " + resp.Header.Get("Content-Range"))
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Частичный файл не совпадает с тем, что на сервере; следующая попытка начнёт заново.
+		removePartial(tmp)
+		return &httpError{StatusCode: resp.StatusCode, Status: resp.Status, Retryable: true}
+	default:
 		return &httpError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
-	out, err := os.Create(tmp)
-	if err != nil {
+	meta := &partialMeta{
+		URL:          req.URL.String(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Size:         expectedSize,
+	}
+	var out *os.File
+	if offset > 0 {
+		out, err = os.OpenFile(tmp, os.O_RDWR, 0o644)
+		if err == nil {
+			if err = hashPrefix(out, hasher, offset); err != nil {
+				out.Close()
+			}
+		}
+		if err != nil {
+			removePartial(tmp)
+			return err
+		}
+	} else {
+		removePartial(tmp)
+		out, err = os.Create(tmp)
+		if err != nil {
+			return err
+		}
+	}
+	if err := savePartialMeta(tmp, meta); err != nil {
+		out.Close()
 		return err
 	}
 
-	hasher := sha256.New()
 	writer := io.MultiWriter(out, hasher)
-	var total int64
+	// Размер без манифеста берём из ответа, чтобы прогресс знал общий объём.
+	reportTotal := expectedSize
+	if reportTotal <= 0 && resp.ContentLength > 0 {
+		reportTotal = offset + resp.ContentLength
+	}
+	total := offset
 	buf := make([]byte, 64*1024)
 	for {
 		n, readErr := resp.Body.Read(buf)
 		if n > 0 {
 			if _, err := writer.Write(buf[:n]); err != nil {
+				_ = out.Close()
 				return err
 			}
 			total += int64(n)
 			if onProgress != nil {
-				onProgress(Progress{BytesDownloaded: total, BytesTotal: expectedSize})
+				onProgress(Progress{BytesDownloaded: total, BytesTotal: reportTotal})
 			}
 		}
 		if readErr == io.EOF {
 			break
 		}
 		if readErr != nil {
+			_ = out.Close()
 			return readErr
 		}
 	}
 
-	if expectedSha256 != "" {
+	if expectedSize > 0 && total != expectedSize {
+		_ = out.Close()
+		removePartial(tmp)
+		return fmt.Errorf("size mismatch: %s: got %d, want %d", filepath.Base(dst), total, expectedSize)
+	}
+	if !sum.IsZero() {
 		got := hex.EncodeToString(hasher.Sum(nil))
-		if got != expectedSha256 {
+		if got != sum.Value {
 			_ = out.Close()
-			return errors.New("sha256 mismatch")
+			removePartial(tmp)
+			return fmt.Errorf("%w: %s %s", ErrChecksumMismatch, sum.Algo, filepath.Base(dst))
 		}
 	}
 	if err := out.Sync(); err != nil {
@@ -125,14 +238,32 @@ func ensureFileOnce(ctx context.Context, client *http.Client, req *http.Request,
 	if err := out.Close(); err != nil {
 		return err
 	}
+	_ = os.Remove(partialMetaPath(tmp))
 	_ = os.Remove(dst)
 	if err := os.Rename(tmp, dst); err != nil {
 		return err
 	}
+	VerificationFrom(ctx).record(dst, sum)
 	return nil
 }
 
-func checkFile(path string, expectedSize int64, expectedSha256 string) (bool, error) {
+// VerifyFile проверяет размер (если > 0) и хеш (если задан) существующего
+// файла с индексом операции ctx (см. WithVerification).
+func VerifyFile(ctx context.Context, path string, expectedSize int64, sum Checksum) (bool, error) {
+	return VerificationFrom(ctx).VerifyFile(path, expectedSize, sum)
+}
+
+func checksumHasher(sum Checksum) (hash.Hash, error) {
+	if sum.IsZero() {
+		return noopHash{}, nil
+	}
+	return NewHash(sum.Algo)
+}
+
+// VerifyFile проверяет размер (если > 0) и хеш (если задан) существующего файла.
+// Файл, не изменившийся с прошлой проверки по индексу, повторно не хешируется,
+// если проверка не глубокая.
+func (v Verification) VerifyFile(path string, expectedSize int64, sum Checksum) (bool, error) {
 	info, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -143,18 +274,40 @@ func checkFile(path string, expectedSize int64, expectedSha256 string) (bool, er
 	if expectedSize > 0 && info.Size() != expectedSize {
 		return false, nil
 	}
-	if expectedSha256 == "" {
+	if sum.IsZero() {
 		return true, nil
 	}
+	idx := v.Index
+	if idx != nil && !v.Deep && idx.lookup(path, info, sum) {
+		return true, nil
+	}
+	hasher, err := NewHash(sum.Algo)
+	if err != nil {
+		return false, err
+	}
 	file, err := os.Open(path)
 	if err != nil {
 		return false, err
 	}
 	defer file.Close()
-	hasher := sha256.New()
 	if _, err := io.Copy(hasher, file); err != nil {
 		return false, err
 	}
 	got := hex.EncodeToString(hasher.Sum(nil))
-	return got == expectedSha256, nil
+	if got != sum.Value {
+		return false, nil
+	}
+	if idx != nil {
+		idx.record(path, info, sum)
+	}
+	return true, nil
 }
+
+// noopHash используется, когда контрольная сумма не задана.
+type noopHash struct{}
+
+func (noopHash) Write(p []byte) (int, error) { return len(p), nil }
+func (noopHash) Sum(b []byte) []byte         { return b }
+func (noopHash) Reset()                      {}
+func (noopHash) Size() int                   { return 0 }
+func (noopHash) BlockSize() int              { return 1 }
@@ -0,0 +1,150 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Index — сохраняемый индекс проверенных файлов: путь -> размер, mtime и
+// хеши. Пока размер и mtime файла совпадают с записью, хеш не пересчитывается:
+// проверка большой сборки при запуске не перечитывает каждый мод.
+type Index struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]indexEntry
+	dirty   bool
+}
+
+type indexEntry struct {
+	Size    int64 `json:"size"`
+	ModTime int64 `json:"mtime"`
+	// Hashes — алгоритм -> хеш, под которыми файл уже проверялся.
+	Hashes map[string]string `json:"hashes"`
+}
+
+// Verification — индекс и режим проверки файлов одной операции. Нулевое
+// значение хеширует каждый файл и ничего не запоминает.
+type Verification struct {
+	Index *Index
+	// Deep — записям индекса не доверяем, каждый файл хешируется заново
+	// (результат всё равно записывается в индекс).
+	Deep bool
+}
+
+type verificationKey struct{}
+
+// WithVerification передаёт операции индекс проверенных файлов: его
+// используют EnsureFile, VerifyFile и задания планировщика с этим контекстом.
+func WithVerification(ctx context.Context, v Verification) context.Context {
+	return context.WithValue(ctx, verificationKey{}, v)
+}
+
+// VerificationFrom — индекс и режим проверки операции ctx.
+func VerificationFrom(ctx context.Context) Verification {
+	v, _ := ctx.Value(verificationKey{}).(Verification)
+	return v
+}
+
+// OpenIndex читает индекс; отсутствующий или повреждённый файл — пустой индекс.
+func OpenIndex(path string) (*Index, error) {
+	idx := &Index{path: path, entries: map[string]indexEntry{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return idx, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &idx.entries); err != nil {
+		slog.Warn("download: file index is corrupt, starting over", "error", err)
+		idx.entries = map[string]indexEntry{}
+	}
+	return idx, nil
+}
+
+// Flush сохраняет индекс, если он менялся.
+func (x *Index) Flush() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if !x.dirty {
+		return nil
+	}
+	// Записи удалённых файлов не нужны: путь всё равно перепроверится.
+	for path := range x.entries {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(x.entries, path)
+		}
+	}
+	data, err := json.Marshal(x.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(x.path), 0o755); err != nil {
+		return err
+	}
+	tmp := x.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	_ = os.Remove(x.path)
+	if err := os.Rename(tmp, x.path); err != nil {
+		return err
+	}
+	x.dirty = false
+	return nil
+}
+
+// lookup — файл уже проверялся с этим хешем и с тех пор не менялся.
+func (x *Index) lookup(path string, info os.FileInfo, sum Checksum) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	entry, ok := x.entries[indexKey(path)]
+	return ok && entry.Size == info.Size() && entry.ModTime == info.ModTime().UnixNano() &&
+		entry.Hashes[sum.Algo] == sum.Value
+}
+
+// record запоминает хеш файла. info — состояние файла до хеширования:
+// если файл менялся во время чтения, его mtime уже не совпадёт с записью.
+func (x *Index) record(path string, info os.FileInfo, sum Checksum) {
+	if sum.IsZero() {
+		return
+	}
+	key := indexKey(path)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	entry, ok := x.entries[key]
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		entry = indexEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	}
+	if entry.Hashes == nil {
+		entry.Hashes = map[string]string{}
+	}
+	if entry.Hashes[sum.Algo] == sum.Value {
+		return
+	}
+	entry.Hashes[sum.Algo] = sum.Value
+	x.entries[key] = entry
+	x.dirty = true
+}
+
+func indexKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// record добавляет в индекс операции файл, хеш которого только что проверен.
+func (v Verification) record(path string, sum Checksum) {
+	if v.Index == nil || sum.IsZero() {
+		return
+	}
+	if info, err := os.Stat(path); err == nil {
+		v.Index.record(path, info, sum)
+	}
+}
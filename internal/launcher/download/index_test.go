@@ -0,0 +1,62 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestVerificationPerOperation — индекс и глубина проверки задаются
+// операцией: одновременные операции с одним индексом не влияют друг на друга.
+func TestVerificationPerOperation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mod.jar")
+	original := []byte("original content")
+	sum := sha256.Sum256(original)
+	checksum := SHA256(hex.EncodeToString(sum[:]))
+	if err := os.WriteFile(path, original, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := OpenIndex(filepath.Join(dir, "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shallow := WithVerification(context.Background(), Verification{Index: idx})
+	if ok, err := VerifyFile(shallow, path, 0, checksum); err != nil || !ok {
+		t.Fatalf("VerifyFile() = %v, %v; want true", ok, err)
+	}
+
+	// Подмена содержимого с тем же размером и mtime индекс не замечает.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("tampered content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := info.ModTime()
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{name: "indexed operation", ctx: shallow, want: true},
+		{name: "deep operation", ctx: WithVerification(context.Background(), Verification{Index: idx, Deep: true}), want: false},
+		{name: "operation without index", ctx: context.Background(), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := VerifyFile(tt.ctx, path, 0, checksum)
+			if err != nil || ok != tt.want {
+				t.Fatalf("VerifyFile() = %v, %v; want %v", ok, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEnsureFileResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 8192)
+	sum := sha256.Sum256(content)
+	checksum := SHA256(hex.EncodeToString(sum[:]))
+	half := int64(len(content) / 2)
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		partial   []byte
+		meta      *partialMeta
+		etag      string
+		checksum  Checksum
+		wantRange string
+		wantErr   error
+	}{
+		{
+			name:      "resume with matching etag",
+			partial:   content[:half],
+			meta:      &partialMeta{ETag: `"v1"`},
+			etag:      `"v1"`,
+			checksum:  checksum,
+			wantRange: "bytes=65536-",
+		},
+		{
+			name:      "resume by last-modified",
+			partial:   content[:half],
+			meta:      &partialMeta{LastModified: modified.Format(http.TimeFormat)},
+			checksum:  checksum,
+			wantRange: "bytes=65536-",
+		},
+		{
+			name:      "file changed on server",
+			partial:   []byte(strings.Repeat("x", int(half))),
+			meta:      &partialMeta{ETag: `"v0"`},
+			etag:      `"v1"`,
+			checksum:  checksum,
+			wantRange: "bytes=65536-",
+		},
+		{
+			name:     "partial from another url",
+			partial:  content[:half],
+			meta:     &partialMeta{URL: "http://other/file", ETag: `"v1"`},
+			etag:     `"v1"`,
+			checksum: checksum,
+		},
+		{
+			name:    "no validators and no checksum",
+			partial: content[:half],
+			meta:    &partialMeta{},
+		},
+		{
+			name:      "corrupt prefix fails the checksum",
+			partial:   []byte(strings.Repeat("x", int(half))),
+			meta:      &partialMeta{ETag: `"v1"`},
+			etag:      `"v1"`,
+			checksum:  checksum,
+			wantRange: "bytes=65536-",
+			wantErr:   ErrChecksumMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var gotRange string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				gotRange = r.Header.Get("Range")
+				mu.Unlock()
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				http.ServeContent(w, r, "file.bin", modified, bytes.NewReader(content))
+			}))
+			defer srv.Close()
+
+			dst := filepath.Join(t.TempDir(), "file.bin")
+			tmp := dst + ".tmp"
+			if err := os.WriteFile(tmp, tt.partial, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.meta.URL == "" {
+				tt.meta.URL = srv.URL + "/file.bin"
+			}
+			if err := savePartialMeta(tmp, tt.meta); err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/file.bin", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ensureFileOnce(context.Background(), srv.Client(), req, dst, int64(len(content)), tt.checksum, nil)
+			if gotRange != tt.wantRange {
+				t.Errorf("Range = %q, want %q", gotRange, tt.wantRange)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ensureFileOnce() error = %v, want %v", err, tt.wantErr)
+				}
+				if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+					t.Error("corrupt partial file was kept")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ensureFileOnce() error = %v", err)
+			}
+			data, err := os.ReadFile(dst)
+			if err != nil || !bytes.Equal(data, content) {
+				t.Fatalf("downloaded %d bytes, %v; want the original %d bytes", len(data), err, len(content))
+			}
+			for _, leftover := range []string{tmp, partialMetaPath(tmp)} {
+				if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+					t.Errorf("%s was not removed", filepath.Base(leftover))
+				}
+			}
+		})
+	}
+}
+
+func TestContentRangeStart(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int64
+		ok     bool
+	}{
+		{header: "bytes 100-199/200", want: 100, ok: true},
+		{header: " bytes 0-9/*", want: 0, ok: true},
+		{header: "bytes */200"},
+		{header: "items 1-2/3"},
+		{header: ""},
+	}
+	for _, tt := range tests {
+		got, err := contentRangeStart(tt.header)
+		if (err == nil) != tt.ok || (tt.ok && got != tt.want) {
+			t.Errorf("contentRangeStart(%q) = %d, %v; want %d, ok=%v", tt.header, got, err, tt.want, tt.ok)
+		}
+	}
+}
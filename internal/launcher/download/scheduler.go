@@ -0,0 +1,274 @@
+package download
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	priorityCount
+)
+
+const (
+	defaultMaxConcurrent = 16
+	defaultMaxPerHost    = 8
+)
+
+// Job — один файл для загрузки планировщиком.
+type Job struct {
+	// URL задаёт и источник, и хост для ограничения числа соединений на хост.
+	URL string
+	// Request строит запрос непосредственно перед каждой попыткой загрузки
+	// (например, подписанный запрос к серверу, чтобы подпись не устарела, пока
+	// задание ждёт в очереди). Если не задан, выполняется GET по URL.
+	Request  func(ctx context.Context) (*http.Request, error)
+	Dst      string
+	Size     int64
+	Checksum Checksum
+	Cache    Cache
+	Priority Priority
+}
+
+type SchedulerOptions struct {
+	MaxConcurrent int
+	MaxPerHost    int
+	// OnProgress получает суммарный прогресс по байтам всех заданий планировщика.
+	OnProgress ProgressFunc
+}
+
+// Scheduler — общий планировщик загрузок для всех фаз установки. Ограничивает
+// число одновременных загрузок глобально и для каждого хоста, выбирает задания
+// по приоритету и считает суммарный прогресс в байтах.
+type Scheduler struct {
+	client *http.Client
+	opts   SchedulerOptions
+
+	mu      sync.Mutex
+	queues  [priorityCount][]*task
+	perHost map[string]int
+	running int
+	// inflight — задания, поставленные в очередь или выполняемые, по пути
+	// назначения. Повторные задания с тем же Dst не запускаются параллельно
+	// (они писали бы в одни и те же .tmp и .tmp.json), а ждут результата первого.
+	inflight map[string]*flight
+
+	bytesDone  atomic.Int64
+	bytesTotal atomic.Int64
+}
+
+type task struct {
+	job   Job
+	host  string
+	group *Group
+}
+
+// flight — выполняемое задание и ожидающие его задания с тем же Dst.
+type flight struct {
+	waiters []*task
+}
+
+func NewScheduler(client *http.Client, opts SchedulerOptions) *Scheduler {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if opts.MaxConcurrent <= 0 {
+		opts.MaxConcurrent = defaultMaxConcurrent
+	}
+	if opts.MaxPerHost <= 0 {
+		opts.MaxPerHost = defaultMaxPerHost
+	}
+	return &Scheduler{client: client, opts: opts, perHost: map[string]int{}, inflight: map[string]*flight{}}
+}
+
+// Progress возвращает суммарный прогресс по всем поставленным заданиям.
+func (s *Scheduler) Progress() Progress {
+	return Progress{BytesDownloaded: s.bytesDone.Load(), BytesTotal: s.bytesTotal.Load()}
+}
+
+// Group — набор заданий одной фазы установки. Первая ошибка отменяет
+// оставшиеся задания группы; Wait дожидается завершения всех.
+type Group struct {
+	s      *Scheduler
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+	onDone func(Job)
+}
+
+// NewGroup создаёт группу заданий. onDone (может быть nil) вызывается после
+// успешной загрузки каждого файла.
+func (s *Scheduler) NewGroup(ctx context.Context, onDone func(Job)) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{s: s, ctx: ctx, cancel: cancel, onDone: onDone}
+}
+
+func (g *Group) Add(job Job) {
+	if job.Priority < PriorityLow || job.Priority >= priorityCount {
+		job.Priority = PriorityNormal
+	}
+	g.wg.Add(1)
+	g.s.submit(&task{job: job, host: jobHost(job), group: g})
+}
+
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
+// finish записывает результат задания группы.
+func (g *Group) finish(job Job, err error) {
+	if err != nil {
+		g.fail(err)
+	} else if g.onDone != nil {
+		g.onDone(job)
+	}
+	g.wg.Done()
+}
+
+func (g *Group) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
+// submit ставит задание в очередь или, если файл с тем же Dst уже
+// загружается, присоединяет его к выполняемому заданию.
+func (s *Scheduler) submit(t *task) {
+	key := filepath.Clean(t.job.Dst)
+	s.mu.Lock()
+	if f, ok := s.inflight[key]; ok {
+		f.waiters = append(f.waiters, t)
+		s.mu.Unlock()
+		return
+	}
+	s.inflight[key] = &flight{}
+	s.queues[t.job.Priority] = append(s.queues[t.job.Priority], t)
+	s.mu.Unlock()
+	s.bytesTotal.Add(t.job.Size)
+	s.dispatch()
+}
+
+// dispatch запускает задания, пока есть свободные слоты.
+func (s *Scheduler) dispatch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.running < s.opts.MaxConcurrent {
+		t := s.nextLocked()
+		if t == nil {
+			return
+		}
+		s.running++
+		s.perHost[t.host]++
+		go s.run(t)
+	}
+}
+
+// nextLocked выбирает задание с наивысшим приоритетом, хост которого не перегружен.
+func (s *Scheduler) nextLocked() *task {
+	for p := priorityCount - 1; p >= PriorityLow; p-- {
+		queue := s.queues[p]
+		for i, t := range queue {
+			if s.perHost[t.host] >= s.opts.MaxPerHost {
+				continue
+			}
+			s.queues[p] = append(queue[:i:i], queue[i+1:]...)
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) run(t *task) {
+	err := s.execute(t)
+	s.mu.Lock()
+	s.running--
+	s.perHost[t.host]--
+	key := filepath.Clean(t.job.Dst)
+	waiters := s.inflight[key].waiters
+	delete(s.inflight, key)
+	s.mu.Unlock()
+	t.group.finish(t.job, err)
+	for _, w := range waiters {
+		// Результат ведущего задания годится, только если ждали тот же файл и
+		// ведущее задание не было отменено вместе со своей группой, а группа
+		// ожидающего ещё жива. Иначе ожидающее задание выполняется само.
+		reuse := w.job.Checksum == t.job.Checksum &&
+			(err == nil || t.group.ctx.Err() == nil || w.group.ctx.Err() != nil)
+		if reuse {
+			w.group.finish(w.job, err)
+		} else {
+			s.submit(w)
+		}
+	}
+	s.dispatch()
+}
+
+func (s *Scheduler) execute(t *task) error {
+	ctx := t.group.ctx
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	job := t.job
+	file := filepath.Base(job.Dst)
+	var reported, discovered int64
+	onProgress := func(p Progress) {
+		// Для заданий без известного размера общий объём узнаём из ответа сервера.
+		if job.Size <= 0 && discovered == 0 && p.BytesTotal > 0 {
+			discovered = p.BytesTotal
+			s.bytesTotal.Add(discovered)
+		}
+		delta := p.BytesDownloaded - reported
+		reported = p.BytesDownloaded
+		s.addBytes(delta, file)
+	}
+	newRequest := requestFunc(job.Request)
+	if newRequest == nil {
+		newRequest = func(ctx context.Context) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, job.URL, nil)
+		}
+	}
+	if err := ensureFileCachedWith(ctx, s.client, job.Cache, newRequest, job.Dst, job.Size, job.Checksum, onProgress); err != nil {
+		// Незавершённое задание не должно оставлять байты в общем прогрессе.
+		s.addBytes(-reported, file)
+		return err
+	}
+	// Файл мог быть уже на месте или взят из кэша — засчитываем его целиком.
+	if job.Size > reported {
+		s.addBytes(job.Size-reported, file)
+	}
+	return nil
+}
+
+func (s *Scheduler) addBytes(delta int64, file string) {
+	if delta == 0 {
+		return
+	}
+	done := s.bytesDone.Add(delta)
+	if s.opts.OnProgress != nil {
+		s.opts.OnProgress(Progress{BytesDownloaded: done, BytesTotal: s.bytesTotal.Load(), File: file})
+	}
+}
+
+func jobHost(job Job) string {
+	if job.URL == "" {
+		return ""
+	}
+	parsed, err := url.Parse(job.URL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
@@ -0,0 +1,105 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSchedulerSharesSameDst(t *testing.T) {
+	content := []byte("shared asset object")
+	sum := sha256.Sum256(content)
+	checksum := SHA256(hex.EncodeToString(sum[:]))
+
+	tests := []struct {
+		name string
+		// cancelFirst отменяет группу первого задания, пока оно загружается.
+		cancelFirst  bool
+		sameGroup    bool
+		wantRequests int32
+		wantFirstErr bool
+	}{
+		{name: "same group", sameGroup: true, wantRequests: 1},
+		{name: "different groups", wantRequests: 1},
+		{name: "leader cancelled", cancelFirst: true, wantRequests: 2, wantFirstErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			started := make(chan struct{}, 2)
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				started <- struct{}{}
+				select {
+				case <-release:
+				case <-r.Context().Done():
+					return
+				}
+				_, _ = w.Write(content)
+			}))
+			defer srv.Close()
+
+			dst := filepath.Join(t.TempDir(), "objects", "ab", "abcdef")
+			s := NewScheduler(srv.Client(), SchedulerOptions{})
+			var mu sync.Mutex
+			var done []string
+			onDone := func(job Job) {
+				mu.Lock()
+				done = append(done, job.URL)
+				mu.Unlock()
+			}
+			ctx1, cancel1 := context.WithCancel(context.Background())
+			defer cancel1()
+			first := s.NewGroup(ctx1, onDone)
+			second := first
+			if !tt.sameGroup {
+				second = s.NewGroup(context.Background(), onDone)
+			}
+			first.Add(Job{URL: srv.URL + "/a", Dst: dst, Size: int64(len(content)), Checksum: checksum})
+			<-started
+			second.Add(Job{URL: srv.URL + "/b", Dst: dst, Size: int64(len(content)), Checksum: checksum})
+			if tt.cancelFirst {
+				cancel1()
+				<-started
+			}
+			close(release)
+
+			firstErr := first.Wait()
+			if err := second.Wait(); err != nil && !tt.sameGroup {
+				t.Fatalf("second group: %v", err)
+			}
+			if (firstErr != nil) != tt.wantFirstErr {
+				t.Fatalf("first group error = %v, want error %v", firstErr, tt.wantFirstErr)
+			}
+			if tt.wantFirstErr && !errors.Is(firstErr, context.Canceled) {
+				t.Fatalf("first group error = %v, want context.Canceled", firstErr)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Fatalf("requests = %d, want %d", got, tt.wantRequests)
+			}
+			wantDone := 2
+			if tt.wantFirstErr {
+				wantDone = 1
+			}
+			if len(done) != wantDone {
+				t.Fatalf("onDone calls = %v, want %d", done, wantDone)
+			}
+			got, err := os.ReadFile(dst)
+			if err != nil || string(got) != string(content) {
+				t.Fatalf("dst = %q, %v", got, err)
+			}
+			if _, err := os.Stat(dst + ".tmp"); !os.IsNotExist(err) {
+				t.Fatalf("temporary file left behind: %v", err)
+			}
+		})
+	}
+}
@@ -21,10 +21,21 @@ const (
 	metaMirrors = "https://maven.fabricmc.net"
 )
 
-func EnsureInstalled(ctx context.Context, baseDir, gameVersion, loaderVersion string, client *http.Client) (string, string, error) {
+type InstallRequest struct {
+	BaseDir       string
+	GameVersion   string
+	LoaderVersion string // пусто — последняя стабильная
+	Client        *http.Client
+	Cache         download.Cache
+	Scheduler     *download.Scheduler
+}
+
+func EnsureInstalled(ctx context.Context, req InstallRequest) (string, string, error) {
+	baseDir, gameVersion, loaderVersion := req.BaseDir, req.GameVersion, req.LoaderVersion
 	if strings.TrimSpace(gameVersion) == "" {
 		return "", "", errors.New("game version is required")
 	}
+	client := req.Client
 	if client == nil {
 		client = http.DefaultClient
 	}
@@ -92,7 +103,11 @@ func EnsureInstalled(ctx context.Context, baseDir, gameVersion, loaderVersion st
 		return "", "", err
 	}
 
-	if err := downloadProfileLibraries(ctx, client, baseDir, meta); err != nil {
+	sched := req.Scheduler
+	if sched == nil {
+		sched = download.NewScheduler(client, download.SchedulerOptions{})
+	}
+	if err := downloadProfileLibraries(ctx, sched, req.Cache, baseDir, meta); err != nil {
 		return "", "", err
 	}
 
@@ -157,40 +172,38 @@ func fetchLatestLoader(ctx context.Context, client *http.Client) (string, error)
 	return "", errors.New("no fabric loader versions")
 }
 
-func downloadProfileLibraries(ctx context.Context, client *http.Client, baseDir string, meta mojang.VersionMetadata) error {
+func downloadProfileLibraries(ctx context.Context, sched *download.Scheduler, cache download.Cache, baseDir string, meta mojang.VersionMetadata) error {
+	group := sched.NewGroup(ctx, nil)
 	for _, lib := range meta.Libraries {
 		if !allowLibrary(lib.Rules) {
 			continue
 		}
 		if lib.Downloads != nil && lib.Downloads.Artifact != nil {
-			if err := downloadLibrary(ctx, client, baseDir, lib.Downloads.Artifact); err != nil {
-				return err
-			}
-		} else {
-			if err := downloadLibraryByName(ctx, client, baseDir, lib.Name, lib.URL); err != nil {
-				return err
-			}
+			group.Add(libraryJob(cache, baseDir, lib.Downloads.Artifact.URL, lib.Downloads.Artifact.Path, lib.Downloads.Artifact.Size, lib.Downloads.Artifact.Sha1))
+			continue
+		}
+		path := libraryPath(lib.Name)
+		if path == "" {
+			continue
+		}
+		url := "https://libraries.minecraft.net/" + path
+		if strings.TrimSpace(lib.URL) != "" {
+			url = strings.TrimRight(lib.URL, "/") + "/" + path
 		}
+		group.Add(libraryJob(cache, baseDir, url, path, lib.Size, lib.Sha1))
 	}
-	return nil
-}
-
-func downloadLibrary(ctx context.Context, client *http.Client, baseDir string, artifact *mojang.LibraryArtifact) error {
-	dst := filepath.Join(baseDir, "libraries", filepath.FromSlash(artifact.Path))
-	return downloadEnsureFile(ctx, client, artifact.URL, dst, artifact.Size)
+	return group.Wait()
 }
 
-func downloadLibraryByName(ctx context.Context, client *http.Client, baseDir, name, baseURL string) error {
-	path := libraryPath(name)
-	if path == "" {
-		return nil
+func libraryJob(cache download.Cache, baseDir, url, path string, size int64, sha1 string) download.Job {
+	return download.Job{
+		URL:      url,
+		Dst:      filepath.Join(baseDir, "libraries", filepath.FromSlash(path)),
+		Size:     size,
+		Checksum: download.SHA1(sha1),
+		Cache:    cache,
+		Priority: download.PriorityHigh,
 	}
-	url := "https://libraries.minecraft.net/" + path
-	if strings.TrimSpace(baseURL) != "" {
-		url = strings.TrimRight(baseURL, "/") + "/" + path
-	}
-	dst := filepath.Join(baseDir, "libraries", filepath.FromSlash(path))
-	return downloadEnsureFile(ctx, client, url, dst, 0)
 }
 
 func libraryPath(name string) string {
@@ -249,7 +262,3 @@ func osName() string {
 		return "linux"
 	}
 }
-
-func downloadEnsureFile(ctx context.Context, client *http.Client, url, dst string, size int64) error {
-	return download.EnsureFile(ctx, client, url, dst, size, "", nil)
-}
@@ -14,10 +14,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
-	"syscall"
 
 	"shinecore/internal/launcher/download"
 	"shinecore/internal/launcher/mojang"
+	"shinecore/internal/system"
 )
 
 type LoaderKind string
@@ -34,6 +34,8 @@ type InstallRequest struct {
 	LoaderVersion string
 	JavaPath     string
 	Client       *http.Client
+	Cache        download.Cache
+	Scheduler    *download.Scheduler
 }
 
 func EnsureInstalled(ctx context.Context, req InstallRequest) (string, error) {
@@ -47,6 +49,10 @@ func EnsureInstalled(ctx context.Context, req InstallRequest) (string, error) {
 	if client == nil {
 		client = http.DefaultClient
 	}
+	sched := req.Scheduler
+	if sched == nil {
+		sched = download.NewScheduler(client, download.SchedulerOptions{})
+	}
 
 	installerURLs := installerURLs(req.LoaderKind, req.LoaderVersion)
 	if len(installerURLs) == 0 {
@@ -57,7 +63,7 @@ func EnsureInstalled(ctx context.Context, req InstallRequest) (string, error) {
 	// Пытаемся загрузить установщик с основного URL, при неудаче - с резервных зеркал
 	var lastErr error
 	for i, url := range installerURLs {
-		err := download.EnsureFile(ctx, client, url, installerPath, 0, "", nil)
+		err := download.EnsureFileCached(ctx, client, req.Cache, url, installerPath, 0, download.SHA1(fetchMavenSha1(ctx, client, url)), nil)
 		if err == nil {
 			if i > 0 {
 				log.Printf("forge: installer downloaded from fallback mirror %d/%d", i+1, len(installerURLs))
@@ -78,7 +84,9 @@ func EnsureInstalled(ctx context.Context, req InstallRequest) (string, error) {
 	if _, err := mojang.EnsureInstalled(ctx, mojang.InstallRequest{
 		BaseDir: req.BaseDir,
 		Version: req.GameVersion,
-		Client:  client,
+		Client:    client,
+		Cache:     req.Cache,
+		Scheduler: sched,
 	}); err != nil {
 		return "", err
 	}
@@ -99,7 +107,7 @@ func EnsureInstalled(ctx context.Context, req InstallRequest) (string, error) {
 	}
 
 	libraries := map[string]string{}
-	if err := downloadInstallerLibraries(ctx, client, installerPath, librariesDir, profile, libraries); err != nil {
+	if err := downloadInstallerLibraries(ctx, sched, req.Cache, installerPath, librariesDir, profile, libraries); err != nil {
 		return "", err
 	}
 
@@ -168,6 +176,32 @@ func installerURLs(kind LoaderKind, version string) []string {
 	}
 }
 
+// fetchMavenSha1 читает контрольную сумму артефакта из соседнего .sha1 файла Maven.
+// Пустая строка — суммы нет, файл скачивается без кэша.
+func fetchMavenSha1(ctx context.Context, client *http.Client, artifactURL string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, artifactURL+".sha1", nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 128))
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 || len(fields[0]) != 40 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
 func buildVersionID(kind LoaderKind, version string) string {
 	return fmt.Sprintf("%s-%s", kind, version)
 }
@@ -232,27 +266,47 @@ func readZipFile(file *zip.File) ([]byte, error) {
 	return io.ReadAll(in)
 }
 
-func downloadInstallerLibraries(ctx context.Context, client *http.Client, installerPath, librariesDir string, profile *InstallProfile, libraries map[string]string) error {
+func downloadInstallerLibraries(ctx context.Context, sched *download.Scheduler, cache download.Cache, installerPath, librariesDir string, profile *InstallProfile, libraries map[string]string) error {
 	reader, err := zip.OpenReader(installerPath)
 	if err != nil {
 		return err
 	}
 	defer reader.Close()
-	for _, lib := range profile.Libraries {
-		path := lib.Downloads.Artifact.Path
-		dst := filepath.Join(librariesDir, filepath.FromSlash(path))
-		libraries[lib.Name.String()] = dst
-		if lib.Downloads.Artifact.URL != "" {
-			if err := download.EnsureFile(ctx, client, lib.Downloads.Artifact.URL, dst, lib.Downloads.Artifact.Size, "", nil); err != nil {
+	group := sched.NewGroup(ctx, nil)
+	// Загрузки идут в планировщике, пока здесь распаковываются встроенные артефакты.
+	extractErr := func() error {
+		for _, lib := range profile.Libraries {
+			path := lib.Downloads.Artifact.Path
+			dst := filepath.Join(librariesDir, filepath.FromSlash(path))
+			libraries[lib.Name.String()] = dst
+			if lib.Downloads.Artifact.URL != "" {
+				artifact := lib.Downloads.Artifact
+				group.Add(download.Job{
+					URL:      artifact.URL,
+					Dst:      dst,
+					Size:     artifact.Size,
+					Checksum: download.SHA1(artifact.Sha1),
+					Cache:    cache,
+					Priority: download.PriorityHigh,
+				})
+				continue
+			}
+			if ok, _ := download.VerifyFile(ctx, dst, lib.Downloads.Artifact.Size, download.SHA1(lib.Downloads.Artifact.Sha1)); ok {
+				continue
+			}
+			if err := extractMavenArtifact(reader, lib.Name, dst); err != nil {
 				return err
 			}
-			continue
-		}
-		if err := extractMavenArtifact(reader, lib.Name, dst); err != nil {
-			return err
+			if ok, err := download.VerifyFile(ctx, dst, lib.Downloads.Artifact.Size, download.SHA1(lib.Downloads.Artifact.Sha1)); err != nil || !ok {
+				return fmt.Errorf("%w: installer artifact %s", download.ErrChecksumMismatch, lib.Name.String())
+			}
 		}
+		return nil
+	}()
+	if err := group.Wait(); err != nil {
+		return err
 	}
-	return nil
+	return extractErr
 }
 
 func extractMavenArtifact(reader *zip.ReadCloser, gav GAV, dst string) error {
@@ -266,6 +320,9 @@ func extractMavenArtifact(reader *zip.ReadCloser, gav GAV, dst string) error {
 			if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
 				return err
 			}
+			// На месте dst может быть жёсткая ссылка на объект хранилища:
+			// пишем новый файл, а не поверх объекта.
+			_ = os.Remove(dst)
 			return os.WriteFile(dst, data, 0o644)
 		}
 	}
@@ -325,6 +382,9 @@ func extractInstallerFile(reader *zip.ReadCloser, entry, dst string) error {
 			if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
 				return err
 			}
+			// На месте dst может быть жёсткая ссылка на объект хранилища:
+			// пишем новый файл, а не поверх объекта.
+			_ = os.Remove(dst)
 			return os.WriteFile(dst, data, 0o644)
 		}
 	}
@@ -366,12 +426,7 @@ func runProcessors(ctx context.Context, javaPath, librariesDir string, profile *
 		}
 		cmd := exec.CommandContext(ctx, path, args...)
 		cmd.Dir = librariesDir
-		if runtime.GOOS == "windows" {
-			cmd.SysProcAttr = &syscall.SysProcAttr{
-				HideWindow:    true,
-				CreationFlags: 0x08000000, // CREATE_NO_WINDOW
-			}
-		}
+		system.HideWindow(cmd)
 		out, err := cmd.CombinedOutput()
 		if err != nil {
 			return fmt.Errorf("processor failed: %s: %w", string(out), err)
@@ -0,0 +1,137 @@
+package launcher
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shinecore/internal/launcher/config"
+	"shinecore/internal/launcher/java"
+	"shinecore/internal/launcher/mojang"
+)
+
+const (
+	javaProbesName = "probes.json"
+	// max32BitHeapMB — больше памяти 32-битная JVM выделить не может.
+	max32BitHeapMB = 2048
+)
+
+// openJavaProbes открывает кэш проверок Java в каталоге Java лаунчера;
+// возвращённая функция сохраняет его. Без файла кэша проверки работают
+// без него.
+func (l *Launcher) openJavaProbes(cfg *config.Config) (*java.ProbeCache, func()) {
+	path := filepath.Join(javaBaseDir(cfg.InstallDir), javaProbesName)
+	l.mu.Lock()
+	cache, ok := l.probes[path]
+	if !ok {
+		var err error
+		if cache, err = java.OpenProbeCache(path); err != nil {
+			l.mu.Unlock()
+			slog.Warn("launcher: open java probe cache failed", "error", err)
+			return nil, func() {}
+		}
+		if l.probes == nil {
+			l.probes = map[string]*java.ProbeCache{}
+		}
+		l.probes[path] = cache
+	}
+	l.mu.Unlock()
+	return cache, func() {
+		if err := cache.Flush(); err != nil {
+			slog.Warn("launcher: save java probe cache failed", "error", err)
+		}
+	}
+}
+
+// checkJavaMemory не даёт запустить 32-битную JVM с кучей больше 2 ГБ:
+// она упала бы сразу при старте.
+func checkJavaMemory(probes *java.ProbeCache, javaPath string, memoryMB int) error {
+	info, err := probes.Probe(javaPath)
+	if err != nil {
+		slog.Warn("launcher: java probe failed", "path", javaPath, "error", err)
+		return nil
+	}
+	if !info.Is64Bit && memoryMB > max32BitHeapMB {
+		return fmt.Errorf("32-bit java cannot allocate %d MB (at most %d MB), use 64-bit java or lower memory: %s", memoryMB, max32BitHeapMB, javaPath)
+	}
+	return nil
+}
+
+// findJava — Java для сборки: выбранная игроком, иначе скачанная
+// лаунчером, иначе подходящая установленная в системе. Пустой путь без
+// ошибки — нужной Java нет.
+func findJava(probes *java.ProbeCache, baseDir string, inst *config.Instance, required mojang.JavaVersion) (string, error) {
+	if pinned := strings.TrimSpace(inst.JavaPath); pinned != "" {
+		info, err := probes.Probe(pinned)
+		if err != nil {
+			return "", fmt.Errorf("java selected for instance does not start: %s: %w", pinned, err)
+		}
+		if required.MajorVersion > 0 && info.Major != required.MajorVersion {
+			slog.Warn("launcher: selected java differs from required", "path", pinned, "major", info.Major, "required", required.MajorVersion)
+		}
+		return pinned, nil
+	}
+	if path := findInstalledJava(probes, baseDir, required); path != "" {
+		return path, nil
+	}
+	if required.MajorVersion > 0 {
+		return findSystemJava(probes, required.MajorVersion), nil
+	}
+	return "", nil
+}
+
+// findSystemJava — установленная в системе Java нужной major-версии и
+// архитектуры лаунчера.
+func findSystemJava(probes *java.ProbeCache, required int) string {
+	for _, rt := range probes.Discover("") {
+		if rt.Major == required && java.ArchMatches(rt.Arch) {
+			slog.Info("launcher: using system java", "path", rt.Path, "version", rt.Version, "source", rt.Source)
+			return rt.Path
+		}
+	}
+	return ""
+}
+
+// JavaRuntimes — вся найденная Java: скачанная лаунчером и установленная
+// в системе.
+func (l *Launcher) JavaRuntimes() ([]java.Runtime, error) {
+	cfg, err := l.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	probes, closeProbes := l.openJavaProbes(cfg)
+	defer closeProbes()
+	return probes.Discover(javaBaseDir(cfg.InstallDir)), nil
+}
+
+// SetInstanceJava закрепляет за сборкой Java: путь к java или каталог JDK.
+// Пустой путь возвращает выбор Java лаунчеру.
+func (l *Launcher) SetInstanceJava(instanceID, path string) (*java.JavaInfo, error) {
+	cfg, inst, err := l.loadInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	probes, closeProbes := l.openJavaProbes(cfg)
+	defer closeProbes()
+	path = strings.TrimSpace(path)
+	var info *java.JavaInfo
+	if path != "" {
+		if stat, err := os.Stat(path); err == nil && stat.IsDir() {
+			if exe := java.Executable(path); exe != "" {
+				path = exe
+			}
+		}
+		info, err = probes.Probe(path)
+		if err != nil {
+			return nil, fmt.Errorf("java does not start: %s: %w", path, err)
+		}
+	}
+	inst.JavaPath = path
+	if err := cfg.Save(l.ConfigPath); err != nil {
+		return nil, err
+	}
+	slog.Info("launcher: instance java set", "instance", inst.ID, "path", path)
+	return info, nil
+}
@@ -0,0 +1,173 @@
+package java
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Где найдена Java.
+const (
+	SourceLauncher = "launcher"
+	SourceJavaHome = "java_home"
+	SourcePath     = "path"
+	SourceSystem   = "system"
+)
+
+// Runtime — найденная Java.
+type Runtime struct {
+	JavaInfo
+	Source string `json:"source"`
+}
+
+// Discover ищет Java в каталоге рантаймов лаунчера (runtimesDir; пусто — не
+// искать), JAVA_HOME, PATH и типичных каталогах установки JDK. Каждая
+// найденная Java проверяется через кэш c; неработающие пропускаются.
+func (c *ProbeCache) Discover(runtimesDir string) []Runtime {
+	var found []Runtime
+	seen := map[string]struct{}{}
+	add := func(path, source string) {
+		if path == "" {
+			return
+		}
+		// Одна и та же Java встречается по ссылкам (/usr/bin/java,
+		// SDKMAN current) и парой java/javaw в одном bin.
+		key := path
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			key = resolved
+		}
+		key = filepath.Dir(filepath.Dir(key))
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		info, err := c.Probe(path)
+		if err != nil {
+			slog.Debug("java: probe failed", "path", path, "error", err)
+			return
+		}
+		found = append(found, Runtime{JavaInfo: *info, Source: source})
+	}
+
+	if runtimesDir != "" {
+		entries, _ := os.ReadDir(runtimesDir)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				add(c.FindInTree(filepath.Join(runtimesDir, entry.Name()), 0), SourceLauncher)
+			}
+		}
+	}
+	if home := strings.TrimSpace(os.Getenv("JAVA_HOME")); home != "" {
+		add(Executable(home), SourceJavaHome)
+	}
+	for _, name := range executableNames() {
+		if path, err := exec.LookPath(name); err == nil {
+			add(path, SourcePath)
+		}
+	}
+	for _, home := range systemHomes() {
+		add(Executable(home), SourceSystem)
+	}
+	return found
+}
+
+// executableNames — имена java по предпочтению: javaw.exe не открывает консоль.
+func executableNames() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"javaw.exe", "java.exe"}
+	}
+	return []string{"java"}
+}
+
+// Executable — java в каталоге JDK/JRE: <home>/bin, у бандлов macOS —
+// Contents/Home/bin, у рантаймов Mojang для macOS — jre.bundle/Contents/Home/bin.
+func Executable(home string) string {
+	if home == "" {
+		return ""
+	}
+	bins := []string{
+		filepath.Join(home, "bin"),
+		filepath.Join(home, "Contents", "Home", "bin"),
+		filepath.Join(home, "jre.bundle", "Contents", "Home", "bin"),
+	}
+	for _, bin := range bins {
+		for _, name := range executableNames() {
+			path := filepath.Join(bin, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// FindInTree ищет java в каталогах bin внутри dir (архивы JDK обычно
+// распакованы с каталогом верхнего уровня); required > 0 — только этой
+// major-версии (проверяется через кэш c).
+func (c *ProbeCache) FindInTree(dir string, required int) string {
+	if dir == "" {
+		return ""
+	}
+	found := ""
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || !strings.EqualFold(d.Name(), "bin") {
+			return nil
+		}
+		exe := Executable(filepath.Dir(path))
+		if exe == "" {
+			return filepath.SkipDir
+		}
+		if required > 0 {
+			if info, err := c.Probe(exe); err != nil || info.Major != required {
+				return filepath.SkipDir
+			}
+		}
+		found = exe
+		return filepath.SkipAll
+	})
+	return found
+}
+
+// systemHomes — каталоги JDK в типичных местах установки для текущей ОС.
+func systemHomes() []string {
+	var patterns []string
+	userHome, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "windows":
+		vendors := []string{"Java", "Eclipse Adoptium", "AdoptOpenJDK", "Zulu", "Microsoft", "Amazon Corretto", "BellSoft", "Semeru"}
+		for _, root := range []string{os.Getenv("ProgramFiles"), os.Getenv("ProgramFiles(x86)")} {
+			if root == "" {
+				continue
+			}
+			for _, vendor := range vendors {
+				patterns = append(patterns, filepath.Join(root, vendor, "*"))
+			}
+		}
+	case "darwin":
+		patterns = append(patterns,
+			"/Library/Java/JavaVirtualMachines/*",
+			"/opt/homebrew/opt/openjdk*/libexec/openjdk.jdk",
+			"/usr/local/opt/openjdk*/libexec/openjdk.jdk",
+		)
+		if userHome != "" {
+			patterns = append(patterns, filepath.Join(userHome, "Library", "Java", "JavaVirtualMachines", "*"))
+		}
+	default:
+		patterns = append(patterns, "/usr/lib/jvm/*", "/usr/lib64/jvm/*", "/usr/java/*", "/opt/java/*", "/opt/jdk*")
+	}
+	if userHome != "" {
+		patterns = append(patterns,
+			filepath.Join(userHome, ".sdkman", "candidates", "java", "*"),
+			filepath.Join(userHome, ".jdks", "*"),
+		)
+	}
+	var homes []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		homes = append(homes, matches...)
+	}
+	return homes
+}
@@ -0,0 +1,118 @@
+package java
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeJDK создаёт каталог JDK с файлом release и пустой java: запускать её
+// не придётся, сведения берутся из release.
+func fakeJDK(t *testing.T, home, version, arch string) string {
+	t.Helper()
+	exe := filepath.Join(home, "bin", executableNames()[0])
+	if err := os.MkdirAll(filepath.Dir(exe), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(exe, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	release := "IMPLEMENTOR=\"Eclipse Adoptium\"\nJAVA_VERSION=\"" + version + "\"\nOS_ARCH=\"" + arch + "\"\n"
+	if err := os.WriteFile(filepath.Join(home, "release"), []byte(release), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return exe
+}
+
+func TestFindInTree(t *testing.T) {
+	dir := t.TempDir()
+	java17 := fakeJDK(t, filepath.Join(dir, "17", "jdk-17.0.12+7"), "17.0.12", "x86_64")
+	java21 := fakeJDK(t, filepath.Join(dir, "21", "jdk-21.0.5+11"), "21.0.5", "aarch64")
+	java8 := fakeJDK(t, filepath.Join(dir, "8", "jdk8u422-b05"), "1.8.0_422", "i386")
+
+	tests := []struct {
+		dir      string
+		required int
+		want     string
+	}{
+		{dir: filepath.Join(dir, "21"), required: 21, want: java21},
+		{dir: filepath.Join(dir, "21"), required: 0, want: java21},
+		{dir: filepath.Join(dir, "21"), required: 17, want: ""},
+		{dir: dir, required: 17, want: java17},
+		{dir: dir, required: 8, want: java8},
+		{dir: filepath.Join(dir, "missing"), required: 0, want: ""},
+		{dir: "", required: 0, want: ""},
+	}
+	for _, tt := range tests {
+		if got := (*ProbeCache)(nil).FindInTree(tt.dir, tt.required); got != tt.want {
+			t.Errorf("FindInTree(%q, %d) = %q, want %q", tt.dir, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestProbeReleaseFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		version string
+		arch    string
+		major   int
+		is64    bool
+		goArch  string
+	}{
+		{version: "21.0.5", arch: "x86_64", major: 21, is64: true, goArch: "amd64"},
+		{version: "17", arch: "aarch64", major: 17, is64: true, goArch: "arm64"},
+		{version: "1.8.0_422", arch: "i386", major: 8, goArch: "386"},
+	}
+	for i, tt := range tests {
+		exe := fakeJDK(t, filepath.Join(dir, tt.version), tt.version, tt.arch)
+		info, err := Probe(exe)
+		if err != nil {
+			t.Fatalf("%d: Probe() error = %v", i, err)
+		}
+		if info.Major != tt.major || info.Is64Bit != tt.is64 || info.Arch != tt.goArch || info.Path != exe || info.Vendor != "Eclipse Adoptium" {
+			t.Errorf("%d: Probe() = %+v, want Java %d %s", i, info, tt.major, tt.goArch)
+		}
+	}
+}
+
+func TestProbeCachePersists(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "probes.json")
+	home := filepath.Join(dir, "jdk")
+	exe := fakeJDK(t, home, "21.0.5", "x86_64")
+
+	cache, err := OpenProbeCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Probe(exe); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Без release java пришлось бы запускать: ответ должен прийти из кэша.
+	if err := os.Remove(filepath.Join(home, "release")); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenProbeCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := reopened.Probe(exe)
+	if err != nil {
+		t.Fatalf("cached Probe() error = %v", err)
+	}
+	if info.Major != 21 || info.Arch != "amd64" {
+		t.Errorf("cached Probe() = %+v, want Java 21 amd64", info)
+	}
+
+	// Изменённый файл java проверяется заново.
+	if err := os.WriteFile(exe, []byte("changed"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Probe(exe); err == nil {
+		t.Error("Probe() trusted the cache after java changed")
+	}
+}
@@ -2,15 +2,28 @@ package java
 
 import (
 	"errors"
+	"os"
 	"os/exec"
 	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
-	"syscall"
+
+	"shinecore/internal/system"
 )
 
-var versionRe = regexp.MustCompile(`version \"([0-9]+)(?:\\.([0-9]+))?`)
+var versionRe = regexp.MustCompile(`version "([^"]+)"`)
+
+// JavaInfo — сведения, которые сообщает о себе java.
+type JavaInfo struct {
+	Path    string `json:"path"`
+	Major   int    `json:"major"`
+	Version string `json:"version"`
+	Vendor  string `json:"vendor,omitempty"`
+	// Arch — архитектура в терминах Go: amd64, arm64, 386.
+	Arch    string `json:"arch,omitempty"`
+	Is64Bit bool   `json:"is_64bit"`
+}
 
 func FindSystemJava() string {
 	if path, err := exec.LookPath("javaw.exe"); err == nil {
@@ -25,28 +38,100 @@ func FindSystemJava() string {
 	return ""
 }
 
-func GetJavaMajor(javaPath string) (int, error) {
+// Probe — проверка java без кэша результатов.
+func Probe(javaPath string) (*JavaInfo, error) {
+	return (*ProbeCache)(nil).Probe(javaPath)
+}
+
+// Probe узнаёт версию, производителя и архитектуру java: из файла release
+// JDK, а без него — запуском java. Результат хранится в кэше, пока файл
+// java не изменится.
+func (c *ProbeCache) Probe(javaPath string) (*JavaInfo, error) {
 	if strings.TrimSpace(javaPath) == "" {
-		return 0, errors.New("java path is empty")
+		return nil, errors.New("java path is empty")
+	}
+	stat, err := os.Stat(javaPath)
+	if err != nil {
+		return nil, err
+	}
+	if info, ok := c.lookup(javaPath, stat); ok {
+		return info, nil
+	}
+	info, ok := fromReleaseFile(javaPath)
+	if !ok {
+		if info, err = probeProcess(javaPath); err != nil {
+			return nil, err
+		}
 	}
+	info.Path = javaPath
+	c.record(javaPath, stat, info)
+	return info, nil
+}
+
+// probeProcess запускает java -XshowSettings:properties -version.
+func probeProcess(javaPath string) (*JavaInfo, error) {
 	// На Windows используем javaw.exe вместо java.exe для скрытия консоли
 	path := javaPath
 	if runtime.GOOS == "windows" {
 		path = strings.ReplaceAll(path, "java.exe", "javaw.exe")
 		path = strings.ReplaceAll(path, "\\bin\\java.exe", "\\bin\\javaw.exe")
 	}
-	cmd := exec.Command(path, "-version")
-	if runtime.GOOS == "windows" {
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			HideWindow:    true,
-			CreationFlags: 0x08000000, // CREATE_NO_WINDOW
-		}
-	}
+	cmd := exec.Command(path, "-XshowSettings:properties", "-version")
+	system.HideWindow(cmd)
 	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, err
+	}
+	return parseProbe(string(output))
+}
+
+func GetJavaMajor(javaPath string) (int, error) {
+	info, err := Probe(javaPath)
 	if err != nil {
 		return 0, err
 	}
-	return ParseJavaMajor(string(output))
+	return info.Major, nil
+}
+
+// parseProbe разбирает вывод -XshowSettings:properties -version; без
+// свойств версия берётся из строки version "...".
+func parseProbe(output string) (*JavaInfo, error) {
+	info := &JavaInfo{}
+	dataModel := ""
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " = ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "java.version":
+			info.Version = strings.TrimSpace(value)
+		case "java.vendor":
+			info.Vendor = strings.TrimSpace(value)
+		case "os.arch":
+			info.Arch = NormalizeArch(value)
+		case "sun.arch.data.model":
+			dataModel = strings.TrimSpace(value)
+		}
+	}
+	if dataModel != "" {
+		info.Is64Bit = dataModel == "64"
+	} else {
+		info.Is64Bit = is64BitArch(info.Arch)
+	}
+	if info.Version == "" {
+		match := versionRe.FindStringSubmatch(output)
+		if len(match) < 2 {
+			return nil, errors.New("java version not found")
+		}
+		info.Version = match[1]
+	}
+	major, err := majorFromVersion(info.Version)
+	if err != nil {
+		return nil, err
+	}
+	info.Major = major
+	return info, nil
 }
 
 func ParseJavaMajor(output string) (int, error) {
@@ -54,16 +139,42 @@ func ParseJavaMajor(output string) (int, error) {
 	if len(match) < 2 {
 		return 0, errors.New("java version not found")
 	}
-	major, err := strconv.Atoi(match[1])
+	return majorFromVersion(match[1])
+}
+
+// majorFromVersion: "17.0.9" -> 17, "21" -> 21, "1.8.0_392" -> 8.
+func majorFromVersion(version string) (int, error) {
+	parts := strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '_' || r == '-' || r == '+' })
+	if len(parts) == 0 {
+		return 0, errors.New("java version not found")
+	}
+	major, err := strconv.Atoi(parts[0])
 	if err != nil {
 		return 0, err
 	}
 	// Java 8 sometimes reports "1.8"
-	if major == 1 && len(match) > 2 {
-		minor, err := strconv.Atoi(match[2])
-		if err == nil {
+	if major == 1 && len(parts) > 1 {
+		if minor, err := strconv.Atoi(parts[1]); err == nil {
 			return minor, nil
 		}
 	}
 	return major, nil
 }
+
+// NormalizeArch переводит os.arch Java в термины Go.
+func NormalizeArch(arch string) string {
+	switch arch = strings.ToLower(strings.TrimSpace(arch)); arch {
+	case "amd64", "x86_64", "x64":
+		return "amd64"
+	case "aarch64", "arm64":
+		return "arm64"
+	case "x86", "i386", "i486", "i586", "i686":
+		return "386"
+	}
+	return arch
+}
+
+// ArchMatches — Java этой архитектуры работает без эмуляции.
+func ArchMatches(arch string) bool {
+	return arch == "" || arch == runtime.GOARCH
+}
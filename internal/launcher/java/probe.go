@@ -0,0 +1,151 @@
+package java
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ProbeCache — сохраняемые результаты Probe: путь к java -> её размер,
+// mtime и сведения о ней. Пока файл java не менялся, она не запускается
+// повторно. Методы nil-кэша проверяют Java без кэша.
+type ProbeCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]probeEntry
+	dirty   bool
+}
+
+type probeEntry struct {
+	Size    int64    `json:"size"`
+	ModTime int64    `json:"mtime"`
+	Info    JavaInfo `json:"info"`
+}
+
+// OpenProbeCache читает кэш; отсутствующий или повреждённый файл — пустой кэш.
+func OpenProbeCache(path string) (*ProbeCache, error) {
+	cache := &ProbeCache{path: path, entries: map[string]probeEntry{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		slog.Warn("java: probe cache is corrupt, starting over", "error", err)
+		cache.entries = map[string]probeEntry{}
+	}
+	return cache, nil
+}
+
+// Flush сохраняет кэш, если он менялся.
+func (c *ProbeCache) Flush() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty || c.path == "" {
+		return nil
+	}
+	for path := range c.entries {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(c.entries, path)
+		}
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	_ = os.Remove(c.path)
+	if err := os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+func (c *ProbeCache) lookup(path string, info os.FileInfo) (*JavaInfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[path]
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		return nil, false
+	}
+	result := entry.Info
+	return &result, true
+}
+
+func (c *ProbeCache) record(path string, info os.FileInfo, result *JavaInfo) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[path] = probeEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Info: *result}
+	c.dirty = true
+}
+
+// fromReleaseFile читает файл release в каталоге JDK (<home>/bin/java ->
+// <home>/release): JAVA_VERSION, IMPLEMENTOR и OS_ARCH. Так java не нужно
+// запускать.
+func fromReleaseFile(javaPath string) (*JavaInfo, bool) {
+	home := filepath.Dir(filepath.Dir(javaPath))
+	file, err := os.Open(filepath.Join(home, "release"))
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+	info := &JavaInfo{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch strings.TrimSpace(key) {
+		case "JAVA_VERSION":
+			info.Version = value
+		case "IMPLEMENTOR":
+			info.Vendor = value
+		case "OS_ARCH":
+			info.Arch = NormalizeArch(value)
+		}
+	}
+	// Без архитектуры не понять разрядность — тогда спрашиваем саму java.
+	if info.Version == "" || info.Arch == "" {
+		return nil, false
+	}
+	major, err := majorFromVersion(info.Version)
+	if err != nil {
+		return nil, false
+	}
+	info.Major = major
+	info.Is64Bit = is64BitArch(info.Arch)
+	return info, true
+}
+
+func is64BitArch(arch string) bool {
+	switch arch {
+	case "amd64", "arm64", "ppc64", "ppc64le", "s390x", "riscv64", "loong64", "mips64", "mips64le":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package java
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"shinecore/internal/launcher/archive"
+	"shinecore/internal/launcher/download"
+)
+
+// RuntimeIndexURL — список рантаймов Java, которые раздаёт Mojang:
+// платформа -> компонент (jre-legacy, java-runtime-gamma, ...) -> сборки.
+const RuntimeIndexURL = "https://piston-meta.mojang.com/v1/products/java-runtime/2ec0cc96c44e5a76b9c8b7c39df7210883d12871/all.json"
+
+// versionMarker — файл в каталоге рантайма с версией полностью
+// установленной сборки; без него каталог считается недокачанным.
+const versionMarker = ".version"
+
+// manifestFile — манифест установленной сборки: по нему проверяются хеши
+// файлов рантайма.
+const manifestFile = ".manifest.json"
+
+// RuntimeIndex — all.json: платформа -> компонент -> сборки.
+type RuntimeIndex map[string]map[string][]RuntimeEntry
+
+// RuntimeEntry — сборка компонента для платформы.
+type RuntimeEntry struct {
+	Manifest RuntimeDownload `json:"manifest"`
+	Version  struct {
+		Name     string `json:"name"`
+		Released string `json:"released"`
+	} `json:"version"`
+}
+
+type RuntimeDownload struct {
+	Sha1 string `json:"sha1"`
+	Size int64  `json:"size"`
+	URL  string `json:"url"`
+}
+
+// RuntimeManifest — файлы рантайма: путь -> файл, каталог или ссылка.
+type RuntimeManifest struct {
+	Files map[string]RuntimeFile `json:"files"`
+}
+
+type RuntimeFile struct {
+	Type       string `json:"type"` // file | directory | link
+	Executable bool   `json:"executable,omitempty"`
+	// Target — цель ссылки относительно её каталога.
+	Target    string `json:"target,omitempty"`
+	Downloads struct {
+		Raw *RuntimeDownload `json:"raw,omitempty"`
+	} `json:"downloads"`
+}
+
+// Platform — ключ платформы в RuntimeIndex для текущей ОС и архитектуры.
+func Platform() string {
+	switch runtime.GOOS + "/" + runtime.GOARCH {
+	case "windows/amd64":
+		return "windows-x64"
+	case "windows/386":
+		return "windows-x86"
+	case "windows/arm64":
+		return "windows-arm64"
+	case "linux/amd64":
+		return "linux"
+	case "linux/386":
+		return "linux-i386"
+	case "darwin/amd64":
+		return "mac-os"
+	case "darwin/arm64":
+		return "mac-os-arm64"
+	}
+	return ""
+}
+
+// FetchRuntimeIndex загружает список рантаймов Mojang.
+func FetchRuntimeIndex(ctx context.Context, client *http.Client) (RuntimeIndex, error) {
+	data, err := fetch(ctx, client, RuntimeIndexURL, 8<<20)
+	if err != nil {
+		return nil, fmt.Errorf("java runtime index: %w", err)
+	}
+	var index RuntimeIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("java runtime index: %w", err)
+	}
+	return index, nil
+}
+
+// Lookup — сборка компонента для платформы.
+func (idx RuntimeIndex) Lookup(platform, component string) (*RuntimeEntry, bool) {
+	entries := idx[platform][component]
+	if len(entries) == 0 {
+		return nil, false
+	}
+	return &entries[0], true
+}
+
+// InstalledRuntime — версия рантайма, полностью установленного в dir.
+func InstalledRuntime(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, versionMarker))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// InstalledManifest — манифест рантайма, сохранённый в dir при установке.
+func InstalledManifest(dir string) (*RuntimeManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var manifest RuntimeManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("java runtime manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// FetchRuntimeManifest загружает манифест сборки и сверяет его sha1 с индексом.
+func FetchRuntimeManifest(ctx context.Context, client *http.Client, entry *RuntimeEntry) (*RuntimeManifest, []byte, error) {
+	data, err := fetch(ctx, client, entry.Manifest.URL, 64<<20)
+	if err != nil {
+		return nil, nil, fmt.Errorf("java runtime manifest: %w", err)
+	}
+	if sum := sha1.Sum(data); entry.Manifest.Sha1 != "" && !strings.EqualFold(hex.EncodeToString(sum[:]), entry.Manifest.Sha1) {
+		return nil, nil, errors.New("java runtime manifest: sha1 mismatch")
+	}
+	var manifest RuntimeManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("java runtime manifest: %w", err)
+	}
+	return &manifest, data, nil
+}
+
+// Path — путь файла манифеста внутри dir.
+func (m *RuntimeManifest) Path(dir, name string) (string, error) {
+	rel := filepath.FromSlash(name)
+	if !filepath.IsLocal(rel) {
+		return "", errors.New("java runtime manifest: unsafe path " + name)
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+// InstallRuntime ставит сборку рантайма в dir: файлы проверяются по sha1,
+// исполняемым ставится бит x, ссылки создаются как в архивах (archive.Symlink).
+func InstallRuntime(ctx context.Context, client *http.Client, sched *download.Scheduler, entry *RuntimeEntry, dir string) error {
+	manifest, data, err := FetchRuntimeManifest(ctx, client, entry)
+	if err != nil {
+		return err
+	}
+	// Незавершённую установку выдаёт отсутствие маркера.
+	_ = os.Remove(filepath.Join(dir, versionMarker))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	group := sched.NewGroup(ctx, nil)
+	for name, file := range manifest.Files {
+		path, err := manifest.Path(dir, name)
+		if err != nil {
+			return err
+		}
+		switch file.Type {
+		case "directory":
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+		case "file":
+			raw := file.Downloads.Raw
+			if raw == nil || raw.URL == "" {
+				return errors.New("java runtime manifest: no download for " + name)
+			}
+			group.Add(download.Job{
+				URL:      raw.URL,
+				Dst:      path,
+				Size:     raw.Size,
+				Checksum: download.SHA1(raw.Sha1),
+				Priority: download.PriorityHigh,
+			})
+		}
+	}
+	if err := group.Wait(); err != nil {
+		return err
+	}
+	if err := manifest.Finish(dir); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), data, 0o644); err != nil {
+		return err
+	}
+	slog.Info("java: runtime installed", "dir", dir, "version", entry.Version.Name, "files", len(manifest.Files))
+	return os.WriteFile(filepath.Join(dir, versionMarker), []byte(entry.Version.Name), 0o644)
+}
+
+// Finish ставит бит x исполняемым файлам и создаёт ссылки. Нужен после
+// загрузки файлов: и при установке, и при починке рантайма.
+func (m *RuntimeManifest) Finish(dir string) error {
+	links := map[string]string{}
+	for name, file := range m.Files {
+		path, err := m.Path(dir, name)
+		if err != nil {
+			return err
+		}
+		switch {
+		case file.Type == "file" && file.Executable:
+			if err := os.Chmod(path, 0o755); err != nil {
+				return err
+			}
+		case file.Type == "link":
+			links[path] = file.Target
+		}
+	}
+	// Ссылки — после прав: без поддержки ссылок цель копируется вместе с ними.
+	for path, target := range links {
+		if err := archive.Symlink(dir, path, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fetch(ctx context.Context, client *http.Client, url string, limit int64) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("request failed: " + resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
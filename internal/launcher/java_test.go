@@ -0,0 +1,38 @@
+package launcher
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"shinecore/internal/launcher/config"
+	"shinecore/internal/launcher/mojang"
+	"shinecore/internal/launcher/server"
+)
+
+func TestEnsureJavaRequiresSha256(t *testing.T) {
+	cfg := &config.Config{InstallDir: t.TempDir()}
+	manifest := &server.Manifest{}
+	manifest.Dependencies.JavaURLs = server.JavaURLs{{
+		Major: 21,
+		OS:    runtime.GOOS,
+		Arch:  runtime.GOARCH,
+		URL:   "http://127.0.0.1:1/jdk.zip",
+	}}
+
+	// Без рантайма Mojang подставить нечего: установка должна упасть, а не
+	// скачать архив без хеша.
+	_, err := ensureJava(context.Background(), nil, nil, nil, nil, cfg, manifest, mojang.JavaVersion{MajorVersion: 21})
+	if err == nil || !strings.Contains(err.Error(), "allow_unverified_java") {
+		t.Fatalf("ensureJava() error = %v, want missing sha256", err)
+	}
+
+	if err := checkJavaArchive(&manifest.Dependencies.JavaURLs[0], true); err != nil {
+		t.Fatalf("checkJavaArchive(allow) = %v", err)
+	}
+	manifest.Dependencies.JavaURLs[0].Sha256 = "abc"
+	if err := checkJavaArchive(&manifest.Dependencies.JavaURLs[0], false); err != nil {
+		t.Fatalf("checkJavaArchive(sha256) = %v", err)
+	}
+}
@@ -13,18 +13,24 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
-	"syscall"
+	"time"
 
 	"shinecore/internal/logging"
 	"shinecore/internal/launcher/mojang"
+	"shinecore/internal/system"
 )
 
 type LaunchRequest struct {
 	BaseDir   string
+	GameDir  string // папка сборки; пусто — BaseDir
 	Version  string
 	Player   PlayerInfo
 	JavaPath string
 	MemoryMB int
+	JVMArgs  []string
+	// OnExit вызывается после завершения процесса игры с ошибкой Wait
+	// (nil — код 0) и временем работы.
+	OnExit func(err error, uptime time.Duration)
 }
 
 type PlayerInfo struct {
@@ -60,16 +66,32 @@ func Launch(ctx context.Context, req LaunchRequest) error {
 	}
 	slog.Info("launcher: java start", "java", javaPath, "args_count", len(args))
 	cmd := exec.CommandContext(ctx, javaPath, args...)
-	cmd.Dir = req.BaseDir
+	cmd.Dir = req.gameDir()
+	if err := os.MkdirAll(cmd.Dir, 0o755); err != nil {
+		return err
+	}
 	cmd.Stdout = logging.Writer()
 	cmd.Stderr = logging.Writer()
-	if runtime.GOOS == "windows" {
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			HideWindow:    true,
-			CreationFlags: 0x08000000, // CREATE_NO_WINDOW
+	system.HideWindow(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	started := time.Now()
+	go func() {
+		err := cmd.Wait()
+		slog.Info("launcher: game exited", "uptime", time.Since(started).Round(time.Second), "error", err)
+		if req.OnExit != nil {
+			req.OnExit(err, time.Since(started))
 		}
+	}()
+	return nil
+}
+
+func (r LaunchRequest) gameDir() string {
+	if strings.TrimSpace(r.GameDir) != "" {
+		return r.GameDir
 	}
-	return cmd.Start()
+	return r.BaseDir
 }
 
 func PrepareNatives(baseDir, version string) (int, error) {
@@ -187,6 +209,7 @@ func buildClasspath(baseDir string, resolved *resolvedVersion, rootVersion strin
 func buildArgs(req LaunchRequest, resolved *resolvedVersion, nativesDir string) []string {
 	var args []string
 	args = append(args, buildMemoryArgs(req.MemoryMB)...)
+	args = append(args, req.JVMArgs...)
 	for _, arg := range resolved.Arguments.Jvm {
 		for _, v := range expandArgument(arg) {
 			args = append(args, replaceVars(v, req, resolved, nativesDir))
@@ -265,7 +288,7 @@ func replaceVars(input string, req LaunchRequest, resolved *resolvedVersion, nat
 		"${auth_access_token}": "0",
 		"${clientid}":          "",
 		"${version_name}":     resolved.ID,
-		"${game_directory}":   req.BaseDir,
+		"${game_directory}":   req.gameDir(),
 		"${assets_root}":      filepath.Join(req.BaseDir, "assets"),
 		"${assets_index_name}": resolved.AssetIndex.ID,
 		"${auth_uuid}":        req.Player.UUID,
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
@@ -16,7 +17,6 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"shinecore/internal/launcher/archive"
@@ -28,6 +28,8 @@ import (
 	"shinecore/internal/launcher/launch"
 	"shinecore/internal/launcher/mojang"
 	"shinecore/internal/launcher/server"
+	"shinecore/internal/launcher/store"
+	"shinecore/internal/system"
 )
 
 type ProgressEvent struct {
@@ -35,10 +37,26 @@ type ProgressEvent struct {
 	Done     int
 	Total    int
 	Progress float64
+	// Прогресс загрузок в байтах: Progress считается по ним, если объём известен.
+	BytesDone  int64
+	BytesTotal int64
+	Speed      float64 // байт/с
+	ETA        time.Duration
+	File       string
 }
 
 type Launcher struct {
 	ConfigPath string
+	// DeepVerify — перехешировать все файлы, не доверяя индексу проверенных
+	// файлов (то же, что deep_verify в конфиге).
+	DeepVerify bool
+
+	// indexes и probes — открытые индекс проверенных файлов и кэш проверок
+	// Java по пути файла: одновременные операции делят их, а не
+	// перезаписывают файлы друг друга.
+	mu      sync.Mutex
+	indexes map[string]*download.Index
+	probes  map[string]*java.ProbeCache
 }
 
 var mcVersionRe = regexp.MustCompile(`^1\.(\d+)(?:\.(\d+))?`)
@@ -47,264 +65,294 @@ func (l *Launcher) LoadConfig() (*config.Config, error) {
 	return config.Load(l.ConfigPath)
 }
 
-func (l *Launcher) Install(ctx context.Context, onProgress func(ProgressEvent)) (*config.Config, error) {
+// loadInstance загружает конфиг и сборку по ID (пустой ID — выбранная сборка).
+func (l *Launcher) loadInstance(instanceID string) (*config.Config, *config.Instance, error) {
 	cfg, err := l.LoadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	inst, err := cfg.Instance(instanceID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", err, instanceID)
+	}
+	return cfg, inst, nil
+}
+
+func (l *Launcher) Install(ctx context.Context, instanceID string, onProgress func(ProgressEvent)) (_ *config.Instance, err error) {
+	cfg, inst, err := l.loadInstance(instanceID)
 	if err != nil {
 		return nil, err
 	}
-	serverCfg, err := config.LoadServer("")
+	ctx, closeIndex := l.withIndex(ctx, cfg)
+	defer closeIndex()
+	probes, closeProbes := l.openJavaProbes(cfg)
+	defer closeProbes()
+	serverCfg, err := loadServerProfile()
 	if err != nil {
 		return nil, err
 	}
 	client := newHTTPClient()
-	srv := &server.Client{BaseURL: serverCfg.ServerBaseURL, Secret: serverCfg.ServerSecret, Client: client}
-	slog.Info("launcher: fetch manifest", "server", serverCfg.ServerBaseURL)
+	tracker := newProgressTracker(onProgress)
+	sched := newScheduler(client, tracker)
+	srv := newServerClient(serverCfg, inst, client)
+	slog.Info("launcher: fetch manifest", "server", serverCfg.ServerBaseURL, "instance", inst.ID)
 	
 	// Сохраняем старые значения перед применением манифеста
-	oldGameVersion := cfg.GameVersion
-	oldLoader := cfg.Loader
-	oldLoaderVersion := cfg.LoaderVersion
+	oldGameVersion := inst.GameVersion
+	oldLoader := inst.Loader
+	oldLoaderVersion := inst.LoaderVersion
 	
-	manifest, err := srv.FetchManifest(ctx)
+	manifest, err := installManifest(ctx, srv, inst)
 	if err != nil {
-		// Fallback: Если манифест недоступен - используем сохранённый конфиг
-		if strings.TrimSpace(cfg.GameVersion) == "" {
-			slog.Error("launcher: manifest unavailable and config incomplete", "error", err)
-			return nil, errors.New("manifest unavailable and config incomplete: " + err.Error())
-		}
-		// Используем сохранённый конфиг для продолжения установки/запуска
-		slog.Info("launcher: manifest unavailable, using saved config", 
-			"version", cfg.GameVersion, "loader", cfg.Loader, 
-			"note", "server offline or manifest cache missing - using local config")
-		manifest = nil // Явно указываем, что манифеста нет
-	} else {
-		slog.Info("launcher: manifest fetched", "mods", len(manifest.Packages.Mods))
-		applyManifest(cfg, manifest)
+		return nil, err
 	}
+	// Итог установки решает, можно ли откатиться с этой версии манифеста.
+	defer func() { noteInstall(inst.Dir, manifest, err) }()
 	
-	// Проверяем, изменились ли версия игры или загрузчик
-	versionChanged := oldGameVersion != "" && oldGameVersion != cfg.GameVersion
-	loaderChanged := oldLoader != cfg.Loader
-	loaderVersionChanged := oldLoaderVersion != cfg.LoaderVersion
-	
-	// ГАРАНТИРОВАННАЯ очистка при смене загрузчика ДО загрузки
-	if versionChanged || loaderChanged || loaderVersionChanged {
-		slog.Info("launcher: version or loader changed - cleaning install dir BEFORE installation", 
-			"old_version", oldGameVersion, "new_version", cfg.GameVersion,
-			"old_loader", oldLoader, "new_loader", cfg.Loader,
-			"old_loader_version", oldLoaderVersion, "new_loader_version", cfg.LoaderVersion)
-		
-		// Принудительная очистка - удаляем всё кроме java и файлов лаунчера
-		if err := cleanInstallDir(cfg.InstallDir); err != nil {
-			slog.Error("launcher: clean install dir failed", "error", err)
-			return nil, fmt.Errorf("cleanup failed: %w", err)
-		}
-		
-		// Убеждаемся, что очистка завершена перед загрузкой
-		slog.Info("launcher: install dir cleaned successfully, proceeding with installation")
+	// Версии, библиотеки и ассеты лежат в общем InstallDir под разными ID,
+	// поэтому смена загрузчика не требует очистки папки сборки: миры и
+	// настройки игрока остаются на месте, а моды приводит в порядок синхронизация.
+	if changed := oldGameVersion != inst.GameVersion || oldLoader != inst.Loader || oldLoaderVersion != inst.LoaderVersion; changed {
+		slog.Info("launcher: version or loader changed", "instance", inst.ID,
+			"old_version", oldGameVersion, "new_version", inst.GameVersion,
+			"old_loader", oldLoader, "new_loader", inst.Loader,
+			"old_loader_version", oldLoaderVersion, "new_loader_version", inst.LoaderVersion)
 	}
-	
+
+	if err := l.install(ctx, cfg, inst, client, sched, tracker, srv, probes, manifest); err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
+
+// install ставит сборку по уже полученному манифесту (nil — манифест
+// недоступен, ставим по сохранённому конфигу). Состояние релиза записывает
+// вызывающий: он же получал манифест.
+func (l *Launcher) install(ctx context.Context, cfg *config.Config, inst *config.Instance, client *http.Client, sched *download.Scheduler, tracker *progressTracker, srv *server.Client, probes *java.ProbeCache, manifest *server.Manifest) error {
 	if err := cfg.Save(l.ConfigPath); err != nil {
 		slog.Error("launcher: save config failed", "error", err)
-		return nil, err
+		return err
 	}
 
 	if err := os.MkdirAll(cfg.InstallDir, 0o755); err != nil {
 		slog.Error("launcher: create install dir failed", "error", err)
-		return nil, err
+		return err
+	}
+	if err := os.MkdirAll(inst.Dir, 0o755); err != nil {
+		slog.Error("launcher: create instance dir failed", "error", err)
+		return err
 	}
 
-	tracker := newProgressTracker(onProgress)
+	cache, err := openStore(ctx, cfg)
+	if err != nil {
+		slog.Error("launcher: open store failed", "error", err)
+		return err
+	}
+	defer func() {
+		if err := cache.Close(); err != nil {
+			slog.Warn("launcher: save store refs failed", "error", err)
+		}
+	}()
 
 	// Синхронизация модов только если манифест доступен
 	if manifest != nil {
-		if err := syncModsStrict(ctx, client, srv, cfg.InstallDir, manifest, tracker); err != nil {
+		if err := syncPackages(ctx, sched, packageCache{cache}, srv, inst.Dir, manifest, tracker); err != nil {
 			slog.Error("launcher: sync packages failed", "error", err)
-			return nil, err
+			return err
 		}
 	} else {
 		slog.Info("launcher: skipping mods sync (manifest unavailable)")
 	}
 
-	requiredJava := resolveRequiredJava(manifest, cfg.GameVersion)
-	
-	// Сначала проверяем локально установленную Java
-	javaPath := findInstalledJava(cfg.InstallDir, requiredJava)
-	
-	// Если Java не найдена локально и манифест доступен - пытаемся загрузить
-	if javaPath == "" && manifest != nil {
-		var err error
-		javaPath, err = ensureJava(ctx, client, srv, cfg.InstallDir, manifest, requiredJava)
-		if err != nil {
-			slog.Warn("launcher: ensure java failed", "error", err)
-			// Не блокируем установку, если Java можно будет найти позже
-		}
-	}
-	
-	// Повторная проверка локальной Java на случай, если ensureJava не сработал
-	if javaPath == "" {
-		javaPath = findInstalledJava(cfg.InstallDir, requiredJava)
-	}
-
 	_, err = mojang.EnsureInstalled(ctx, mojang.InstallRequest{
-		BaseDir:      cfg.InstallDir,
-		Version:      cfg.GameVersion,
-		Client:       client,
-		AssetWorkers: 16,
+		BaseDir:   cfg.InstallDir,
+		Version:   inst.GameVersion,
+		Client:    client,
+		Cache:     cache,
+		Scheduler: sched,
 		OnProgress: func(step string, done, total int) {
 			tracker.Update(step, done, total)
 		},
 	})
 	if err != nil {
 		slog.Error("launcher: mojang install failed", "error", err)
-		return nil, err
+		return err
+	}
+
+	// javaVersion берётся из JSON версии, поэтому Java ставится после неё.
+	requiredJava := resolveRequiredJava(cfg.InstallDir, inst, manifest)
+
+	// Сначала ищем выбранную игроком, скачанную или системную Java
+	javaPath, err := findJava(probes, cfg.InstallDir, inst, requiredJava)
+	if err != nil {
+		return err
+	}
+
+	// Если Java не найдена локально - пытаемся загрузить
+	if javaPath == "" && requiredJava.MajorVersion > 0 {
+		javaPath, err = ensureJava(ctx, client, sched, srv, probes, cfg, manifest, requiredJava)
+		if err != nil {
+			slog.Warn("launcher: ensure java failed", "error", err)
+			// Не блокируем установку, если Java можно будет найти позже
+		}
 	}
 
 	var versionID string
-	switch cfg.Loader {
+	switch inst.Loader {
 	case "":
-		cfg.LoaderVersion = ""
+		inst.LoaderVersion = ""
 		_ = cfg.Save(l.ConfigPath)
-		versionID = cfg.GameVersion
+		versionID = inst.GameVersion
 	case "fabric":
 		var loaderVersion string
-		versionID, loaderVersion, err = fabric.EnsureInstalled(ctx, cfg.InstallDir, cfg.GameVersion, cfg.LoaderVersion, client)
+		versionID, loaderVersion, err = fabric.EnsureInstalled(ctx, fabric.InstallRequest{
+			BaseDir:       cfg.InstallDir,
+			GameVersion:   inst.GameVersion,
+			LoaderVersion: inst.LoaderVersion,
+			Client:        client,
+			Cache:         cache,
+			Scheduler:     sched,
+		})
 		if err != nil {
 			slog.Error("launcher: fabric install failed", "error", err)
-			return nil, err
+			return err
 		}
-		if loaderVersion != "" && loaderVersion != cfg.LoaderVersion {
-			cfg.LoaderVersion = loaderVersion
+		if loaderVersion != "" && loaderVersion != inst.LoaderVersion {
+			inst.LoaderVersion = loaderVersion
 			_ = cfg.Save(l.ConfigPath)
 		}
-		if err := mojang.EnsureLibrariesForVersion(ctx, cfg.InstallDir, versionID, client); err != nil {
-			return nil, err
+		if err := mojang.EnsureLibrariesForVersion(ctx, cfg.InstallDir, versionID, sched, cache); err != nil {
+			return err
 		}
 	case "forge":
 		if javaPath == "" {
-			return nil, errors.New("java не установлена (runtime not found)")
+			return errors.New("java не установлена (runtime not found)")
 		}
 		versionID, err = forge.EnsureInstalled(ctx, forge.InstallRequest{
 			BaseDir:       cfg.InstallDir,
-			GameVersion:   cfg.GameVersion,
+			GameVersion:   inst.GameVersion,
 			LoaderKind:    forge.LoaderForge,
-			LoaderVersion: cfg.LoaderVersion,
+			LoaderVersion: inst.LoaderVersion,
 			JavaPath:      javaPath,
 			Client:        client,
+			Cache:         cache,
+			Scheduler:     sched,
 		})
 		if err != nil {
 			slog.Error("launcher: forge install failed", "error", err)
-			return nil, err
+			return err
 		}
-		if err := mojang.EnsureLibrariesForVersion(ctx, cfg.InstallDir, versionID, client); err != nil {
-			return nil, err
+		if err := mojang.EnsureLibrariesForVersion(ctx, cfg.InstallDir, versionID, sched, cache); err != nil {
+			return err
 		}
 	case "neoforge":
 		if javaPath == "" {
-			return nil, errors.New("java не установлена (runtime not found)")
+			return errors.New("java не установлена (runtime not found)")
 		}
 		versionID, err = forge.EnsureInstalled(ctx, forge.InstallRequest{
 			BaseDir:       cfg.InstallDir,
-			GameVersion:   cfg.GameVersion,
+			GameVersion:   inst.GameVersion,
 			LoaderKind:    forge.LoaderNeoForge,
-			LoaderVersion: cfg.LoaderVersion,
+			LoaderVersion: inst.LoaderVersion,
 			JavaPath:      javaPath,
 			Client:        client,
+			Cache:         cache,
+			Scheduler:     sched,
 		})
 		if err != nil {
 			slog.Error("launcher: neoforge install failed", "error", err)
-			return nil, err
+			return err
 		}
-		if err := mojang.EnsureLibrariesForVersion(ctx, cfg.InstallDir, versionID, client); err != nil {
-			return nil, err
+		if err := mojang.EnsureLibrariesForVersion(ctx, cfg.InstallDir, versionID, sched, cache); err != nil {
+			return err
 		}
 	default:
-		return nil, errors.New("unknown loader: " + cfg.Loader)
+		return errors.New("unknown loader: " + inst.Loader)
 	}
 
 	nativesCount, err := launch.PrepareNatives(cfg.InstallDir, versionID)
 	if err != nil {
 		slog.Error("launcher: natives prepare failed", "error", err)
-		return nil, err
+		return err
 	}
 	if nativesCount > 0 {
 		tracker.SetTotal("natives", nativesCount)
 		tracker.Update("natives", nativesCount, nativesCount)
 	}
 
-	return cfg, nil
+	return nil
 }
 
-func (l *Launcher) PrepareForLaunch(ctx context.Context, onProgress func(ProgressEvent)) error {
-	cfg, err := l.LoadConfig()
+func (l *Launcher) PrepareForLaunch(ctx context.Context, instanceID string, onProgress func(ProgressEvent)) (err error) {
+	cfg, inst, err := l.loadInstance(instanceID)
 	if err != nil {
 		return err
 	}
-	serverCfg, err := config.LoadServer("")
+	ctx, closeIndex := l.withIndex(ctx, cfg)
+	defer closeIndex()
+	probes, closeProbes := l.openJavaProbes(cfg)
+	defer closeProbes()
+	serverCfg, err := loadServerProfile()
 	if err != nil {
 		return err
 	}
 	client := newHTTPClient()
-	srv := &server.Client{BaseURL: serverCfg.ServerBaseURL, Secret: serverCfg.ServerSecret, Client: client}
 	tracker := newProgressTracker(onProgress)
+	sched := newScheduler(client, tracker)
+	srv := newServerClient(serverCfg, inst, client)
 
-	oldGameVersion := cfg.GameVersion
-	oldLoader := cfg.Loader
-	oldLoaderVersion := cfg.LoaderVersion
+	oldGameVersion := inst.GameVersion
+	oldLoader := inst.Loader
+	oldLoaderVersion := inst.LoaderVersion
 
-	manifest, err := srv.FetchManifest(ctx)
+	manifest, err := installManifest(ctx, srv, inst)
 	if err != nil {
-		if strings.TrimSpace(cfg.GameVersion) == "" {
-			slog.Error("launcher: manifest unavailable and config incomplete", "error", err)
-			return errors.New("manifest unavailable and config incomplete: " + err.Error())
-		}
-		slog.Info("launcher: manifest unavailable, using saved config",
-			"version", cfg.GameVersion, "loader", cfg.Loader,
-			"note", "server offline or manifest cache missing - using local config")
-		manifest = nil
-	} else {
-		slog.Info("launcher: manifest fetched", "mods", len(manifest.Packages.Mods))
-		applyManifest(cfg, manifest)
+		return err
 	}
+	defer func() { noteInstall(inst.Dir, manifest, err) }()
 
-	versionChanged := oldGameVersion != "" && oldGameVersion != cfg.GameVersion
-	loaderChanged := oldLoader != cfg.Loader
-	loaderVersionChanged := oldLoaderVersion != cfg.LoaderVersion
+	versionChanged := oldGameVersion != "" && oldGameVersion != inst.GameVersion
+	loaderChanged := oldLoader != inst.Loader
+	loaderVersionChanged := oldLoaderVersion != inst.LoaderVersion
 	if versionChanged || loaderChanged || loaderVersionChanged {
 		slog.Info("launcher: version or loader changed - full install required",
-			"old_version", oldGameVersion, "new_version", cfg.GameVersion,
-			"old_loader", oldLoader, "new_loader", cfg.Loader,
-			"old_loader_version", oldLoaderVersion, "new_loader_version", cfg.LoaderVersion)
-		_, err := l.Install(ctx, onProgress)
-		return err
+			"old_version", oldGameVersion, "new_version", inst.GameVersion,
+			"old_loader", oldLoader, "new_loader", inst.Loader,
+			"old_loader_version", oldLoaderVersion, "new_loader_version", inst.LoaderVersion)
+		return l.install(ctx, cfg, inst, client, sched, tracker, srv, probes, manifest)
 	}
 
 	if err := cfg.Save(l.ConfigPath); err != nil {
 		return err
 	}
 
-	installed, err := l.IsInstalled()
+	installed, err := l.IsInstalled(inst.ID)
 	if err != nil {
 		return err
 	}
 	if !installed {
-		_, err := l.Install(ctx, onProgress)
-		return err
+		return l.install(ctx, cfg, inst, client, sched, tracker, srv, probes, manifest)
 	}
 
 	if manifest != nil {
-		if err := syncModsStrict(ctx, client, srv, cfg.InstallDir, manifest, tracker); err != nil {
+		cache, closeCache := openPackageCache(ctx, cfg)
+		err := syncPackages(ctx, sched, cache, srv, inst.Dir, manifest, tracker)
+		closeCache()
+		if err != nil {
 			return err
 		}
 	} else {
 		slog.Info("launcher: skipping mods sync (manifest unavailable)")
 	}
 
-	requiredJava := resolveRequiredJava(manifest, cfg.GameVersion)
-	if requiredJava > 0 {
-		javaPath := findInstalledJava(cfg.InstallDir, requiredJava)
-		if javaPath == "" && manifest != nil {
-			if _, err := ensureJava(ctx, client, srv, cfg.InstallDir, manifest, requiredJava); err != nil {
+	requiredJava := resolveRequiredJava(cfg.InstallDir, inst, manifest)
+	if requiredJava.MajorVersion > 0 {
+		javaPath, err := findJava(probes, cfg.InstallDir, inst, requiredJava)
+		if err != nil {
+			return err
+		}
+		if javaPath == "" {
+			if _, err := ensureJava(ctx, client, sched, srv, probes, cfg, manifest, requiredJava); err != nil {
 				return err
 			}
 		}
@@ -313,20 +361,44 @@ func (l *Launcher) PrepareForLaunch(ctx context.Context, onProgress func(Progres
 	return nil
 }
 
-func (l *Launcher) Launch(ctx context.Context, playerName string) error {
-	cfg, err := l.LoadConfig()
-	if err != nil {
+func (l *Launcher) Launch(ctx context.Context, instanceID, playerName string) error {
+	_, err := l.launch(ctx, instanceID, playerName)
+	return err
+}
+
+// LaunchAndWait запускает игру и ждёт итога первого запуска новой версии
+// манифеста: закрытия игры или подтверждения LKG через lkgConfirmAfter.
+// Консольному лаунчеру это нужно, чтобы не выйти раньше, чем итог записан.
+func (l *Launcher) LaunchAndWait(ctx context.Context, instanceID, playerName string) error {
+	settled, err := l.launch(ctx, instanceID, playerName)
+	if err != nil || settled == nil {
 		return err
 	}
+	slog.Info("launcher: waiting for the game to confirm the release", "confirm_after", lkgConfirmAfter)
+	select {
+	case <-settled:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Launcher) launch(ctx context.Context, instanceID, playerName string) (<-chan struct{}, error) {
+	cfg, inst, err := l.loadInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	probes, closeProbes := l.openJavaProbes(cfg)
+	defer closeProbes()
 	profile, err := config.LoadProfile("")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if strings.TrimSpace(playerName) == "" {
 		playerName = profile.PlayerName
 	}
 	if strings.TrimSpace(playerName) == "" {
-		return errors.New("player name required")
+		return nil, errors.New("player name required")
 	}
 	playerUUID := profile.PlayerUUID
 	if strings.TrimSpace(playerUUID) == "" || profile.PlayerName != playerName {
@@ -336,63 +408,86 @@ func (l *Launcher) Launch(ctx context.Context, playerName string) error {
 		_ = profile.Save("")
 	}
 
-	requiredJava := resolveRequiredJava(nil, cfg.GameVersion)
-	slog.Info("launcher: checking java", "required", requiredJava, "install_dir", cfg.InstallDir)
-	javaPath := findInstalledJava(cfg.InstallDir, requiredJava)
+	requiredJava := resolveRequiredJava(cfg.InstallDir, inst, nil)
+	slog.Info("launcher: checking java", "required", requiredJava.MajorVersion, "component", requiredJava.Component, "install_dir", cfg.InstallDir)
+	javaPath, err := findJava(probes, cfg.InstallDir, inst, requiredJava)
+	if err != nil {
+		return nil, err
+	}
 	if javaPath == "" {
-		slog.Error("launcher: java not found", "required", requiredJava, "search_dir", javaVersionDir(cfg.InstallDir, requiredJava))
-		if requiredJava > 0 {
-			return errors.New("java не установлена: нужна версия " + strconv.Itoa(requiredJava))
+		slog.Error("launcher: java not found", "required", requiredJava.MajorVersion, "component", requiredJava.Component, "search_dir", javaBaseDir(cfg.InstallDir))
+		if requiredJava.MajorVersion > 0 {
+			return nil, errors.New("java не установлена: нужна версия " + strconv.Itoa(requiredJava.MajorVersion))
 		}
-		return errors.New("java не установлена (runtime not found)")
+		return nil, errors.New("java не установлена (runtime not found)")
+	}
+	slog.Info("launcher: java found", "path", javaPath, "version", requiredJava.MajorVersion)
+	if err := checkJavaMemory(probes, javaPath, inst.MemoryMB); err != nil {
+		return nil, err
 	}
-	slog.Info("launcher: java found", "path", javaPath, "version", requiredJava)
 
-	versionID := resolveVersionID(cfg)
-	slog.Info("launcher: launching", "version", versionID, "memory_mb", cfg.MemoryMB, "java", javaPath)
-	return launch.Launch(ctx, launch.LaunchRequest{
+	versionID := resolveVersionID(inst)
+	slog.Info("launcher: launching", "instance", inst.ID, "version", versionID, "memory_mb", inst.MemoryMB, "java", javaPath)
+	onExit, stopWatch, settled := l.watchLaunch(ctx, cfg, inst)
+	err = launch.Launch(ctx, launch.LaunchRequest{
 		BaseDir:  cfg.InstallDir,
+		GameDir:  inst.Dir,
 		Version:  versionID,
 		Player:   launch.PlayerInfo{Name: playerName, UUID: playerUUID},
 		JavaPath: javaPath,
-		MemoryMB: cfg.MemoryMB,
+		MemoryMB: inst.MemoryMB,
+		JVMArgs:  inst.JVMArgs,
+		OnExit:   onExit,
 	})
+	if err != nil {
+		stopWatch()
+		return nil, err
+	}
+	return settled, nil
 }
 
-func (l *Launcher) SyncMods(ctx context.Context, onProgress func(ProgressEvent)) error {
-	cfg, err := l.LoadConfig()
+func (l *Launcher) SyncMods(ctx context.Context, instanceID string, onProgress func(ProgressEvent)) error {
+	cfg, inst, err := l.loadInstance(instanceID)
 	if err != nil {
 		return err
 	}
-	serverCfg, err := config.LoadServer("")
+	ctx, closeIndex := l.withIndex(ctx, cfg)
+	defer closeIndex()
+	serverCfg, err := loadServerProfile()
 	if err != nil {
 		return err
 	}
 	client := newHTTPClient()
-	srv := &server.Client{BaseURL: serverCfg.ServerBaseURL, Secret: serverCfg.ServerSecret, Client: client}
-	slog.Info("launcher: sync mods start", "server", serverCfg.ServerBaseURL)
-	manifest, err := srv.FetchManifest(ctx)
+	tracker := newProgressTracker(onProgress)
+	sched := newScheduler(client, tracker)
+	srv := newServerClient(serverCfg, inst, client)
+	slog.Info("launcher: sync mods start", "server", serverCfg.ServerBaseURL, "instance", inst.ID)
+	manifest, err := instanceManifest(ctx, srv, inst.Dir)
+	if errors.Is(err, server.ErrClockSkew) {
+		return err
+	}
 	if err != nil {
 		// Если манифест недоступен - просто пропускаем синхронизацию модов
 		// Это не критично, игра может работать с уже установленными модами
 		slog.Info("launcher: sync mods skipped - manifest unavailable", "error", err)
 		return nil
 	}
-	slog.Info("launcher: manifest fetched for sync", "mods", len(manifest.Packages.Mods))
-	tracker := newProgressTracker(onProgress)
-	if err := syncModsStrict(ctx, client, srv, cfg.InstallDir, manifest, tracker); err != nil {
+	slog.Info("launcher: manifest fetched for sync", "files", manifest.Packages.FileCount())
+	cache, closeCache := openPackageCache(ctx, cfg)
+	defer closeCache()
+	if err := syncPackages(ctx, sched, cache, srv, inst.Dir, manifest, tracker); err != nil {
 		return err
 	}
 	slog.Info("launcher: sync mods complete")
 	return nil
 }
 
-func (l *Launcher) IsInstalled() (bool, error) {
-	cfg, err := l.LoadConfig()
+func (l *Launcher) IsInstalled(instanceID string) (bool, error) {
+	cfg, inst, err := l.loadInstance(instanceID)
 	if err != nil {
 		return false, err
 	}
-	versionID := resolveVersionID(cfg)
+	versionID := resolveVersionID(inst)
 	metaPath := filepath.Join(cfg.InstallDir, "versions", versionID, versionID+".json")
 	_, err = os.Stat(metaPath)
 	if err == nil {
@@ -404,168 +499,286 @@ func (l *Launcher) IsInstalled() (bool, error) {
 	return false, err
 }
 
-func applyManifest(cfg *config.Config, manifest *server.Manifest) {
+// installManifest получает манифест для установки или запуска и применяет
+// его к сборке. Без манифеста (сервер недоступен, кэша нет) возвращает nil,
+// и ставить будем по сохранённому конфигу, если в нём есть версия игры.
+func installManifest(ctx context.Context, srv *server.Client, inst *config.Instance) (*server.Manifest, error) {
+	manifest, err := instanceManifest(ctx, srv, inst.Dir)
+	if errors.Is(err, server.ErrClockSkew) {
+		// Сохранённый конфиг не поможет: сервер отклонит и загрузки.
+		return nil, err
+	}
+	if err != nil {
+		if strings.TrimSpace(inst.GameVersion) == "" {
+			slog.Error("launcher: manifest unavailable and config incomplete", "error", err)
+			return nil, errors.New("manifest unavailable and config incomplete: " + err.Error())
+		}
+		slog.Info("launcher: manifest unavailable, using saved config",
+			"version", inst.GameVersion, "loader", inst.Loader,
+			"note", "server offline or manifest cache missing - using local config")
+		return nil, nil
+	}
+	slog.Info("launcher: manifest fetched", "files", manifest.Packages.FileCount())
+	applyManifest(inst, manifest)
+	return manifest, nil
+}
+
+func applyManifest(inst *config.Instance, manifest *server.Manifest) {
 	if manifest == nil {
 		return
 	}
 	if manifest.Dependencies.GameVersion != "" {
-		cfg.GameVersion = manifest.Dependencies.GameVersion
+		inst.GameVersion = manifest.Dependencies.GameVersion
 	}
 	if manifest.Dependencies.Loader == "" {
-		cfg.Loader = ""
-		cfg.LoaderVersion = ""
+		inst.Loader = ""
+		inst.LoaderVersion = ""
 	} else {
-		cfg.Loader = manifest.Dependencies.Loader
+		inst.Loader = manifest.Dependencies.Loader
 		if manifest.Dependencies.LoaderVersion != "" {
-			cfg.LoaderVersion = manifest.Dependencies.LoaderVersion
+			inst.LoaderVersion = manifest.Dependencies.LoaderVersion
 		}
 	}
 }
 
-func (l *Launcher) RefreshFromServer(ctx context.Context) (*config.Config, error) {
-	cfg, err := l.LoadConfig()
+func (l *Launcher) RefreshFromServer(ctx context.Context, instanceID string) (*config.Instance, error) {
+	cfg, inst, err := l.loadInstance(instanceID)
 	if err != nil {
 		return nil, err
 	}
-	serverCfg, err := config.LoadServer("")
+	serverCfg, err := loadServerProfile()
 	if err != nil {
-		return cfg, err
+		return inst, err
 	}
-	client := &http.Client{}
-	srv := &server.Client{BaseURL: serverCfg.ServerBaseURL, Secret: serverCfg.ServerSecret, Client: client}
-	manifest, err := srv.FetchManifest(ctx)
+	srv := newServerClient(serverCfg, inst, newAPIClient())
+	manifest, err := instanceManifest(ctx, srv, inst.Dir)
 	if err != nil {
-		return cfg, err
+		return inst, err
 	}
-	applyManifest(cfg, manifest)
+	applyManifest(inst, manifest)
 	if err := cfg.Save(l.ConfigPath); err != nil {
-		return cfg, err
+		return inst, err
 	}
-	return cfg, nil
+	return inst, nil
 }
 
-func syncModsStrict(ctx context.Context, client *http.Client, srv *server.Client, baseDir string, manifest *server.Manifest, tracker *progressTracker) error {
-	if manifest == nil {
-		return nil
+// FetchManifest возвращает проверенный манифест сборки и его актуальность,
+// не меняя конфиг.
+func (l *Launcher) FetchManifest(ctx context.Context, instanceID string) (*server.ManifestResult, error) {
+	_, inst, err := l.loadInstance(instanceID)
+	if err != nil {
+		return nil, err
 	}
-	if tracker == nil {
-		tracker = newProgressTracker(nil)
+	serverCfg, err := loadServerProfile()
+	if err != nil {
+		return nil, err
 	}
-	modsDir := filepath.Join(baseDir, "mods")
-	_ = os.MkdirAll(modsDir, 0o755)
+	return newServerClient(serverCfg, inst, newAPIClient()).FetchManifest(ctx)
+}
 
-	expected := make(map[string]server.FilePackage)
-	for _, mod := range manifest.Packages.Mods {
-		if mod.Path == "" || mod.URL == "" {
-			continue
-		}
-		key := modKey(mod.Path)
-		expected[key] = mod
+// GC удаляет из общего хранилища объекты, на которые не ссылается ни одна установка.
+func (l *Launcher) GC() (store.GCResult, error) {
+	cfg, err := l.LoadConfig()
+	if err != nil {
+		return store.GCResult{}, err
 	}
-
-	local, err := listLocalMods(modsDir)
+	ctx, closeIndex := l.withIndex(context.Background(), cfg)
+	defer closeIndex()
+	st, err := openStore(ctx, cfg)
 	if err != nil {
-		return err
+		return store.GCResult{}, err
 	}
-
-	extras := make([]string, 0)
-	for key, fullPath := range local {
-		if _, ok := expected[key]; !ok {
-			extras = append(extras, fullPath)
-		}
+	result, err := st.GC()
+	if closeErr := st.Close(); err == nil {
+		err = closeErr
 	}
+	slog.Info("launcher: store gc", "removed", result.RemovedObjects, "freed_bytes", result.FreedBytes, "kept", result.KeptObjects)
+	return result, err
+}
 
-	tracker.SetTotal("mods", len(expected)+len(extras))
-	slog.Info("mods: sync start", "expected", len(expected), "extras", len(extras))
+// fileIndexName — индекс проверенных файлов в общем хранилище (см. download.Index).
+const fileIndexName = "index.json"
 
-	downloaded := 0
-	for _, mod := range expected {
-		dst := filepath.Join(modsDir, filepath.FromSlash(mod.Path))
-		url := srv.ResolveURL(mod.URL)
-		req, err := srv.SignedRequest(ctx, http.MethodGet, url)
-		if err != nil {
-			return err
+// openStore открывает общее хранилище с индексом проверенных файлов операции ctx.
+func openStore(ctx context.Context, cfg *config.Config) (*store.Store, error) {
+	st, err := store.Open(cfg.StoreDir)
+	if err != nil {
+		return nil, err
+	}
+	st.Verification = download.VerificationFrom(ctx)
+	return st, nil
+}
+
+// withIndex подключает к операции индекс проверенных файлов из общего
+// хранилища; возвращённая функция сохраняет его после операции.
+func (l *Launcher) withIndex(ctx context.Context, cfg *config.Config) (context.Context, func()) {
+	path := filepath.Join(cfg.StoreDir, fileIndexName)
+	l.mu.Lock()
+	idx, ok := l.indexes[path]
+	if !ok {
+		var err error
+		if idx, err = download.OpenIndex(path); err != nil {
+			l.mu.Unlock()
+			slog.Warn("launcher: open file index failed", "error", err)
+			return ctx, func() {}
 		}
-		if err := download.EnsureFileWithRequest(ctx, client, req, dst, mod.Size, mod.Sha256, nil); err != nil {
-			return err
+		if l.indexes == nil {
+			l.indexes = map[string]*download.Index{}
 		}
-		downloaded++
-		tracker.Increment("mods")
+		l.indexes[path] = idx
 	}
+	l.mu.Unlock()
+	ctx = download.WithVerification(ctx, download.Verification{Index: idx, Deep: l.DeepVerify || cfg.DeepVerify})
+	return ctx, func() {
+		if err := idx.Flush(); err != nil {
+			slog.Warn("launcher: save file index failed", "error", err)
+		}
+	}
+}
 
-	removed := 0
-	for _, fullPath := range extras {
-		_ = os.Remove(fullPath)
-		removed++
-		tracker.Increment("mods")
+// fetchManifest — манифест для установки и синхронизации; устаревший кэш
+// годится, но попадает в лог с возрастом.
+func fetchManifest(ctx context.Context, srv *server.Client) (*server.Manifest, error) {
+	result, err := srv.FetchManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result.Stale() {
+		slog.Warn("launcher: server unavailable, using cached manifest",
+			"age", result.Age.Round(time.Second), "fetched_at", result.FetchedAt.Format(time.RFC3339))
 	}
+	return result.Manifest, nil
+}
 
-	slog.Info("mods: sync complete", "downloaded", downloaded, "removed", removed)
-	return nil
+func newServerClient(serverCfg *config.ServerProfile, inst *config.Instance, client *http.Client) *server.Client {
+	srv := &server.Client{
+		BaseURL:    serverCfg.ServerBaseURL,
+		Secret:     serverCfg.ServerSecret,
+		Auth:       serverAuth(serverCfg, client),
+		Client:     client,
+		PublicKeys: serverCfg.ManifestPublicKeys,
+		Channel:    serverCfg.Channel,
+		MaxAge:     serverCfg.ManifestMaxAge(),
+		MaxStale:   serverCfg.ManifestMaxStale(),
+	}
+	if inst != nil {
+		srv.ManifestURL = inst.ManifestURL
+		srv.AppliedVersion = loadSyncState(inst.Dir).ManifestVersion
+	}
+	return srv
 }
 
-func ensureJava(ctx context.Context, client *http.Client, srv *server.Client, baseDir string, manifest *server.Manifest, required int) (string, error) {
-	if required <= 0 {
+// ensureJava ставит нужную Java: архивом по ссылке из манифеста сервера,
+// если она задана, иначе — рантаймом Mojang.
+func ensureJava(ctx context.Context, client *http.Client, sched *download.Scheduler, srv *server.Client, probes *java.ProbeCache, cfg *config.Config, manifest *server.Manifest, required mojang.JavaVersion) (string, error) {
+	if required.MajorVersion <= 0 {
 		return "", errors.New("java version not resolved")
 	}
-	if path := findInstalledJava(baseDir, required); path != "" {
+	baseDir := cfg.InstallDir
+	if path := findInstalledJava(probes, baseDir, required); path != "" {
 		return path, nil
 	}
+	if manifest != nil {
+		if rt := manifest.Dependencies.JavaURLs.Find(required.MajorVersion, runtime.GOOS, runtime.GOARCH); rt != nil {
+			err := checkJavaArchive(rt, cfg.AllowUnverifiedJava)
+			if err == nil {
+				return installJavaArchive(ctx, sched, srv, probes, baseDir, rt)
+			}
+			if required.Component == "" {
+				return "", err
+			}
+			slog.Warn("launcher: ignoring java archive from manifest, using mojang runtime", "url", rt.URL, "error", err)
+		}
+	}
+	return installMojangJava(ctx, client, sched, baseDir, required)
+}
 
-	if manifest == nil {
-		return "", errors.New("java url not provided: need Java " + strconv.Itoa(required))
+// checkJavaArchive не пускает архив Java без sha256: распакованная Java
+// запускается с правами игрока, и подменённый архив не должен пройти.
+func checkJavaArchive(rt *server.JavaRuntime, allowUnverified bool) error {
+	if strings.TrimSpace(rt.Sha256) != "" || allowUnverified {
+		return nil
+	}
+	return fmt.Errorf("java archive for Java %d has no sha256 in the manifest (allow_unverified_java is off): %s", rt.Major, rt.URL)
+}
+
+// installMojangJava ставит компонент рантайма Mojang в java/<component>.
+func installMojangJava(ctx context.Context, client *http.Client, sched *download.Scheduler, baseDir string, required mojang.JavaVersion) (string, error) {
+	if required.Component == "" {
+		return "", errors.New("java url not set: need Java " + strconv.Itoa(required.MajorVersion))
+	}
+	platform := java.Platform()
+	if platform == "" {
+		return "", errors.New("java runtime: unsupported platform " + runtime.GOOS + "/" + runtime.GOARCH)
+	}
+	index, err := java.FetchRuntimeIndex(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	entry, ok := index.Lookup(platform, required.Component)
+	if !ok {
+		return "", errors.New("java runtime " + required.Component + " is not available for " + platform)
 	}
-	downloadURL := javaURLForVersion(manifest.Dependencies.JavaURLs, required)
-	if strings.TrimSpace(downloadURL) == "" {
-		return "", errors.New("java url not set: need Java " + strconv.Itoa(required))
+	targetDir := javaRuntimeDir(baseDir, required.Component)
+	slog.Info("launcher: installing java runtime", "component", required.Component, "version", entry.Version.Name, "dir", targetDir)
+	if err := java.InstallRuntime(ctx, client, sched, entry, targetDir); err != nil {
+		return "", err
+	}
+	if path := java.Executable(targetDir); path != "" {
+		return path, nil
 	}
+	return "", errors.New("java not found after install: need Java " + strconv.Itoa(required.MajorVersion))
+}
 
+// installJavaArchive скачивает и распаковывает Java по ссылке из манифеста.
+func installJavaArchive(ctx context.Context, sched *download.Scheduler, srv *server.Client, probes *java.ProbeCache, baseDir string, rt *server.JavaRuntime) (string, error) {
 	downloadDir := filepath.Join(javaBaseDir(baseDir), "downloads")
 	if err := os.MkdirAll(downloadDir, 0o755); err != nil {
 		return "", err
 	}
-	archiveName := javaArchiveName(downloadURL, required)
+	downloadURL := rt.URL
+	required := rt.Major
+	archiveName := javaArchiveName(rt)
 	dst := filepath.Join(downloadDir, archiveName)
+	var checksum download.Checksum
+	if rt.Sha256 != "" {
+		checksum = download.SHA256(rt.Sha256)
+	} else {
+		slog.Warn("launcher: java archive has no sha256, downloading unverified", "url", downloadURL, "major", required)
+	}
 
-	req, err := buildJavaDownloadRequest(ctx, srv, downloadURL)
-	if err != nil {
-		return "", err
+	group := sched.NewGroup(ctx, nil)
+	jobURL := downloadURL
+	if srv != nil {
+		jobURL = srv.ResolveURL(downloadURL)
 	}
-	if err := download.EnsureFileWithRequest(ctx, client, req, dst, 0, "", nil); err != nil {
+	group.Add(download.Job{
+		URL: jobURL,
+		Request: func(ctx context.Context) (*http.Request, error) {
+			return buildDownloadRequest(ctx, srv, downloadURL)
+		},
+		Dst:      dst,
+		Size:     rt.Size,
+		Checksum: checksum,
+		Priority: download.PriorityHigh,
+	})
+	if err := group.Wait(); err != nil {
 		return "", err
 	}
 
 	archiveLower := strings.ToLower(archiveName)
-	switch {
-	case strings.HasSuffix(archiveLower, ".zip"):
-		targetDir := javaVersionDir(baseDir, required)
-		_ = os.RemoveAll(targetDir)
-		if err := os.MkdirAll(targetDir, 0o755); err != nil {
-			return "", err
-		}
-		if err := archive.ExtractZip(dst, targetDir); err != nil {
-			return "", err
-		}
-		if path := findJavaInDirTree(targetDir, required); path != "" {
-			return path, nil
-		}
-		return "", errors.New("java not found after extract: need Java " + strconv.Itoa(required))
-	case strings.HasSuffix(archiveLower, ".tar.gz"), strings.HasSuffix(archiveLower, ".tgz"), strings.HasSuffix(archiveLower, ".tar"):
-		targetDir := javaVersionDir(baseDir, required)
-		_ = os.RemoveAll(targetDir)
-		if err := os.MkdirAll(targetDir, 0o755); err != nil {
-			return "", err
-		}
-		if err := archive.ExtractTar(dst, targetDir); err != nil {
-			return "", err
-		}
-		if path := findJavaInDirTree(targetDir, required); path != "" {
-			return path, nil
-		}
-		return "", errors.New("java not found after extract: need Java " + strconv.Itoa(required))
-	default:
-		return "", errors.New("unsupported java package: " + archiveLower + " (use zip or tar)")
+	if !strings.HasSuffix(archiveLower, ".zip") && !archive.IsTar(archiveLower) {
+		return "", errors.New("unsupported java package: " + archiveLower + " (use zip, tar, tar.gz, tar.xz or tar.zst)")
+	}
+	targetDir := javaVersionDir(baseDir, required)
+	_ = os.RemoveAll(targetDir)
+	if err := archive.Extract(dst, targetDir); err != nil {
+		return "", err
 	}
+	if path := probes.FindInTree(targetDir, required); path != "" {
+		return path, nil
+	}
+	return "", errors.New("java not found after extract: need Java " + strconv.Itoa(required))
 }
 
 func javaBaseDir(baseDir string) string {
@@ -576,31 +789,37 @@ func javaVersionDir(baseDir string, required int) string {
 	return filepath.Join(javaBaseDir(baseDir), "java"+strconv.Itoa(required))
 }
 
-func javaArchiveName(raw string, required int) string {
+// javaRuntimeDir — каталог рантайма Mojang: java/<component>.
+func javaRuntimeDir(baseDir, component string) string {
+	return filepath.Join(javaBaseDir(baseDir), component)
+}
+
+// javaArchiveName — имя скачанного архива; по его расширению выбирается
+// распаковщик, поэтому явный тип архива важнее имени из URL.
+func javaArchiveName(rt *server.JavaRuntime) string {
+	if rt.Archive != "" {
+		name := "java" + strconv.Itoa(rt.Major)
+		if rt.OS != "" {
+			name += "-" + rt.OS
+		}
+		if rt.Arch != "" {
+			name += "-" + rt.Arch
+		}
+		return name + "." + strings.TrimPrefix(strings.ToLower(rt.Archive), ".")
+	}
 	name := ""
-	if parsed, err := url.Parse(raw); err == nil {
+	if parsed, err := url.Parse(rt.URL); err == nil {
 		name = path.Base(parsed.Path)
 	}
 	if name == "" || name == "." || name == "/" {
-		return "java" + strconv.Itoa(required) + ".zip"
+		return "java" + strconv.Itoa(rt.Major) + ".zip"
 	}
 	return name
 }
 
-func javaURLForVersion(urls server.JavaURLs, required int) string {
-	switch required {
-	case 8:
-		return urls.Java8
-	case 17:
-		return urls.Java17
-	case 21:
-		return urls.Java21
-	default:
-		return ""
-	}
-}
-
-func buildJavaDownloadRequest(ctx context.Context, srv *server.Client, raw string) (*http.Request, error) {
+// buildDownloadRequest — абсолютный URL запрашивается как есть, путь на
+// сервере сборки — подписанным запросом.
+func buildDownloadRequest(ctx context.Context, srv *server.Client, raw string) (*http.Request, error) {
 	trimmed := strings.TrimSpace(raw)
 	if strings.HasPrefix(trimmed, "http://") || strings.HasPrefix(trimmed, "https://") {
 		return http.NewRequestWithContext(ctx, http.MethodGet, trimmed, nil)
@@ -612,14 +831,23 @@ func buildJavaDownloadRequest(ctx context.Context, srv *server.Client, raw strin
 	return http.NewRequestWithContext(ctx, http.MethodGet, trimmed, nil)
 }
 
-func findInstalledJava(baseDir string, required int) string {
+// findInstalledJava ищет Java из ссылки сервера (java/java<N>), затем
+// рантайм Mojang (java/<component>); без требований — любую.
+func findInstalledJava(probes *java.ProbeCache, baseDir string, required mojang.JavaVersion) string {
 	root := javaBaseDir(baseDir)
-	if required > 0 {
-		targetDir := javaVersionDir(baseDir, required)
-		slog.Debug("launcher: searching java", "dir", targetDir, "required", required)
-		if path := findJavaInDirTree(targetDir, required); path != "" {
+	if required.MajorVersion > 0 {
+		targetDir := javaVersionDir(baseDir, required.MajorVersion)
+		slog.Debug("launcher: searching java", "dir", targetDir, "required", required.MajorVersion)
+		if path := probes.FindInTree(targetDir, required.MajorVersion); path != "" {
 			return path
 		}
+		if required.Component != "" {
+			// Недокачанный рантайм не считается установленным.
+			runtimeDir := javaRuntimeDir(baseDir, required.Component)
+			if java.InstalledRuntime(runtimeDir) != "" {
+				return java.Executable(runtimeDir)
+			}
+		}
 		return ""
 	}
 	entries, err := os.ReadDir(root)
@@ -630,7 +858,7 @@ func findInstalledJava(baseDir string, required int) string {
 		if !entry.IsDir() {
 			continue
 		}
-		path := findJavaInDirTree(filepath.Join(root, entry.Name()), required)
+		path := probes.FindInTree(filepath.Join(root, entry.Name()), 0)
 		if path != "" {
 			return path
 		}
@@ -638,86 +866,42 @@ func findInstalledJava(baseDir string, required int) string {
 	return ""
 }
 
-func findJavaInDirTree(dir string, required int) string {
-	if dir == "" {
-		return ""
-	}
-	if path := findJavaInDirWithMajor(dir, required); path != "" {
-		return path
-	}
-	var foundJavaW string
-	var foundJava string
-	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
-		if err != nil || d.IsDir() {
-			return nil
-		}
-		name := strings.ToLower(d.Name())
-		isJavaW := name == "javaw.exe"
-		isJava := name == "java.exe"
-		if !isJavaW && !isJava {
-			return nil
-		}
-		if strings.ToLower(filepath.Base(filepath.Dir(path))) != "bin" {
-			return nil
-		}
-		if required > 0 {
-			major, err := java.GetJavaMajor(path)
-			if err != nil || major != required {
-				return nil
+// resolveRequiredJava — Java для сборки: javaVersion из JSON версии или её
+// родителей, а пока версия не установлена — по номеру версии игры.
+func resolveRequiredJava(baseDir string, inst *config.Instance, manifest *server.Manifest) mojang.JavaVersion {
+	for _, id := range []string{resolveVersionID(inst), inst.GameVersion} {
+		// Цепочка может быть неполной, если родитель ещё не скачан.
+		chain, _ := loadVersionChain(baseDir, id)
+		for _, meta := range chain {
+			if meta.JavaVersion != nil && meta.JavaVersion.MajorVersion > 0 {
+				return *meta.JavaVersion
 			}
 		}
-		if isJavaW {
-			foundJavaW = path
-		} else if foundJava == "" {
-			foundJava = path
-		}
-		return nil
-	})
-	if foundJavaW != "" {
-		return foundJavaW
-	}
-	if foundJava != "" {
-		return foundJava
 	}
-	return ""
-}
-
-func findJavaInDir(dir string) string {
-	path := filepath.Join(dir, "bin", "javaw.exe")
-	if _, err := os.Stat(path); err == nil {
-		return path
+	version := strings.TrimSpace(inst.GameVersion)
+	if manifest != nil && strings.TrimSpace(manifest.Dependencies.GameVersion) != "" {
+		version = manifest.Dependencies.GameVersion
 	}
-	path = filepath.Join(dir, "bin", "java.exe")
-	if _, err := os.Stat(path); err == nil {
-		return path
+	if required, ok := javaRequiredForMinecraft(version); ok {
+		return mojang.JavaVersion{Component: javaComponentForMajor(required), MajorVersion: required}
 	}
-	return ""
+	return mojang.JavaVersion{}
 }
 
-func findJavaInDirWithMajor(dir string, required int) string {
-	if required <= 0 {
-		return findJavaInDir(dir)
-	}
-	path := findJavaInDir(dir)
-	if path == "" {
-		return ""
-	}
-	major, err := java.GetJavaMajor(path)
-	if err != nil || major != required {
+// javaComponentForMajor — компонент рантайма Mojang для major-версии Java.
+func javaComponentForMajor(major int) string {
+	switch major {
+	case 8:
+		return "jre-legacy"
+	case 16:
+		return "java-runtime-alpha"
+	case 17:
+		return "java-runtime-gamma"
+	case 21:
+		return "java-runtime-delta"
+	default:
 		return ""
 	}
-	return path
-}
-
-func resolveRequiredJava(manifest *server.Manifest, gameVersion string) int {
-	version := strings.TrimSpace(gameVersion)
-	if manifest != nil && strings.TrimSpace(manifest.Dependencies.GameVersion) != "" {
-		version = manifest.Dependencies.GameVersion
-	}
-	if required, ok := javaRequiredForMinecraft(version); ok {
-		return required
-	}
-	return 0
 }
 
 func javaRequiredForMinecraft(version string) (int, bool) {
@@ -751,84 +935,21 @@ func javaRequiredForMinecraft(version string) (int, bool) {
 	}
 }
 
-func resolveVersionID(cfg *config.Config) string {
-	if cfg == nil {
+func resolveVersionID(inst *config.Instance) string {
+	if inst == nil {
 		return ""
 	}
-	switch cfg.Loader {
+	switch inst.Loader {
 	case "fabric":
-		if cfg.LoaderVersion != "" {
-			return "fabric-loader-" + cfg.LoaderVersion + "-" + cfg.GameVersion
+		if inst.LoaderVersion != "" {
+			return "fabric-loader-" + inst.LoaderVersion + "-" + inst.GameVersion
 		}
 	case "forge", "neoforge":
-		if cfg.LoaderVersion != "" {
-			return cfg.Loader + "-" + cfg.LoaderVersion
+		if inst.LoaderVersion != "" {
+			return inst.Loader + "-" + inst.LoaderVersion
 		}
 	}
-	return cfg.GameVersion
-}
-
-func cleanInstallDir(baseDir string) error {
-	if strings.TrimSpace(baseDir) == "" {
-		return errors.New("install dir is empty")
-	}
-	
-	// Получаем абсолютный путь для безопасности
-	absBaseDir, err := filepath.Abs(baseDir)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
-	}
-	baseDir = absBaseDir
-	
-	// Папки и файлы, которые нужно удалить (всё кроме java и файлов лаунчера)
-	// Файлы лаунчера (launcher.json, profile.json) хранятся в %APPDATA%/shinecore, не в InstallDir
-	itemsToRemove := []string{
-		"versions",
-		"libraries",
-		"mods",
-		"assets",
-		"bin",
-		"logs",
-		"saves",
-		"resourcepacks",
-		"shaderpacks",
-		"config",
-		"screenshots",
-		"options.txt",
-		"optionsof.txt",
-		"optionsshaders.txt",
-		"usercache.json",
-		"usernamecache.json",
-		"installers", // Временные установщики Forge/NeoForge
-		"tmp",        // Временные файлы установки
-	}
-	
-	javaDir := filepath.Join(baseDir, "java")
-	
-	// Удаляем все указанные папки и файлы
-	removedCount := 0
-	for _, item := range itemsToRemove {
-		path := filepath.Join(baseDir, item)
-		if _, err := os.Stat(path); err == nil {
-			if err := os.RemoveAll(path); err != nil {
-				slog.Warn("launcher: failed to remove item", "path", path, "error", err)
-				// Продолжаем удаление остальных, но логируем ошибку
-			} else {
-				removedCount++
-			}
-		}
-	}
-	
-	// Убеждаемся, что папка java осталась и не была удалена
-	if err := os.MkdirAll(javaDir, 0o755); err != nil {
-		return fmt.Errorf("failed to preserve java dir: %w", err)
-	}
-	
-	slog.Info("launcher: install dir cleaned", 
-		"base_dir", baseDir, 
-		"removed_items", removedCount,
-		"preserved", "java, launcher files in %APPDATA%")
-	return nil
+	return inst.GameVersion
 }
 
 func runJavaInstaller(ctx context.Context, installerPath string) error {
@@ -842,12 +963,7 @@ func runJavaInstaller(ctx context.Context, installerPath string) error {
 	default:
 		return errors.New("unsupported java installer: " + ext)
 	}
-	if runtime.GOOS == "windows" {
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			HideWindow:    true,
-			CreationFlags: 0x08000000, // CREATE_NO_WINDOW
-		}
-	}
+	system.HideWindow(cmd)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return errors.New("java installer failed: " + string(output))
@@ -855,11 +971,11 @@ func runJavaInstaller(ctx context.Context, installerPath string) error {
 	return nil
 }
 
-func modKey(path string) string {
+func fileKey(path string) string {
 	return strings.ToLower(filepath.ToSlash(path))
 }
 
-func listLocalMods(root string) (map[string]string, error) {
+func listLocalFiles(root string) (map[string]string, error) {
 	result := map[string]string{}
 	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
@@ -872,7 +988,7 @@ func listLocalMods(root string) (map[string]string, error) {
 		if err != nil {
 			return err
 		}
-		result[modKey(rel)] = path
+		result[fileKey(rel)] = path
 		return nil
 	})
 	if err != nil {
@@ -889,8 +1005,22 @@ type progressTracker struct {
 	totals     map[string]int
 	done       map[string]int
 	mu         sync.Mutex
+
+	step       string
+	bytesDone  int64
+	bytesTotal int64
+	file       string
+	speed      float64
+	sampleAt   time.Time
+	sampleDone int64
+	emittedAt  time.Time
 }
 
+const (
+	speedSampleInterval = 500 * time.Millisecond
+	bytesEmitInterval   = 200 * time.Millisecond
+)
+
 func newProgressTracker(onProgress func(ProgressEvent)) *progressTracker {
 	return &progressTracker{
 		onProgress: onProgress,
@@ -928,11 +1058,51 @@ func (p *progressTracker) Increment(step string) {
 	p.emit(step)
 }
 
+// Bytes принимает суммарный прогресс планировщика загрузок. Скорость
+// сглаживается экспоненциально, события отправляются не чаще bytesEmitInterval.
+func (p *progressTracker) Bytes(progress download.Progress) {
+	now := time.Now()
+	p.mu.Lock()
+	p.bytesDone = progress.BytesDownloaded
+	p.bytesTotal = progress.BytesTotal
+	if progress.File != "" {
+		p.file = progress.File
+	}
+	if p.sampleAt.IsZero() {
+		p.sampleAt = now
+		p.sampleDone = progress.BytesDownloaded
+	} else if elapsed := now.Sub(p.sampleAt); elapsed >= speedSampleInterval {
+		current := float64(progress.BytesDownloaded-p.sampleDone) / elapsed.Seconds()
+		if current < 0 {
+			current = 0
+		}
+		if p.speed == 0 {
+			p.speed = current
+		} else {
+			p.speed = 0.3*current + 0.7*p.speed
+		}
+		p.sampleAt = now
+		p.sampleDone = progress.BytesDownloaded
+	}
+	finished := progress.BytesTotal > 0 && progress.BytesDownloaded >= progress.BytesTotal
+	if !finished && now.Sub(p.emittedAt) < bytesEmitInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.emittedAt = now
+	step := p.step
+	p.mu.Unlock()
+	p.emit(step)
+}
+
 func (p *progressTracker) emit(step string) {
 	if p.onProgress == nil {
 		return
 	}
 	p.mu.Lock()
+	if step != "" {
+		p.step = step
+	}
 	totalAll := 0
 	doneAll := 0
 	for key, total := range p.totals {
@@ -942,12 +1112,26 @@ func (p *progressTracker) emit(step string) {
 		totalAll += total
 		doneAll += minInt(p.done[key], total)
 	}
+	evt := ProgressEvent{
+		Step:       p.step,
+		Done:       doneAll,
+		Total:      totalAll,
+		BytesDone:  p.bytesDone,
+		BytesTotal: p.bytesTotal,
+		Speed:      p.speed,
+		File:       p.file,
+	}
 	p.mu.Unlock()
-	progress := 0.0
-	if totalAll > 0 {
-		progress = float64(doneAll) / float64(totalAll)
+	switch {
+	case evt.BytesTotal > 0:
+		evt.Progress = math.Min(float64(evt.BytesDone)/float64(evt.BytesTotal), 1)
+	case totalAll > 0:
+		evt.Progress = float64(doneAll) / float64(totalAll)
 	}
-	p.onProgress(ProgressEvent{Step: step, Done: doneAll, Total: totalAll, Progress: progress})
+	if evt.Speed > 0 && evt.BytesTotal > evt.BytesDone {
+		evt.ETA = time.Duration(float64(evt.BytesTotal-evt.BytesDone) / evt.Speed * float64(time.Second)).Round(time.Second)
+	}
+	p.onProgress(evt)
 }
 
 func minInt(a, b int) int {
@@ -960,3 +1144,19 @@ func minInt(a, b int) int {
 func newHTTPClient() *http.Client {
 	return &http.Client{Timeout: 10 * time.Minute}
 }
+
+// newAPIClient — клиент для коротких запросов к API (манифест, каналы,
+// обновления): зависший сервер не должен держать интерфейс.
+func newAPIClient() *http.Client {
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+// newScheduler — один планировщик на операцию: моды, библиотеки и ассеты
+// делят общие лимиты соединений и общий прогресс в байтах.
+func newScheduler(client *http.Client, tracker *progressTracker) *download.Scheduler {
+	return download.NewScheduler(client, download.SchedulerOptions{
+		MaxConcurrent: 16,
+		MaxPerHost:    8,
+		OnProgress:    tracker.Bytes,
+	})
+}
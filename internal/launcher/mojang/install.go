@@ -24,7 +24,9 @@ type InstallRequest struct {
 	Version   string
 	Client    *http.Client
 	OnProgress func(step string, done, total int)
-	AssetWorkers int
+	Cache     download.Cache
+	// Scheduler — общий планировщик загрузок; nil — создаётся свой.
+	Scheduler *download.Scheduler
 }
 
 func EnsureInstalled(ctx context.Context, req InstallRequest) (*VersionMetadata, error) {
@@ -35,6 +37,10 @@ func EnsureInstalled(ctx context.Context, req InstallRequest) (*VersionMetadata,
 	if client == nil {
 		client = http.DefaultClient
 	}
+	sched := req.Scheduler
+	if sched == nil {
+		sched = download.NewScheduler(client, download.SchedulerOptions{})
+	}
 
 	meta, err := fetchVersionMetadata(ctx, client, req.Version)
 	if err != nil {
@@ -50,21 +56,21 @@ func EnsureInstalled(ctx context.Context, req InstallRequest) (*VersionMetadata,
 		return nil, err
 	}
 
-	if err := ensureClientJar(ctx, client, req.BaseDir, meta); err != nil {
+	if err := ensureClientJar(ctx, sched, req.Cache, req.BaseDir, meta); err != nil {
 		return nil, err
 	}
-	if err := ensureLibraries(ctx, client, req.BaseDir, meta, req.OnProgress); err != nil {
+	if err := ensureLibraries(ctx, sched, req.Cache, req.BaseDir, meta, req.OnProgress); err != nil {
 		return nil, err
 	}
-	if err := ensureAssets(ctx, client, req.BaseDir, meta, req.OnProgress, req.AssetWorkers); err != nil {
+	if err := ensureAssets(ctx, client, sched, req.Cache, req.BaseDir, meta, req.OnProgress); err != nil {
 		return nil, err
 	}
 	return meta, nil
 }
 
-func EnsureLibrariesForVersion(ctx context.Context, baseDir, version string, client *http.Client) error {
-	if client == nil {
-		client = http.DefaultClient
+func EnsureLibrariesForVersion(ctx context.Context, baseDir, version string, sched *download.Scheduler, cache download.Cache) error {
+	if sched == nil {
+		sched = download.NewScheduler(nil, download.SchedulerOptions{})
 	}
 	metaPath := filepath.Join(baseDir, "versions", version, version+".json")
 	data, err := os.ReadFile(metaPath)
@@ -75,7 +81,7 @@ func EnsureLibrariesForVersion(ctx context.Context, baseDir, version string, cli
 	if err := json.Unmarshal(data, &meta); err != nil {
 		return err
 	}
-	return ensureLibraries(ctx, client, baseDir, &meta, nil)
+	return ensureLibraries(ctx, sched, cache, baseDir, &meta, nil)
 }
 
 func fetchVersionMetadata(ctx context.Context, client *http.Client, version string) (*VersionMetadata, error) {
@@ -124,57 +130,52 @@ func fetchVersionMetadata(ctx context.Context, client *http.Client, version stri
 	return &meta, nil
 }
 
-func ensureClientJar(ctx context.Context, client *http.Client, baseDir string, meta *VersionMetadata) error {
+func ensureClientJar(ctx context.Context, sched *download.Scheduler, cache download.Cache, baseDir string, meta *VersionMetadata) error {
 	downloadInfo := meta.Downloads.Client
-	dst := filepath.Join(baseDir, "versions", meta.ID, meta.ID+".jar")
-	return download.EnsureFile(ctx, client, downloadInfo.URL, dst, downloadInfo.Size, "", nil)
+	group := sched.NewGroup(ctx, nil)
+	group.Add(download.Job{
+		URL:      downloadInfo.URL,
+		Dst:      filepath.Join(baseDir, "versions", meta.ID, meta.ID+".jar"),
+		Size:     downloadInfo.Size,
+		Checksum: download.SHA1(downloadInfo.Sha1),
+		Cache:    cache,
+		Priority: download.PriorityHigh,
+	})
+	return group.Wait()
 }
 
-func ensureLibraries(ctx context.Context, client *http.Client, baseDir string, meta *VersionMetadata, onProgress func(step string, done, total int)) error {
-	total := 0
+func ensureLibraries(ctx context.Context, sched *download.Scheduler, cache download.Cache, baseDir string, meta *VersionMetadata, onProgress func(step string, done, total int)) error {
+	var jobs []download.Job
 	for _, lib := range meta.Libraries {
-		if !allowLibrary(lib.Rules) {
+		if !AllowLibrary(lib.Rules) {
 			continue
 		}
-		total++
 		if lib.Downloads != nil && lib.Downloads.Artifact != nil {
-			total += len(resolveNatives(lib))
-		}
-	}
-	done := 0
-	for _, lib := range meta.Libraries {
-		if !allowLibrary(lib.Rules) {
-			continue
-		}
-		if lib.Downloads != nil && lib.Downloads.Artifact != nil {
-			if err := downloadLibrary(ctx, client, baseDir, lib.Downloads.Artifact); err != nil {
-				return err
-			}
+			jobs = append(jobs, libraryJob(cache, baseDir, lib.Downloads.Artifact))
 		} else {
-			if err := downloadLibraryByName(ctx, client, baseDir, lib.Name, lib.URL); err != nil {
-				return err
-			}
+			jobs = append(jobs, libraryByNameJob(cache, baseDir, lib))
 		}
-		done++
-		if onProgress != nil {
-			onProgress("libraries", done, total)
+		for _, native := range NativeArtifacts(lib) {
+			jobs = append(jobs, libraryJob(cache, baseDir, &native))
 		}
-		for _, native := range resolveNatives(lib) {
-			if err := downloadLibrary(ctx, client, baseDir, &native); err != nil {
-				return err
-			}
-			done++
-			if onProgress != nil {
-				onProgress("libraries", done, total)
-			}
+	}
+	total := len(jobs)
+	var done int64
+	group := sched.NewGroup(ctx, func(download.Job) {
+		n := atomic.AddInt64(&done, 1)
+		if onProgress != nil {
+			onProgress("libraries", int(n), total)
 		}
+	})
+	for _, job := range jobs {
+		group.Add(job)
 	}
-	return nil
+	return group.Wait()
 }
 
-func ensureAssets(ctx context.Context, client *http.Client, baseDir string, meta *VersionMetadata, onProgress func(step string, done, total int), workers int) error {
+func ensureAssets(ctx context.Context, client *http.Client, sched *download.Scheduler, cache download.Cache, baseDir string, meta *VersionMetadata, onProgress func(step string, done, total int)) error {
 	indexPath := filepath.Join(baseDir, "assets", "indexes", meta.AssetIndex.ID+".json")
-	if err := download.EnsureFile(ctx, client, meta.AssetIndex.URL, indexPath, meta.AssetIndex.Size, "", nil); err != nil {
+	if err := download.EnsureFileCached(ctx, client, cache, meta.AssetIndex.URL, indexPath, meta.AssetIndex.Size, download.SHA1(meta.AssetIndex.Sha1), nil); err != nil {
 		return err
 	}
 	data, err := os.ReadFile(indexPath)
@@ -189,81 +190,62 @@ func ensureAssets(ctx context.Context, client *http.Client, baseDir string, meta
 	if total == 0 {
 		return nil
 	}
-	if workers <= 0 {
-		workers = 8
-	}
-	type assetJob struct {
-		Hash string
-		Size int64
-	}
-	jobs := make(chan assetJob)
-	errCh := make(chan error, 1)
-	doneCh := make(chan struct{})
 	var doneCount int64
-
-	for i := 0; i < workers; i++ {
-		go func() {
-			for job := range jobs {
-				hash := job.Hash
-				if len(hash) < 2 {
-					continue
-				}
-				sub := hash[:2]
-				dst := filepath.Join(baseDir, "assets", "objects", sub, hash)
-				url := "https://resources.download.minecraft.net/" + sub + "/" + hash
-				if err := download.EnsureFile(ctx, client, url, dst, job.Size, "", nil); err != nil {
-					select {
-					case errCh <- err:
-					default:
-					}
-					continue
-				}
-				atomic.AddInt64(&doneCount, 1)
-				if onProgress != nil {
-					onProgress("assets", int(atomic.LoadInt64(&doneCount)), total)
-				}
-			}
-			doneCh <- struct{}{}
-		}()
-	}
-
+	group := sched.NewGroup(ctx, func(download.Job) {
+		n := atomic.AddInt64(&doneCount, 1)
+		if onProgress != nil {
+			onProgress("assets", int(n), total)
+		}
+	})
 	for _, obj := range index.Objects {
-		select {
-		case err := <-errCh:
-			return err
-		default:
+		hash := obj.Hash
+		if len(hash) < 2 {
+			continue
 		}
-		jobs <- assetJob{Hash: obj.Hash, Size: obj.Size}
-	}
-	close(jobs)
-
-	for i := 0; i < workers; i++ {
-		<-doneCh
-	}
-	select {
-	case err := <-errCh:
-		return err
-	default:
-		return nil
-	}
+		sub := hash[:2]
+		group.Add(download.Job{
+			URL:      "https://resources.download.minecraft.net/" + sub + "/" + hash,
+			Dst:      filepath.Join(baseDir, "assets", "objects", sub, hash),
+			Size:     obj.Size,
+			Checksum: download.SHA1(hash),
+			Cache:    cache,
+			Priority: download.PriorityLow,
+		})
+	}
+	return group.Wait()
 }
 
-func downloadLibrary(ctx context.Context, client *http.Client, baseDir string, artifact *LibraryArtifact) error {
-	dst := filepath.Join(baseDir, "libraries", filepath.FromSlash(artifact.Path))
-	return download.EnsureFile(ctx, client, artifact.URL, dst, artifact.Size, "", nil)
+func libraryJob(cache download.Cache, baseDir string, artifact *LibraryArtifact) download.Job {
+	return download.Job{
+		URL:      artifact.URL,
+		Dst:      filepath.Join(baseDir, "libraries", filepath.FromSlash(artifact.Path)),
+		Size:     artifact.Size,
+		Checksum: download.SHA1(artifact.Sha1),
+		Cache:    cache,
+		Priority: download.PriorityHigh,
+	}
 }
 
-func downloadLibraryByName(ctx context.Context, client *http.Client, baseDir, name, baseURL string) error {
-	path := libraryPath(name)
+// libraryByNameJob — загрузка библиотеки по Maven-имени; хеш и размер берутся
+// из самой записи (так их отдаёт Fabric), без них файл не проверяется.
+func libraryByNameJob(cache download.Cache, baseDir string, lib Library) download.Job {
+	path := LibraryPath(lib.Name)
 	url := libBaseURL + path
-	if strings.TrimSpace(baseURL) != "" {
-		url = strings.TrimRight(baseURL, "/") + "/" + path
+	if strings.TrimSpace(lib.URL) != "" {
+		url = strings.TrimRight(lib.URL, "/") + "/" + path
+	}
+	return download.Job{
+		URL:      url,
+		Dst:      filepath.Join(baseDir, "libraries", filepath.FromSlash(path)),
+		Size:     lib.Size,
+		Checksum: download.SHA1(lib.Sha1),
+		Cache:    cache,
+		Priority: download.PriorityHigh,
 	}
-	dst := filepath.Join(baseDir, "libraries", filepath.FromSlash(path))
-	return download.EnsureFile(ctx, client, url, dst, 0, "", nil)
 }
 
-func libraryPath(name string) string {
+// LibraryPath — путь Maven-артефакта "group:artifact:version[:classifier][@ext]".
+func LibraryPath(name string) string {
 	parts := strings.Split(name, ":")
 	if len(parts) < 3 {
 		return ""
@@ -289,7 +271,8 @@ func libraryPath(name string) string {
 	return group + "/" + artifact + "/" + version + "/" + file
 }
 
-func resolveNatives(lib Library) []LibraryArtifact {
+// NativeArtifacts — jar с нативными библиотеками для текущей ОС, если есть.
+func NativeArtifacts(lib Library) []LibraryArtifact {
 	if len(lib.Natives) == 0 || lib.Downloads == nil || len(lib.Downloads.Classifiers) == 0 {
 		return nil
 	}
@@ -310,7 +293,8 @@ func resolveNatives(lib Library) []LibraryArtifact {
 	return []LibraryArtifact{artifact}
 }
 
-func allowLibrary(rules []Rule) bool {
+// AllowLibrary применяет правила os к библиотеке.
+func AllowLibrary(rules []Rule) bool {
 	if len(rules) == 0 {
 		return true
 	}
@@ -27,10 +27,17 @@ type VersionMetadata struct {
 	Time               string            `json:"time,omitempty"`
 	Logging            map[string]any    `json:"logging,omitempty"`
 	MinimumLauncherVer int               `json:"minimumLauncherVersion,omitempty"`
-	JavaVersion        map[string]any    `json:"javaVersion,omitempty"`
+	JavaVersion        *JavaVersion      `json:"javaVersion,omitempty"`
 	CompatibilityRules []map[string]any  `json:"compatibilityRules,omitempty"`
 }
 
+// JavaVersion — рантайм Java, который нужен версии: компонент в манифесте
+// java-runtime Mojang (java-runtime-gamma, jre-legacy, ...) и major-версия.
+type JavaVersion struct {
+	Component    string `json:"component"`
+	MajorVersion int    `json:"majorVersion"`
+}
+
 type VersionArguments struct {
 	Game []Argument `json:"game"`
 	Jvm  []Argument `json:"jvm"`
@@ -101,6 +108,8 @@ type Library struct {
 	Name      string              `json:"name"`
 	Downloads *LibraryDownloads   `json:"downloads,omitempty"`
 	URL       string              `json:"url,omitempty"`
+	Sha1      string              `json:"sha1,omitempty"` // Fabric: хеш Maven-артефакта
+	Size      int64               `json:"size,omitempty"`
 	Natives   map[string]string   `json:"natives,omitempty"`
 	Rules     []Rule              `json:"rules,omitempty"`
 	Extract   *LibraryExtract     `json:"extract,omitempty"`
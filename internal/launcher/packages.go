@@ -0,0 +1,220 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"shinecore/internal/launcher/download"
+	"shinecore/internal/launcher/server"
+)
+
+// syncPackages приводит все группы файлов манифеста (mods, config,
+// resourcepacks, ...) к манифесту по правилам перезаписи и политике групп.
+func syncPackages(ctx context.Context, sched *download.Scheduler, cache download.Cache, srv *server.Client, baseDir string, manifest *server.Manifest, tracker *progressTracker) error {
+	if manifest == nil {
+		return nil
+	}
+	if tracker == nil {
+		tracker = newProgressTracker(nil)
+	}
+	state := loadSyncState(baseDir)
+	var syncErr error
+	for _, group := range manifest.Packages.Groups {
+		if err := syncGroup(ctx, sched, cache, srv, baseDir, manifest, group, state, tracker); err != nil {
+			syncErr = err
+			break
+		}
+	}
+	// Версию записываем только после полного применения: после ошибки в
+	// сборке смесь файлов двух версий, и дельта от прежней версии неверна.
+	if syncErr == nil {
+		state.ManifestVersion = manifest.Version
+	} else {
+		state.ManifestVersion = ""
+	}
+	// Состояние сохраняем и после ошибки: уже поставленные файлы остаются нашими.
+	if err := state.save(baseDir); err != nil {
+		slog.Warn("sync: save state failed", "error", err)
+	}
+	return syncErr
+}
+
+func syncGroup(ctx context.Context, sched *download.Scheduler, cache download.Cache, srv *server.Client, baseDir string, manifest *server.Manifest, group server.PackageGroup, state *syncState, tracker *progressTracker) error {
+	targetDir, err := groupDir(baseDir, group)
+	if err != nil {
+		return err
+	}
+	_ = os.MkdirAll(targetDir, 0o755)
+	overwrite := overwriteMode(group)
+	policy := groupPolicy(manifest, group)
+
+	expected, err := groupFiles(group)
+	if err != nil {
+		return err
+	}
+	local, err := listLocalFiles(targetDir)
+	if err != nil {
+		return err
+	}
+	prevOwned := state.group(group.Target)
+	extras := groupExtras(ctx, policy, expected, local, prevOwned)
+
+	step := group.Name
+	tracker.SetTotal(step, len(expected)+len(extras))
+	slog.Info("sync: group start", "group", group.Name, "target", group.Target,
+		"expected", len(expected), "extras", len(extras), "overwrite", overwrite, "mode", syncMode(policy))
+
+	// owned — файлы группы, содержимое которых поставил лаунчер.
+	var mu sync.Mutex
+	owned := make(map[string]ownedFile, len(expected))
+	downloaded := 0
+	batch := sched.NewGroup(ctx, func(download.Job) {
+		mu.Lock()
+		downloaded++
+		mu.Unlock()
+		tracker.Increment(step)
+	})
+	for key, file := range expected {
+		dst := filepath.Join(targetDir, filepath.FromSlash(file.Path))
+		record := ownedFile{Size: file.Size, Sha256: strings.ToLower(file.Sha256)}
+		if overwrite == server.OverwriteAlways {
+			// Файл уже совпадает с манифестом: загрузка не нужна, а не
+			// изменившийся с прошлой проверки файл и не хешируется.
+			if sameContent(ctx, dst, record.Sha256) {
+				owned[key] = record
+				tracker.Increment(step)
+				continue
+			}
+		} else if keep, ours := keepLocal(ctx, overwrite, dst, file, prevOwned[key]); keep {
+			if ours {
+				owned[key] = record
+			} else if prev, ok := prevOwned[key]; ok {
+				owned[key] = prev
+			}
+			tracker.Increment(step)
+			continue
+		}
+		owned[key] = record
+		url := srv.ResolveURL(file.URL)
+		batch.Add(download.Job{
+			URL: url,
+			// Подписываем запрос в момент загрузки: в очереди подпись могла бы устареть.
+			Request: func(ctx context.Context) (*http.Request, error) {
+				return srv.SignedRequest(ctx, http.MethodGet, url)
+			},
+			Dst:      dst,
+			Size:     file.Size,
+			Checksum: download.SHA256(file.Sha256),
+			// Файлы последнего рабочего релиза берутся из хранилища без сервера.
+			Cache:    cache,
+			Priority: download.PriorityNormal,
+		})
+	}
+	if err := batch.Wait(); err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, fullPath := range extras {
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			slog.Warn("sync: remove failed", "path", fullPath, "error", err)
+			if rel, relErr := filepath.Rel(targetDir, fullPath); relErr == nil {
+				if prev, ok := prevOwned[fileKey(rel)]; ok {
+					owned[fileKey(rel)] = prev
+				}
+			}
+		} else {
+			removed++
+		}
+		tracker.Increment(step)
+	}
+	state.setGroup(group.Target, owned)
+
+	slog.Info("sync: group complete", "group", group.Name, "downloaded", downloaded, "removed", removed)
+	return nil
+}
+
+// keepLocal решает для правил missing и merge, оставить ли локальный файл.
+// ours — содержимое файла совпадает с манифестом.
+func keepLocal(ctx context.Context, overwrite, dst string, file server.FilePackage, prev ownedFile) (keep, ours bool) {
+	if _, err := os.Stat(dst); err != nil {
+		return false, false
+	}
+	current := sameContent(ctx, dst, file.Sha256)
+	if file.Sha256 == "" {
+		current, _ = download.VerifyFile(ctx, dst, file.Size, download.SHA256(file.Sha256))
+	}
+	if current || overwrite == server.OverwriteMissing {
+		return true, current
+	}
+	// merge: обновляем, только если игрок не менял файл после нашей установки.
+	if prev.Sha256 != "" {
+		if unchanged, _ := download.VerifyFile(ctx, dst, 0, download.SHA256(prev.Sha256)); unchanged {
+			return false, false
+		}
+	}
+	return true, false
+}
+
+func overwriteMode(group server.PackageGroup) string {
+	switch mode := strings.ToLower(strings.TrimSpace(group.Overwrite)); mode {
+	case "", server.OverwriteAlways:
+		return server.OverwriteAlways
+	case server.OverwriteMissing, server.OverwriteMerge:
+		return mode
+	default:
+		// Неизвестное правило: не затираем файлы игрока.
+		slog.Warn("sync: unknown overwrite rule, using missing", "group", group.Name, "overwrite", group.Overwrite)
+		return server.OverwriteMissing
+	}
+}
+
+// reservedDirs — каталоги лаунчера верхнего уровня. Папка сборки по
+// умолчанию совпадает с папкой установки, и группа с таким target при
+// строгой синхронизации удалила бы общие файлы игры или состояние лаунчера.
+var reservedDirs = map[string]bool{
+	"assets":     true,
+	"bin":        true,
+	"instances":  true,
+	"java":       true,
+	"libraries":  true,
+	"runtime":    true,
+	"versions":   true,
+	stateDirName: true,
+}
+
+// groupDir — каталог группы внутри папки сборки.
+func groupDir(baseDir string, group server.PackageGroup) (string, error) {
+	target := filepath.Clean(filepath.FromSlash(group.Target))
+	if group.Target == "" || filepath.IsAbs(target) || target == "." || !filepath.IsLocal(target) {
+		return "", errors.New("invalid package group target: " + group.Target)
+	}
+	// Сравнение без учёта регистра: в Windows и macOS "Libraries" — тот же каталог.
+	top, _, _ := strings.Cut(filepath.ToSlash(target), "/")
+	if reservedDirs[strings.ToLower(top)] {
+		return "", fmt.Errorf("package group %s targets launcher directory %s", group.Name, group.Target)
+	}
+	return filepath.Join(baseDir, target), nil
+}
+
+// groupFiles — файлы группы по ключу fileKey; пути, выходящие за каталог группы, отклоняются.
+func groupFiles(group server.PackageGroup) (map[string]server.FilePackage, error) {
+	expected := make(map[string]server.FilePackage, len(group.Files))
+	for _, file := range group.Files {
+		if file.Path == "" || file.URL == "" {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(file.Path)) {
+			return nil, errors.New("invalid package path in group " + group.Name + ": " + file.Path)
+		}
+		expected[fileKey(file.Path)] = file
+	}
+	return expected, nil
+}
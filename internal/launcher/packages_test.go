@@ -0,0 +1,121 @@
+package launcher
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shinecore/internal/launcher/server"
+)
+
+func TestGroupDir(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "install")
+	tests := []struct {
+		target  string
+		want    string
+		wantErr string
+	}{
+		{target: "mods", want: "mods"},
+		{target: "config/forge", want: filepath.Join("config", "forge")},
+		{target: "kubejs", want: "kubejs"},
+		{target: "config/../resourcepacks", want: "resourcepacks"},
+		{target: "", wantErr: "invalid package group target"},
+		{target: ".", wantErr: "invalid package group target"},
+		{target: "../outside", wantErr: "invalid package group target"},
+		{target: "/etc", wantErr: "invalid package group target"},
+		{target: "libraries", wantErr: "launcher directory"},
+		{target: "versions/1.20.1", wantErr: "launcher directory"},
+		{target: "Assets", wantErr: "launcher directory"},
+		{target: "runtime", wantErr: "launcher directory"},
+		{target: "java", wantErr: "launcher directory"},
+		{target: "bin", wantErr: "launcher directory"},
+		{target: "instances/other", wantErr: "launcher directory"},
+		{target: ".shinecore", wantErr: "launcher directory"},
+		{target: "mods/../libraries", wantErr: "launcher directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			got, err := groupDir(base, server.PackageGroup{Name: "g", Target: tt.target})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("groupDir(%q) = %q, %v; want error %q", tt.target, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("groupDir(%q) error = %v", tt.target, err)
+			}
+			if want := filepath.Join(base, tt.want); got != want {
+				t.Errorf("groupDir(%q) = %q, want %q", tt.target, got, want)
+			}
+		})
+	}
+}
+
+func TestSyncPackagesRecordsAppliedVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		groups  []server.PackageGroup
+		wantErr bool
+		want    string
+	}{
+		{name: "applied", groups: []server.PackageGroup{{Name: "config", Target: "config"}}, want: "8"},
+		{name: "sync failed", groups: []server.PackageGroup{{Name: "libs", Target: "libraries"}}, wantErr: true, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			prev := &syncState{Files: map[string]ownedFile{}, ManifestVersion: "7"}
+			if err := prev.save(dir); err != nil {
+				t.Fatal(err)
+			}
+			manifest := &server.Manifest{Version: "8", Packages: server.ManifestPackages{Groups: tt.groups}}
+			err := syncPackages(context.Background(), nil, nil, nil, dir, manifest, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("syncPackages() = %v, want error %v", err, tt.wantErr)
+			}
+			if got := loadSyncState(dir).ManifestVersion; got != tt.want {
+				t.Fatalf("manifest_version = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeepLocal(t *testing.T) {
+	dir := t.TempDir()
+	local := writeFiles(t, dir, map[string]string{
+		"current.cfg": "server",
+		"ours.cfg":    "old server",
+		"edited.cfg":  "edited by player",
+	})
+	file := server.FilePackage{Path: "x.cfg", Size: int64(len("server")), Sha256: sha256Hex("server")}
+	prev := ownedFile{Sha256: sha256Hex("old server")}
+
+	tests := []struct {
+		name      string
+		overwrite string
+		dst       string
+		file      server.FilePackage
+		prev      ownedFile
+		keep      bool
+		ours      bool
+	}{
+		{name: "missing file is installed", overwrite: server.OverwriteMissing, dst: filepath.Join(dir, "absent.cfg"), file: file},
+		{name: "missing keeps current file", overwrite: server.OverwriteMissing, dst: local["current.cfg"], file: file, keep: true, ours: true},
+		{name: "missing keeps player file", overwrite: server.OverwriteMissing, dst: local["edited.cfg"], file: file, prev: prev, keep: true},
+		{name: "merge keeps current file", overwrite: server.OverwriteMerge, dst: local["current.cfg"], file: file, prev: prev, keep: true, ours: true},
+		{name: "merge updates unchanged own file", overwrite: server.OverwriteMerge, dst: local["ours.cfg"], file: file, prev: prev},
+		{name: "merge keeps file edited by player", overwrite: server.OverwriteMerge, dst: local["edited.cfg"], file: file, prev: prev, keep: true},
+		{name: "merge keeps file without install record", overwrite: server.OverwriteMerge, dst: local["ours.cfg"], file: file, keep: true},
+		{name: "no hash compares size", overwrite: server.OverwriteMerge, dst: local["current.cfg"], file: server.FilePackage{Path: "x.cfg", Size: int64(len("server"))}, keep: true, ours: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, ours := keepLocal(context.Background(), tt.overwrite, tt.dst, tt.file, tt.prev)
+			if keep != tt.keep || ours != tt.ours {
+				t.Errorf("keepLocal() = %v, %v; want %v, %v", keep, ours, tt.keep, tt.ours)
+			}
+		})
+	}
+}
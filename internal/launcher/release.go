@@ -0,0 +1,433 @@
+package launcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"shinecore/internal/launcher/config"
+	"shinecore/internal/launcher/download"
+	"shinecore/internal/launcher/server"
+	"shinecore/internal/launcher/store"
+)
+
+const (
+	releaseFileName = "release.json"
+	// lkgConfirmAfter — сколько игра должна проработать без сбоя, чтобы
+	// установленный манифест стал last-known-good.
+	lkgConfirmAfter = time.Minute
+)
+
+// Причины, по которым версия манифеста считается сломанной.
+const (
+	FailureInstall = "install"
+	FailureCrash   = "crash"
+	// FailureManual — игрок откатился сам.
+	FailureManual = "manual"
+)
+
+var (
+	ErrNoLKG = errors.New("no last-known-good release to roll back to")
+
+	releaseMu sync.Mutex
+)
+
+// releaseState — <instance>/.shinecore/release.json: установленный манифест,
+// последний рабочий (LKG) и версия, на которой установка или запуск сломались.
+type releaseState struct {
+	Installed *releaseRecord  `json:"installed,omitempty"`
+	LKG       *releaseRecord  `json:"lkg,omitempty"`
+	Failed    *ReleaseFailure `json:"failed,omitempty"`
+	// RolledBack — игрок откатился на LKG: пока сервер раздаёт сломанную
+	// версию, устанавливается LKG.
+	RolledBack bool `json:"rolled_back,omitempty"`
+}
+
+// releaseRecord — версия манифеста. Manifest — справочная копия; для
+// установки манифест собирается только из Signed после проверки подписи,
+// иначе правка release.json подменила бы сборку.
+type releaseRecord struct {
+	Version    string                 `json:"version"`
+	RecordedAt string                 `json:"recorded_at"`
+	Manifest   *server.Manifest       `json:"manifest"`
+	Signed     *server.SignedManifest `json:"signed,omitempty"`
+}
+
+// ReleaseFailure — версия манифеста, с которой установка или первый запуск
+// закончились сбоем.
+type ReleaseFailure struct {
+	Version string `json:"version"`
+	Reason  string `json:"reason"`
+	Error   string `json:"error,omitempty"`
+	At      string `json:"at"`
+}
+
+// ReleaseInfo — состояние релизов сборки для интерфейса.
+type ReleaseInfo struct {
+	Installed   string          `json:"installed"`
+	LKG         string          `json:"lkg"`
+	Failed      *ReleaseFailure `json:"failed,omitempty"`
+	RolledBack  bool            `json:"rolled_back"`
+	CanRollback bool            `json:"can_rollback"`
+}
+
+// releaseVersion — версия манифеста; без version — время генерации.
+func releaseVersion(manifest *server.Manifest) string {
+	if manifest == nil {
+		return ""
+	}
+	if manifest.Version != "" {
+		return manifest.Version
+	}
+	return manifest.GeneratedAt
+}
+
+func newReleaseRecord(manifest *server.Manifest) *releaseRecord {
+	return &releaseRecord{
+		Version:    releaseVersion(manifest),
+		RecordedAt: time.Now().UTC().Format(time.RFC3339),
+		Manifest:   manifest,
+		Signed:     manifest.Signed,
+	}
+}
+
+func releasePath(instDir string) string {
+	return filepath.Join(instDir, stateDirName, releaseFileName)
+}
+
+// loadRelease читает состояние; отсутствующий или повреждённый файл — пустое.
+func loadRelease(instDir string) *releaseState {
+	state := &releaseState{}
+	data, err := os.ReadFile(releasePath(instDir))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("release: read state failed", "error", err)
+		}
+		return state
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		slog.Warn("release: state is corrupt, starting over", "error", err)
+		return &releaseState{}
+	}
+	return state
+}
+
+func (s *releaseState) save(instDir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := releasePath(instDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	_ = os.Remove(path)
+	return os.Rename(tmp, path)
+}
+
+// updateRelease изменяет состояние под блокировкой: подтверждение запуска
+// приходит из горутины процесса игры.
+func updateRelease(instDir string, change func(*releaseState) bool) {
+	releaseMu.Lock()
+	defer releaseMu.Unlock()
+	state := loadRelease(instDir)
+	if !change(state) {
+		return
+	}
+	if err := state.save(instDir); err != nil {
+		slog.Warn("release: save state failed", "error", err)
+	}
+}
+
+// restorable — по записи можно переустановить манифест: с ней сохранён подписанный исходник.
+func (r *releaseRecord) restorable() bool {
+	return r != nil && r.Manifest != nil && r.Signed != nil
+}
+
+func (s *releaseState) info() *ReleaseInfo {
+	info := &ReleaseInfo{Failed: s.Failed, RolledBack: s.RolledBack}
+	if s.Installed != nil {
+		info.Installed = s.Installed.Version
+	}
+	if s.LKG != nil {
+		info.LKG = s.LKG.Version
+		info.CanRollback = !s.RolledBack && s.LKG.restorable() && s.LKG.Version != info.Installed
+	}
+	return info
+}
+
+// instanceManifest — манифест сборки с учётом отката. После отката ставится
+// LKG, пока сервер раздаёт сломанную версию (или недоступен); новая версия
+// на сервере снимает откат.
+func instanceManifest(ctx context.Context, srv *server.Client, instDir string) (*server.Manifest, error) {
+	manifest, err := fetchManifest(ctx, srv)
+	if errors.Is(err, server.ErrClockSkew) {
+		return nil, err
+	}
+	releaseMu.Lock()
+	defer releaseMu.Unlock()
+	state := loadRelease(instDir)
+	if !state.RolledBack || !state.LKG.restorable() {
+		return manifest, err
+	}
+	if err == nil && (state.Failed == nil || releaseVersion(manifest) != state.Failed.Version) {
+		slog.Info("release: server published a new version, leaving rollback",
+			"version", releaseVersion(manifest), "lkg", state.LKG.Version)
+		state.RolledBack = false
+		if err := state.save(instDir); err != nil {
+			slog.Warn("release: save state failed", "error", err)
+		}
+		return manifest, nil
+	}
+	lkg, lkgErr := srv.OpenManifest(state.LKG.Signed)
+	if lkgErr != nil {
+		slog.Warn("release: last-known-good manifest rejected by signature check", "lkg", state.LKG.Version, "error", lkgErr)
+		return manifest, err
+	}
+	slog.Info("release: using last-known-good manifest", "lkg", state.LKG.Version)
+	return lkg, nil
+}
+
+// noteInstall записывает итог установки манифеста: успешный становится
+// установленным, неудачный — сломанной версией (если это не LKG).
+func noteInstall(instDir string, manifest *server.Manifest, err error) {
+	if manifest == nil {
+		return
+	}
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, server.ErrClockSkew)) {
+		return
+	}
+	version := releaseVersion(manifest)
+	updateRelease(instDir, func(state *releaseState) bool {
+		if err == nil {
+			state.Installed = newReleaseRecord(manifest)
+			return true
+		}
+		if state.LKG != nil && state.LKG.Version == version {
+			return false
+		}
+		state.Failed = newFailure(version, FailureInstall, err)
+		return true
+	})
+}
+
+func newFailure(version, reason string, err error) *ReleaseFailure {
+	failure := &ReleaseFailure{Version: version, Reason: reason, At: time.Now().UTC().Format(time.RFC3339)}
+	if err != nil {
+		failure.Error = err.Error()
+	}
+	return failure
+}
+
+// watchLaunch возвращает обработчик завершения игры: проработавшая
+// lkgConfirmAfter игра подтверждает установленный манифест как LKG, ранний
+// сбой помечает его сломанным. Раньше закрытая без ошибки или остановленная
+// отменой ctx игра ничего не подтверждает и сбоем не считается: версию
+// проверит следующий запуск. stop отменяет подтверждение, если процесс
+// не запустился; settled закрывается, когда итог запуска записан (nil —
+// подтверждать нечего).
+func (l *Launcher) watchLaunch(ctx context.Context, cfg *config.Config, inst *config.Instance) (onExit func(error, time.Duration), stop func(), settled <-chan struct{}) {
+	state := loadRelease(inst.Dir)
+	installed := state.Installed
+	if !installed.restorable() {
+		return nil, func() {}, nil
+	}
+	if state.LKG != nil && state.LKG.Version == installed.Version {
+		// Версия уже проверена: её файлы лежат в хранилище.
+		return nil, func() {}, nil
+	}
+	instID, instDir := inst.ID, inst.Dir
+	done := make(chan struct{})
+	var once sync.Once
+	confirm := func() {
+		once.Do(func() {
+			// Игра идёт уже без операции установки: индекс открываем сами.
+			ictx, closeIndex := l.withIndex(context.Background(), cfg)
+			confirmRelease(ictx, cfg, instID, instDir, installed)
+			closeIndex()
+			close(done)
+		})
+	}
+	timer := time.AfterFunc(lkgConfirmAfter, confirm)
+	onExit = func(err error, uptime time.Duration) {
+		if uptime >= lkgConfirmAfter {
+			confirm()
+			return
+		}
+		if !timer.Stop() {
+			return
+		}
+		defer close(done)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			slog.Info("release: game closed before the release was confirmed", "version", installed.Version, "uptime", uptime.Round(time.Second))
+			return
+		}
+		updateRelease(instDir, func(state *releaseState) bool {
+			slog.Warn("release: game crashed on first launch", "version", installed.Version, "uptime", uptime.Round(time.Second), "error", err)
+			state.Failed = newFailure(installed.Version, FailureCrash, err)
+			return true
+		})
+	}
+	return onExit, func() { timer.Stop() }, done
+}
+
+// confirmRelease делает манифест LKG и сохраняет его файлы в общем
+// хранилище, чтобы откат не зависел от того, раздаёт ли их ещё сервер.
+func confirmRelease(ctx context.Context, cfg *config.Config, instID, instDir string, record *releaseRecord) {
+	updateRelease(instDir, func(state *releaseState) bool {
+		state.LKG = record
+		if state.Failed != nil && state.Failed.Version == record.Version {
+			state.Failed = nil
+		}
+		return true
+	})
+	slog.Info("release: last-known-good recorded", "instance", instID, "version", record.Version)
+
+	st, err := openStore(ctx, cfg)
+	if err != nil {
+		slog.Warn("release: open store failed", "error", err)
+		return
+	}
+	defer func() {
+		if err := st.Close(); err != nil {
+			slog.Warn("launcher: save store refs failed", "error", err)
+		}
+	}()
+	var objects []store.Object
+	for _, group := range record.Manifest.Packages.Groups {
+		dir, err := groupDir(instDir, group)
+		if err != nil {
+			continue
+		}
+		for _, file := range group.Files {
+			sum := strings.ToLower(file.Sha256)
+			if sum == "" || !filepath.IsLocal(filepath.FromSlash(file.Path)) {
+				continue
+			}
+			path := filepath.Join(dir, filepath.FromSlash(file.Path))
+			// Файл, изменённый игроком после установки, не совпадёт по хешу и не попадёт в хранилище.
+			if err := st.Keep(download.AlgoSHA256, sum, path); err != nil {
+				continue
+			}
+			objects = append(objects, store.Object{Algo: download.AlgoSHA256, Hash: sum})
+		}
+	}
+	st.Pin(lkgPinOwner(instID), objects)
+}
+
+func lkgPinOwner(instID string) string {
+	return "lkg/" + instID
+}
+
+// packageCache отдаёт файлы сборки из общего хранилища копией: объекты туда
+// кладёт только confirmRelease, а копия не даёт правкам игрока испортить объект.
+type packageCache struct {
+	st *store.Store
+}
+
+func (c packageCache) Link(algo, hash, dst string) (bool, error) {
+	if c.st == nil || !c.st.Has(algo, hash) {
+		return false, nil
+	}
+	if ok, _ := c.st.Verification.VerifyFile(dst, 0, download.Checksum{Algo: algo, Value: hash}); ok {
+		return true, nil
+	}
+	return c.st.CopyTo(algo, hash, dst)
+}
+
+// Put ничего не делает: скачанные файлы сборки в хранилище не переносятся.
+func (c packageCache) Put(algo, hash, src string) error {
+	return nil
+}
+
+// openPackageCache открывает хранилище для синхронизации пакетов; без
+// хранилища синхронизация просто скачивает файлы.
+func openPackageCache(ctx context.Context, cfg *config.Config) (packageCache, func()) {
+	st, err := openStore(ctx, cfg)
+	if err != nil {
+		slog.Warn("launcher: open store failed", "error", err)
+		return packageCache{}, func() {}
+	}
+	return packageCache{st}, func() {
+		if err := st.Close(); err != nil {
+			slog.Warn("launcher: save store refs failed", "error", err)
+		}
+	}
+}
+
+// Release возвращает состояние релизов сборки: установленный манифест, LKG
+// и сломанную версию.
+func (l *Launcher) Release(instanceID string) (*ReleaseInfo, error) {
+	_, inst, err := l.loadInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	releaseMu.Lock()
+	defer releaseMu.Unlock()
+	return loadRelease(inst.Dir).info(), nil
+}
+
+// Rollback возвращает сборку к LKG-манифесту: версии игры и загрузчика,
+// моды и файлы групп. Файлы LKG берутся из общего хранилища, поэтому откат
+// работает и без сервера. Откат держится, пока сервер не выпустит новую версию.
+func (l *Launcher) Rollback(ctx context.Context, instanceID string, onProgress func(ProgressEvent)) error {
+	_, inst, err := l.loadInstance(instanceID)
+	if err != nil {
+		return err
+	}
+	releaseMu.Lock()
+	state := loadRelease(inst.Dir)
+	if !state.LKG.restorable() {
+		releaseMu.Unlock()
+		return ErrNoLKG
+	}
+	if state.Failed == nil && state.Installed != nil && state.Installed.Version != state.LKG.Version {
+		state.Failed = newFailure(state.Installed.Version, FailureManual, nil)
+	}
+	state.RolledBack = true
+	err = state.save(inst.Dir)
+	releaseMu.Unlock()
+	if err != nil {
+		return err
+	}
+	slog.Info("release: rolling back", "instance", inst.ID, "lkg", state.LKG.Version)
+	return l.PrepareForLaunch(ctx, inst.ID, onProgress)
+}
+
+// UpdateAvailable — сервер раздаёт версию манифеста, отличную от
+// установленной. Сломанная версия, с которой игрок откатился, обновлением
+// не считается.
+func (l *Launcher) UpdateAvailable(ctx context.Context, instanceID string) (bool, error) {
+	_, inst, err := l.loadInstance(instanceID)
+	if err != nil {
+		return false, err
+	}
+	result, err := l.FetchManifest(ctx, inst.ID)
+	if err != nil {
+		return false, err
+	}
+	version := releaseVersion(result.Manifest)
+	releaseMu.Lock()
+	state := loadRelease(inst.Dir)
+	releaseMu.Unlock()
+	if state.Installed == nil {
+		return true, nil
+	}
+	if state.RolledBack && state.Failed != nil && state.Failed.Version == version {
+		return false, nil
+	}
+	return version != state.Installed.Version, nil
+}
@@ -0,0 +1,146 @@
+package launcher
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"shinecore/internal/launcher/config"
+	"shinecore/internal/launcher/server"
+)
+
+func TestInstanceManifestVerifiesLKG(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"project":"sc","version":"1"}`)
+	sign := func(key ed25519.PrivateKey, data []byte) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	}
+	srv := &server.Client{
+		BaseURL:    ts.URL,
+		Client:     ts.Client(),
+		PublicKeys: []string{base64.StdEncoding.EncodeToString(pub)},
+	}
+
+	tests := []struct {
+		name    string
+		signed  *server.SignedManifest
+		version string
+	}{
+		{name: "valid", signed: &server.SignedManifest{Body: body, Signature: sign(priv, body)}, version: "1"},
+		{name: "tampered body", signed: &server.SignedManifest{Body: []byte(`{"project":"sc","version":"2"}`), Signature: sign(priv, body)}},
+		{name: "untrusted key", signed: &server.SignedManifest{Body: body, Signature: sign(other, body)}},
+		{name: "unsigned", signed: &server.SignedManifest{Body: body}},
+		{name: "no signed copy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// Справочная копия в release.json не должна влиять на результат.
+			lkg := &releaseRecord{Version: "1", Manifest: &server.Manifest{Version: "evil"}, Signed: tt.signed}
+			state := &releaseState{Installed: &releaseRecord{Version: "2"}, LKG: lkg, RolledBack: true,
+				Failed: &ReleaseFailure{Version: "2", Reason: FailureManual}}
+			if err := state.save(dir); err != nil {
+				t.Fatal(err)
+			}
+			manifest, err := instanceManifest(context.Background(), srv, dir)
+			if tt.version == "" {
+				if err == nil || manifest != nil {
+					t.Fatalf("instanceManifest() = %+v, %v; want server error", manifest, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("instanceManifest() error = %v", err)
+			}
+			if manifest.Version != tt.version {
+				t.Errorf("Version = %q, want %q", manifest.Version, tt.version)
+			}
+			if manifest.Signed == nil {
+				t.Error("LKG manifest lost its signed copy")
+			}
+		})
+	}
+}
+
+func TestWatchLaunchFirstRunFailure(t *testing.T) {
+	signed := &server.SignedManifest{Body: []byte(`{}`), Signature: "sig"}
+	installed := &releaseRecord{Version: "2", Manifest: &server.Manifest{Version: "2"}, Signed: signed}
+	crash := errors.New("exit status 1")
+
+	tests := []struct {
+		name    string
+		state   releaseState
+		cancel  bool
+		clean   bool
+		watched bool
+		failed  bool
+	}{
+		{name: "crash marks version failed", state: releaseState{Installed: installed}, watched: true, failed: true},
+		{name: "cancelled launch is not a crash", state: releaseState{Installed: installed}, cancel: true, watched: true},
+		{name: "short clean exit confirms nothing", state: releaseState{Installed: installed}, clean: true, watched: true},
+		{name: "confirmed version is not watched", state: releaseState{Installed: installed, LKG: installed}},
+		{name: "record without signed copy", state: releaseState{Installed: &releaseRecord{Version: "2", Manifest: installed.Manifest}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := tt.state.save(dir); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			l := &Launcher{}
+			onExit, stop, settled := l.watchLaunch(ctx, &config.Config{}, &config.Instance{ID: "main", Dir: dir})
+			defer stop()
+			if !tt.watched {
+				if onExit != nil || settled != nil {
+					t.Fatal("watchLaunch() watches a release that needs no confirmation")
+				}
+				return
+			}
+			if tt.cancel {
+				cancel()
+			}
+			exitErr := crash
+			if tt.clean {
+				exitErr = nil
+			}
+			onExit(exitErr, time.Second)
+			select {
+			case <-settled:
+			case <-time.After(time.Second):
+				t.Fatal("settled was not closed after the game exited")
+			}
+			state := loadRelease(dir)
+			if state.LKG != nil {
+				t.Errorf("LKG = %+v, want nil before lkgConfirmAfter", state.LKG)
+			}
+			failed := state.Failed
+			if tt.failed {
+				if failed == nil || failed.Version != "2" || failed.Reason != FailureCrash || failed.Error != crash.Error() {
+					t.Errorf("Failed = %+v, want crash of version 2", failed)
+				}
+			} else if failed != nil {
+				t.Errorf("Failed = %+v, want nil", failed)
+			}
+		})
+	}
+}
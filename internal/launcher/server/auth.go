@@ -0,0 +1,187 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator добавляет к запросу данные авторизации на сервере.
+type Authenticator interface {
+	Authorize(ctx context.Context, req *http.Request) error
+}
+
+// Negotiator — схема, которая подстраивается под сервер по его ответу.
+// Negotiate возвращает true, если запрос нужно подписать заново и повторить.
+type Negotiator interface {
+	Negotiate(resp *http.Response) bool
+}
+
+var (
+	// ErrNotLoggedIn — для токенной авторизации нет сохранённого входа.
+	ErrNotLoggedIn = errors.New("not logged in to the server")
+	// ErrSessionExpired — сервер отклонил refresh-токен; нужен повторный вход.
+	ErrSessionExpired = errors.New("server session expired, log in again")
+)
+
+const (
+	AuthHMAC  = "hmac"
+	AuthToken = "token"
+)
+
+// Token — сессия аккаунта на сервере.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+}
+
+// expiresSoon — токен пора обновить; запас покрывает время в пути и расхождение часов.
+func (t *Token) expiresSoon() bool {
+	return !t.ExpiresAt.IsZero() && time.Until(t.ExpiresAt) < time.Minute
+}
+
+// TokenAuth — bearer-токены аккаунта, полученные через /auth/login.
+// Истекающий access-токен обновляется по refresh-токену; новый токен
+// передаётся в OnRefresh для сохранения.
+type TokenAuth struct {
+	BaseURL   string
+	Client    *http.Client
+	OnRefresh func(Token)
+
+	mu    sync.Mutex
+	token *Token
+}
+
+func NewTokenAuth(baseURL string, client *http.Client, token *Token, onRefresh func(Token)) *TokenAuth {
+	return &TokenAuth{BaseURL: baseURL, Client: client, OnRefresh: onRefresh, token: token}
+}
+
+func (a *TokenAuth) Authorize(ctx context.Context, req *http.Request) error {
+	access, err := a.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+access)
+	return nil
+}
+
+func (a *TokenAuth) accessToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token == nil || a.token.AccessToken == "" {
+		return "", ErrNotLoggedIn
+	}
+	if a.token.expiresSoon() {
+		if a.token.RefreshToken == "" {
+			return "", ErrSessionExpired
+		}
+		token, err := Refresh(ctx, a.Client, a.BaseURL, a.token.RefreshToken)
+		if err != nil {
+			return "", err
+		}
+		a.token = token
+		if a.OnRefresh != nil {
+			a.OnRefresh(*token)
+		}
+	}
+	return a.token.AccessToken, nil
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Login получает токены аккаунта: POST /auth/login.
+func Login(ctx context.Context, client *http.Client, baseURL, username, password string) (*Token, error) {
+	return postToken(ctx, client, baseURL, "/auth/login", map[string]string{
+		"username": username,
+		"password": password,
+	}, ErrNotLoggedIn)
+}
+
+// Refresh обновляет access-токен: POST /auth/refresh.
+func Refresh(ctx context.Context, client *http.Client, baseURL, refreshToken string) (*Token, error) {
+	token, err := postToken(ctx, client, baseURL, "/auth/refresh", map[string]string{
+		"refresh_token": refreshToken,
+	}, ErrSessionExpired)
+	if err != nil {
+		return nil, err
+	}
+	// Сервер может не выдавать новый refresh-токен при обновлении.
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// Logout отзывает refresh-токен на сервере: POST /auth/logout.
+func Logout(ctx context.Context, client *http.Client, baseURL, refreshToken string) error {
+	if refreshToken == "" {
+		return nil
+	}
+	resp, err := postJSON(ctx, client, baseURL, "/auth/logout", map[string]string{"refresh_token": refreshToken})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusUnauthorized {
+		return errors.New("logout failed: server error " + resp.Status)
+	}
+	return nil
+}
+
+func postToken(ctx context.Context, client *http.Client, baseURL, endpoint string, payload any, unauthorized error) (*Token, error) {
+	resp, err := postJSON(ctx, client, baseURL, endpoint, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, unauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("auth failed: server error " + resp.Status)
+	}
+	var body tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.AccessToken == "" {
+		return nil, errors.New("auth failed: empty access token")
+	}
+	token := &Token{AccessToken: body.AccessToken, RefreshToken: body.RefreshToken}
+	if body.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second).UTC()
+	}
+	return token, nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, baseURL, endpoint string, payload any) (*http.Response, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	target, err := url.JoinPath(strings.TrimRight(baseURL, "/"), endpoint)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ManifestStatus — откуда взят манифест.
+type ManifestStatus string
+
+const (
+	// ManifestLive — получен с сервера сейчас.
+	ManifestLive ManifestStatus = "live"
+	// ManifestRevalidated — сервер подтвердил, что кэш актуален (304).
+	ManifestRevalidated ManifestStatus = "revalidated"
+	// ManifestCached — кэш свежий по max-age, сервер не запрашивался.
+	ManifestCached ManifestStatus = "cached"
+	// ManifestStale — сервер недоступен, использован кэш.
+	ManifestStale ManifestStatus = "stale"
+)
+
+// ManifestResult — манифест и его актуальность.
+type ManifestResult struct {
+	Manifest *Manifest
+	Status   ManifestStatus
+	// FetchedAt — когда манифест последний раз получен или подтверждён сервером.
+	FetchedAt time.Time
+	// Age — сколько прошло с FetchedAt (0 для live).
+	Age time.Duration
+	// Err — почему сервер не ответил (только для stale).
+	Err error
+}
+
+// Stale — манифест мог устареть: сервер его не подтвердил.
+func (r *ManifestResult) Stale() bool {
+	return r.Status == ManifestStale
+}
+
+type cacheMeta struct {
+	URL           string `json:"url,omitempty"`
+	FetchedAtUnix int64  `json:"fetched_at_unix"`
+	// Signature — подпись сохранённого манифеста, проверяется при каждом чтении кэша.
+	Signature    string `json:"signature,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// MaxAgeSeconds — Cache-Control: max-age последнего ответа сервера.
+	MaxAgeSeconds int64 `json:"max_age_seconds,omitempty"`
+	// Deltas — дельты поверх сохранённого полного манифеста, по порядку.
+	// Каждая хранится с подписью сервера и перепроверяется при чтении.
+	Deltas []SignedDelta `json:"deltas,omitempty"`
+}
+
+// SignedManifest — тело полного манифеста и дельты поверх него в том виде,
+// в котором их подписал сервер.
+type SignedManifest struct {
+	Body      []byte        `json:"body"`
+	Signature string        `json:"signature"`
+	Deltas    []SignedDelta `json:"deltas,omitempty"`
+}
+
+// SignedDelta — тело дельты манифеста и её подпись.
+type SignedDelta struct {
+	Body      []byte `json:"body"`
+	Signature string `json:"signature"`
+}
+
+// OpenManifest проверяет подписи сохранённого манифеста и его дельт
+// ключами клиента и собирает из них манифест.
+func (c *Client) OpenManifest(signed *SignedManifest) (*Manifest, error) {
+	keys, err := c.trustedKeys()
+	if err != nil {
+		return nil, err
+	}
+	return openSigned(signed, keys)
+}
+
+func openSigned(signed *SignedManifest, keys []PublicKey) (*Manifest, error) {
+	if signed == nil {
+		return nil, ErrManifestUnsigned
+	}
+	if err := VerifyManifest(signed.Body, signed.Signature, keys); err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(signed.Body, manifest); err != nil {
+		return nil, err
+	}
+	for _, saved := range signed.Deltas {
+		if err := VerifyManifest(saved.Body, saved.Signature, keys); err != nil {
+			return nil, err
+		}
+		var delta ManifestDelta
+		if err := json.Unmarshal(saved.Body, &delta); err != nil {
+			return nil, err
+		}
+		applied, err := ApplyDelta(manifest, &delta)
+		if err != nil {
+			return nil, err
+		}
+		manifest = applied
+	}
+	manifest.Signed = signed
+	return manifest, nil
+}
+
+// cachedManifest — проверенная копия манифеста из кэша.
+type cachedManifest struct {
+	manifest *Manifest
+	meta     cacheMeta
+}
+
+func (m *cachedManifest) fetchedAt() time.Time {
+	return time.Unix(m.meta.FetchedAtUnix, 0)
+}
+
+func (m *cachedManifest) age() time.Duration {
+	if m.meta.FetchedAtUnix == 0 {
+		return 0
+	}
+	return max(time.Since(m.fetchedAt()), 0)
+}
+
+func (m *cachedManifest) result(status ManifestStatus, err error) *ManifestResult {
+	return &ManifestResult{Manifest: m.manifest, Status: status, FetchedAt: m.fetchedAt(), Age: m.age(), Err: err}
+}
+
+// cacheMaxAge — время свежести кэша: max-age сервера, иначе Client.MaxAge.
+func (c *Client) cacheMaxAge(meta cacheMeta) time.Duration {
+	if meta.MaxAgeSeconds > 0 {
+		return time.Duration(meta.MaxAgeSeconds) * time.Second
+	}
+	return c.MaxAge
+}
+
+func (c *Client) manifestCacheDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	cacheDir := filepath.Join(configDir, "shinecore", "manifests")
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", err
+	}
+	return cacheDir, nil
+}
+
+func (c *Client) manifestCachePath() (string, error) {
+	dir, err := c.manifestCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, c.manifestCacheName()+".json"), nil
+}
+
+func (c *Client) manifestCacheMetaPath() (string, error) {
+	dir, err := c.manifestCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, c.manifestCacheName()+".meta.json"), nil
+}
+
+// manifestCacheName — ключ кэша по серверу и источнику манифеста, чтобы
+// разные серверы и сборки не перетирали и не подменяли кэш друг друга.
+func (c *Client) manifestCacheName() string {
+	sum := sha256.Sum256([]byte(strings.TrimRight(c.BaseURL, "/") + "\n" + c.manifestURL()))
+	return "manifest-" + hex.EncodeToString(sum[:8])
+}
+
+func (c *Client) saveCachedManifest(data []byte, meta cacheMeta) error {
+	manifestPath, err := c.manifestCachePath()
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(manifestPath, data, 0o644); err != nil {
+		return err
+	}
+	return c.saveCacheMeta(meta)
+}
+
+func (c *Client) saveCacheMeta(meta cacheMeta) error {
+	metaPath, err := c.manifestCacheMetaPath()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, payload, 0o644)
+}
+
+func (c *Client) loadCachedManifest(keys []PublicKey) (*cachedManifest, error) {
+	path, err := c.manifestCachePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	metaPath, err := c.manifestCacheMetaPath()
+	if err != nil {
+		return nil, err
+	}
+	var meta cacheMeta
+	if payload, err := os.ReadFile(metaPath); err == nil {
+		_ = json.Unmarshal(payload, &meta)
+	}
+	// Кэш без подписи (от старых версий) или изменённый на диске не принимаем.
+	manifest, err := openSigned(&SignedManifest{Body: data, Signature: meta.Signature, Deltas: meta.Deltas}, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedManifest{manifest: manifest, meta: meta}, nil
+}
+
+// parseMaxAge читает Cache-Control: max-age; no-cache и no-store — 0.
+func parseMaxAge(header http.Header) time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-cache" || directive == "no-store" {
+			return 0
+		}
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return 0
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	_ = os.Remove(path)
+	return os.Rename(tmp, path)
+}
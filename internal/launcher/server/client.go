@@ -2,112 +2,248 @@ package server
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"path"
-	"path/filepath"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Client struct {
 	BaseURL string
-	Secret  string
-	Client  *http.Client
+	// Secret — общий секрет legacy-схемы HMAC; используется, если Auth не задан.
+	Secret string
+	Auth   Authenticator
+	Client *http.Client
+	// ManifestURL переопределяет источник манифеста (абсолютный или относительно BaseURL).
+	// Пусто — BaseURL + "/manifest".
+	ManifestURL string
+	// Channel — канал сборки (?channel=); пусто — канал сервера по умолчанию.
+	Channel string
+	// PublicKeys — закреплённые ключи подписи манифеста в дополнение к ManifestPublicKey.
+	PublicKeys []string
+	// MaxAge — сколько кэшированный манифест считается свежим без запроса
+	// к серверу, если сервер не задал Cache-Control: max-age. 0 — всегда перепроверять.
+	MaxAge time.Duration
+	// MaxStale — предельный возраст кэшированного манифеста, который
+	// отдаётся, когда сервер недоступен. 0 — без ограничения.
+	MaxStale time.Duration
+	// AppliedVersion — версия манифеста, которую последняя успешная
+	// синхронизация применила к сборке. Дельта (?since=) запрашивается только
+	// от неё и только если кэш клиента — та же версия; иначе — полный манифест.
+	AppliedVersion string
+
+	authOnce    sync.Once
+	defaultAuth Authenticator
+	// clock — поправка к локальному времени для подписи запросов. Общая для
+	// всех клиентов одного сервера (см. serverClock); задаётся в тестах.
+	clock     *Clock
+	clockOnce sync.Once
 }
 
-func (c *Client) FetchManifest(ctx context.Context) (*Manifest, error) {
-	manifest, raw, err := c.fetchManifestOnline(ctx)
-	if err == nil && manifest != nil {
-		_ = c.saveCachedManifest(raw)
-		return manifest, nil
+// FetchManifest возвращает манифест только с действительной подписью;
+// кэшированная копия проверяется так же, как полученная с сервера.
+// Свежий по max-age кэш отдаётся без запроса, иначе кэш перепроверяется
+// условным запросом, а при недоступности сервера отдаётся как устаревший,
+// если он не старше MaxStale.
+func (c *Client) FetchManifest(ctx context.Context) (*ManifestResult, error) {
+	keys, err := c.trustedKeys()
+	if err != nil {
+		return nil, err
 	}
-	if cached, cacheErr := c.loadCachedManifest(); cacheErr == nil {
-		return cached, nil
+	cached, cacheErr := c.loadCachedManifest(keys)
+	if cacheErr != nil && (errors.Is(cacheErr, ErrManifestUnsigned) || errors.Is(cacheErr, ErrManifestSignature)) {
+		slog.Warn("manifest: cached copy rejected by signature check", "error", cacheErr)
+	}
+	if cached != nil {
+		if maxAge := c.cacheMaxAge(cached.meta); maxAge > 0 && cached.age() < maxAge {
+			return cached.result(ManifestCached, nil), nil
+		}
 	}
-	return nil, err
-}
 
-func (c *Client) manifestCachePath() (string, error) {
-	configDir, err := os.UserConfigDir()
-	if err != nil {
-		return "", err
+	var validators *cacheMeta
+	since := ""
+	if cached != nil {
+		validators = &cached.meta
+		if c.AppliedVersion != "" && cached.manifest.Version == c.AppliedVersion && len(cached.meta.Deltas) < maxDeltaChain {
+			since = c.AppliedVersion
+		}
 	}
-	cacheDir := filepath.Join(configDir, "shinecore")
-	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
-		return "", err
+	fetched, err := c.fetchManifestOnline(ctx, keys, validators, since)
+	if err == nil && fetched.delta != nil {
+		manifest, applyErr := ApplyDelta(cached.manifest, fetched.delta)
+		if applyErr == nil {
+			meta := cached.meta
+			meta.FetchedAtUnix = time.Now().Unix()
+			meta.ETag = fetched.etag
+			meta.LastModified = fetched.lastModified
+			meta.MaxAgeSeconds = int64(fetched.maxAge.Seconds())
+			meta.Deltas = append(append([]SignedDelta(nil), meta.Deltas...), SignedDelta{Body: fetched.raw, Signature: fetched.signature})
+			if err := c.saveCacheMeta(meta); err != nil {
+				slog.Warn("manifest: save cache failed", "error", err)
+			}
+			base := cached.manifest.Signed
+			manifest.Signed = &SignedManifest{Body: base.Body, Signature: base.Signature, Deltas: meta.Deltas}
+			slog.Info("manifest: delta applied", "since", fetched.delta.Since, "version", manifest.Version)
+			return &ManifestResult{Manifest: manifest, Status: ManifestLive, FetchedAt: time.Unix(meta.FetchedAtUnix, 0)}, nil
+		}
+		slog.Warn("manifest: delta rejected, fetching full manifest", "error", applyErr)
+		fetched, err = c.fetchManifestOnline(ctx, keys, nil, "")
 	}
-	return filepath.Join(cacheDir, "manifest_cache.json"), nil
+	if err == nil {
+		if fetched.notModified {
+			cached.meta.FetchedAtUnix = time.Now().Unix()
+			cached.meta.MaxAgeSeconds = int64(fetched.maxAge.Seconds())
+			_ = c.saveCacheMeta(cached.meta)
+			return cached.result(ManifestRevalidated, nil), nil
+		}
+		meta := cacheMeta{
+			URL:           c.manifestURL(),
+			FetchedAtUnix: time.Now().Unix(),
+			Signature:     fetched.signature,
+			ETag:          fetched.etag,
+			LastModified:  fetched.lastModified,
+			MaxAgeSeconds: int64(fetched.maxAge.Seconds()),
+		}
+		if err := c.saveCachedManifest(fetched.raw, meta); err != nil {
+			slog.Warn("manifest: save cache failed", "error", err)
+		}
+		fetched.manifest.Signed = &SignedManifest{Body: fetched.raw, Signature: fetched.signature}
+		return &ManifestResult{Manifest: fetched.manifest, Status: ManifestLive, FetchedAt: time.Unix(meta.FetchedAtUnix, 0)}, nil
+	}
+	if errors.Is(err, ErrManifestUnsigned) || errors.Is(err, ErrManifestSignature) {
+		slog.Warn("manifest: rejected by signature check", "url", c.manifestURL(), "error", err)
+	}
+	// С неверными часами кэш не поможет: загрузки файлов всё равно отклонят.
+	if errors.Is(err, ErrClockSkew) || cached == nil {
+		return nil, err
+	}
+	if age := cached.age(); c.MaxStale > 0 && age > c.MaxStale {
+		slog.Warn("manifest: server unavailable and cached copy is too old", "age", age.Round(time.Second), "max_stale", c.MaxStale)
+		return nil, fmt.Errorf("cached manifest is %s old, older than allowed %s: %w", age.Round(time.Minute), c.MaxStale, err)
+	}
+	result := cached.result(ManifestStale, err)
+	slog.Warn("manifest: server unavailable, using cached copy", "age", result.Age.Round(time.Second), "error", err)
+	return result, nil
 }
 
-func (c *Client) saveCachedManifest(data []byte) error {
-	manifestPath, err := c.manifestCachePath()
-	if err != nil {
-		return err
+// trustedKeys — встроенный ключ сборки и ключи, закреплённые в server.json.
+func (c *Client) trustedKeys() ([]PublicKey, error) {
+	raw := append([]string{ManifestPublicKey}, c.PublicKeys...)
+	keys := make([]PublicKey, 0, len(raw))
+	for _, value := range raw {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		key, err := ParsePublicKey(value)
+		if err != nil {
+			slog.Warn("manifest: skip invalid public key", "error", err)
+			continue
+		}
+		keys = append(keys, key)
 	}
-	metaPath, err := c.manifestCacheMetaPath()
-	if err != nil {
-		return err
+	if len(keys) == 0 {
+		return nil, ErrNoManifestKeys
 	}
-	if err := writeFileAtomic(manifestPath, data, 0o644); err != nil {
-		return err
+	return keys, nil
+}
+
+func (c *Client) manifestURL() string {
+	manifestURL := strings.TrimRight(c.BaseURL, "/") + "/manifest"
+	if strings.TrimSpace(c.ManifestURL) != "" {
+		manifestURL = c.ResolveURL(strings.TrimSpace(c.ManifestURL))
 	}
-	meta := cacheMeta{FetchedAtUnix: time.Now().Unix()}
-	payload, err := json.Marshal(meta)
-	if err != nil {
-		return err
+	if channel := strings.TrimSpace(c.Channel); channel != "" {
+		manifestURL = withQuery(manifestURL, "channel", channel)
 	}
-	return writeFileAtomic(metaPath, payload, 0o644)
+	return manifestURL
 }
 
-func (c *Client) loadCachedManifest() (*Manifest, error) {
-	path, err := c.manifestCachePath()
+func withQuery(rawURL, key, value string) string {
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		return nil, err
+		return rawURL
 	}
-	data, err := os.ReadFile(path)
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// manifestSignatureURL — соседний файл подписи: <manifest>.sig.
+func (c *Client) manifestSignatureURL() string {
+	manifestURL := c.manifestURL()
+	u, err := url.Parse(manifestURL)
 	if err != nil {
-		return nil, err
+		return manifestURL + ".sig"
 	}
-	var manifest Manifest
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		return nil, err
-	}
-	return &manifest, nil
+	u.Path += ".sig"
+	return u.String()
 }
 
-func (c *Client) fetchManifestOnline(ctx context.Context) (*Manifest, []byte, error) {
-	base := strings.TrimRight(c.BaseURL, "/")
+// manifestResponse — ответ /manifest: полный манифест, дельта или notModified.
+type manifestResponse struct {
+	manifest     *Manifest
+	delta        *ManifestDelta
+	raw          []byte
+	signature    string
+	etag         string
+	lastModified string
+	maxAge       time.Duration
+	notModified  bool
+}
+
+// fetchManifestOnline запрашивает манифест; validators — данные кэша для
+// условного запроса (If-None-Match / If-Modified-Since), nil — без кэша.
+//
+// since — версия кэша: сервер с поддержкой дельт вернёт только изменения.
+func (c *Client) fetchManifestOnline(ctx context.Context, keys []PublicKey, validators *cacheMeta, since string) (*manifestResponse, error) {
+	manifestURL := c.manifestURL()
+	if since != "" {
+		manifestURL = withQuery(manifestURL, "since", since)
+	}
 	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	client := c.httpClient()
+	header := http.Header{}
+	if validators != nil {
+		if validators.ETag != "" {
+			header.Set("If-None-Match", validators.ETag)
+		}
+		if validators.LastModified != "" {
+			header.Set("If-Modified-Since", validators.LastModified)
+		}
+	}
 	var lastErr error
 	for attempt := 1; attempt <= 3; attempt++ {
 		if reqCtx.Err() != nil {
-			return nil, nil, reqCtx.Err()
+			return nil, reqCtx.Err()
 		}
-		req, err := c.SignedRequest(reqCtx, http.MethodGet, base+"/manifest")
-		if err != nil {
-			return nil, nil, err
-		}
-		resp, err := client.Do(req)
+		resp, err := c.doWithHeader(reqCtx, http.MethodGet, manifestURL, header)
 		if err != nil {
+			if errors.Is(err, ErrClockSkew) || errors.Is(err, ErrNotLoggedIn) || errors.Is(err, ErrSessionExpired) {
+				return nil, err
+			}
 			lastErr = err
 			sleepWithContext(reqCtx, time.Duration(attempt)*300*time.Millisecond)
 			continue
 		}
+		if resp.StatusCode == http.StatusNotModified && validators != nil {
+			resp.Body.Close()
+			return &manifestResponse{notModified: true, maxAge: parseMaxAge(resp.Header)}, nil
+		}
 		if resp.StatusCode != http.StatusOK {
 			resp.Body.Close()
+			if resp.StatusCode == http.StatusUnauthorized {
+				return nil, fmt.Errorf("manifest unavailable: %w", c.unauthorizedError(resp.Request))
+			}
 			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-				return nil, nil, errors.New("manifest unavailable: server error " + resp.Status)
+				return nil, errors.New("manifest unavailable: server error " + resp.Status)
 			}
 			lastErr = errors.New("manifest unavailable: server error " + resp.Status)
 			sleepWithContext(reqCtx, time.Duration(attempt)*300*time.Millisecond)
@@ -120,42 +256,67 @@ func (c *Client) fetchManifestOnline(ctx context.Context) (*Manifest, []byte, er
 			sleepWithContext(reqCtx, time.Duration(attempt)*300*time.Millisecond)
 			continue
 		}
-		var manifest Manifest
-		if err := json.Unmarshal(body, &manifest); err != nil {
-			return nil, nil, err
+		delta := since != "" && isDelta(body)
+		signature := resp.Header.Get(ManifestSignatureHeader)
+		if strings.TrimSpace(signature) == "" {
+			// Файл .sig подписывает только полный манифест.
+			if delta {
+				return nil, ErrManifestUnsigned
+			}
+			signature, err = c.fetchManifestSignature(reqCtx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		// Проверяем подпись до разбора: непроверенный JSON дальше не идёт.
+		if err := VerifyManifest(body, signature, keys); err != nil {
+			return nil, err
 		}
-		return &manifest, body, nil
+		fetched := &manifestResponse{
+			raw:          body,
+			signature:    signature,
+			etag:         resp.Header.Get("ETag"),
+			lastModified: resp.Header.Get("Last-Modified"),
+			maxAge:       parseMaxAge(resp.Header),
+		}
+		if delta {
+			fetched.delta = &ManifestDelta{}
+			if err := json.Unmarshal(body, fetched.delta); err != nil {
+				return nil, err
+			}
+			return fetched, nil
+		}
+		fetched.manifest = &Manifest{}
+		if err := json.Unmarshal(body, fetched.manifest); err != nil {
+			return nil, err
+		}
+		return fetched, nil
 	}
-	return nil, nil, lastErr
-}
-
-type cacheMeta struct {
-	FetchedAtUnix int64 `json:"fetched_at_unix"`
+	return nil, lastErr
 }
 
-func (c *Client) manifestCacheMetaPath() (string, error) {
-	configDir, err := os.UserConfigDir()
+// fetchManifestSignature читает подпись из файла <manifest>.sig;
+// его отсутствие означает неподписанный манифест.
+func (c *Client) fetchManifestSignature(ctx context.Context) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.manifestSignatureURL())
 	if err != nil {
 		return "", err
 	}
-	cacheDir := filepath.Join(configDir, "shinecore")
-	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
-		return "", err
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrManifestUnsigned
 	}
-	return filepath.Join(cacheDir, "manifest_cache.meta.json"), nil
-}
-
-func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err
+	if resp.StatusCode == http.StatusUnauthorized {
+		return "", fmt.Errorf("manifest signature unavailable: %w", c.unauthorizedError(resp.Request))
 	}
-	tmp := path + ".tmp"
-	if err := os.WriteFile(tmp, data, perm); err != nil {
-		return err
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("manifest signature unavailable: server error " + resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
 	}
-	_ = os.Remove(path)
-	return os.Rename(tmp, path)
+	return string(data), nil
 }
 
 func sleepWithContext(ctx context.Context, d time.Duration) {
@@ -167,28 +328,88 @@ func sleepWithContext(ctx context.Context, d time.Duration) {
 	}
 }
 
+// SignedRequest строит запрос к серверу с данными авторизации клиента.
 func (c *Client) SignedRequest(ctx context.Context, method, urlStr string) (*http.Request, error) {
-	req, err := http.NewRequestWithContext(ctx, method, urlStr, nil)
+	offset, measured := c.serverClock().Offset(), c.serverClock().Known()
+	reqCtx := context.WithValue(ctx, appliedOffsetKey{}, appliedOffset{offset: offset, measured: measured})
+	req, err := http.NewRequestWithContext(reqCtx, method, urlStr, nil)
 	if err != nil {
 		return nil, err
 	}
-	if strings.TrimSpace(c.Secret) == "" {
-		return req, nil
-	}
-	u, err := url.Parse(urlStr)
-	if err != nil {
+	if err := c.authenticator().Authorize(withSigningTime(ctx, time.Now().Add(offset)), req); err != nil {
 		return nil, err
 	}
-	if !strings.HasPrefix(u.Path, "/") {
-		u.Path = "/" + u.Path
-	}
-	ts := time.Now().Unix()
-	sign := signRequest(c.Secret, method, u.Path, ts)
-	req.Header.Set("X-Timestamp", strconv.FormatInt(ts, 10))
-	req.Header.Set("X-Signature", sign)
 	return req, nil
 }
 
+// do выполняет подписанный запрос. Ответы уточняют поправку часов; запрос
+// подписывается заново и повторяется по разу, если сервер сменил версию
+// подписи или отклонил его из-за расхождения часов.
+func (c *Client) do(ctx context.Context, method, urlStr string) (*http.Response, error) {
+	return c.doWithHeader(ctx, method, urlStr, nil)
+}
+
+// doWithHeader — do с дополнительными заголовками, которые не входят в подпись.
+func (c *Client) doWithHeader(ctx context.Context, method, urlStr string, header http.Header) (*http.Response, error) {
+	negotiated, skewRetried := false, false
+	for {
+		offset := c.serverClock().Offset()
+		req, err := c.SignedRequest(ctx, method, urlStr)
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			return nil, clockError(err)
+		}
+		c.serverClock().Observe(resp)
+		// Расхождение часов проверяем раньше согласования версии: иначе
+		// отказ из-за времени выглядел бы как отказ от подписи v2.
+		if resp.StatusCode == http.StatusUnauthorized && !skewRetried {
+			if resp.Header.Get("Date") == "" {
+				c.syncTime(ctx)
+			}
+			if absDuration(c.serverClock().Offset()-offset) > skewRetryThreshold {
+				skewRetried = true
+				slog.Info("server: clock offset corrected, retrying", "offset", c.serverClock().Offset().Round(time.Second))
+				resp.Body.Close()
+				continue
+			}
+		}
+		// Ответ на повтор тоже передаётся схеме: по нему она решает, принял
+		// ли сервер подпись старой версии.
+		if c.negotiate(resp) && !negotiated {
+			negotiated = true
+			resp.Body.Close()
+			continue
+		}
+		return resp, nil
+	}
+}
+
+// authenticator — заданная схема авторизации; без неё — HMAC по Secret
+// (одна на клиента, чтобы согласованная версия подписи сохранялась).
+func (c *Client) authenticator() Authenticator {
+	if c.Auth != nil {
+		return c.Auth
+	}
+	c.authOnce.Do(func() {
+		c.defaultAuth = NewHMACAuth(c.Secret, 0)
+	})
+	return c.defaultAuth
+}
+
+// negotiate передаёт ответ схеме авторизации; true — повторить запрос.
+func (c *Client) negotiate(resp *http.Response) bool {
+	if n, ok := c.authenticator().(Negotiator); ok {
+		return n.Negotiate(resp)
+	}
+	return false
+}
+
 func (c *Client) IsLocalDownload(urlStr string) bool {
 	u, err := url.Parse(urlStr)
 	if err != nil {
@@ -214,10 +435,3 @@ func (c *Client) httpClient() *http.Client {
 	}
 	return http.DefaultClient
 }
-
-func signRequest(secret, method, reqPath string, timestamp int64) string {
-	message := method + "\n" + reqPath + "\n" + strconv.FormatInt(timestamp, 10)
-	mac := hmac.New(sha256.New, []byte(secret))
-	mac.Write([]byte(message))
-	return hex.EncodeToString(mac.Sum(nil))
-}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchManifestSinceApplied(t *testing.T) {
+	signer := newTestKey(t, "signer01")
+	body := []byte(`{"version":"7","minecraft":{"version":"1.20.1"}}`)
+	prevKey := ManifestPublicKey
+	ManifestPublicKey = signer.rawPub()
+	t.Cleanup(func() { ManifestPublicKey = prevKey })
+
+	tests := []struct {
+		name    string
+		applied string
+		want    string
+	}{
+		{name: "applied matches cache", applied: "7", want: "7"},
+		{name: "nothing applied", applied: "", want: ""},
+		{name: "applied older than cache", applied: "6", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Setenv("HOME", t.TempDir())
+			var mu sync.Mutex
+			var since []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				since = append(since, r.URL.Query().Get("since"))
+				mu.Unlock()
+				// Сервер без поддержки дельт: всегда полный манифест.
+				w.Header().Set(ManifestSignatureHeader, signer.rawSig(body))
+				_, _ = w.Write(body)
+			}))
+			defer srv.Close()
+
+			c := &Client{BaseURL: srv.URL, Client: srv.Client(), Secret: "secret"}
+			if _, err := c.FetchManifest(context.Background()); err != nil {
+				t.Fatalf("first FetchManifest() = %v", err)
+			}
+			c = &Client{BaseURL: srv.URL, Client: srv.Client(), Secret: "secret", AppliedVersion: tt.applied}
+			result, err := c.FetchManifest(context.Background())
+			if err != nil {
+				t.Fatalf("FetchManifest() = %v", err)
+			}
+			if result.Manifest.Version != "7" {
+				t.Fatalf("version = %q, want 7", result.Manifest.Version)
+			}
+			if len(since) != 2 || since[0] != "" || since[1] != tt.want {
+				t.Fatalf("since = %q, want [\"\" %q]", since, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchManifestMaxStale(t *testing.T) {
+	signer := newTestKey(t, "signer01")
+	body := []byte(`{"version":"7","minecraft":{"version":"1.20.1"}}`)
+	prevKey := ManifestPublicKey
+	ManifestPublicKey = signer.rawPub()
+	t.Cleanup(func() { ManifestPublicKey = prevKey })
+
+	const day = 24 * time.Hour
+	tests := []struct {
+		name     string
+		age      time.Duration
+		maxStale time.Duration
+		wantErr  bool
+	}{
+		{name: "within limit", age: 3 * day, maxStale: 14 * day},
+		{name: "too old", age: 20 * day, maxStale: 14 * day, wantErr: true},
+		{name: "no limit", age: 200 * day},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Setenv("HOME", t.TempDir())
+			online := true
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !online {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set(ManifestSignatureHeader, signer.rawSig(body))
+				_, _ = w.Write(body)
+			}))
+			defer srv.Close()
+
+			c := &Client{BaseURL: srv.URL, Client: srv.Client(), Secret: "secret", MaxStale: tt.maxStale}
+			if _, err := c.FetchManifest(context.Background()); err != nil {
+				t.Fatalf("first FetchManifest() = %v", err)
+			}
+			keys, err := c.trustedKeys()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cached, err := c.loadCachedManifest(keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cached.meta.FetchedAtUnix = time.Now().Add(-tt.age).Unix()
+			if err := c.saveCacheMeta(cached.meta); err != nil {
+				t.Fatal(err)
+			}
+
+			online = false
+			result, err := c.FetchManifest(context.Background())
+			if tt.wantErr {
+				if err == nil || result != nil {
+					t.Fatalf("FetchManifest() = %+v, %v; want error", result, err)
+				}
+				return
+			}
+			if err != nil || !result.Stale() {
+				t.Fatalf("FetchManifest() = %+v, %v; want stale copy", result, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// MaxClockSkew — допуск сервера на расхождение X-Timestamp.
+	MaxClockSkew = 5 * time.Minute
+	// skewRetryThreshold — насколько должна сдвинуться поправка, чтобы
+	// повторить отклонённый запрос с исправленным временем.
+	skewRetryThreshold = 30 * time.Second
+)
+
+var (
+	// ErrUnauthorized — сервер отклонил авторизацию запроса.
+	ErrUnauthorized = errors.New("server rejected authorization")
+	// ErrClockSkew — часы системы расходятся с сервером, и исправить это не удалось.
+	ErrClockSkew = errors.New("system clock is out of sync with the server")
+)
+
+// ClockSkewError — часы игрока расходятся с сервером. Offset — время сервера
+// минус локальное; 0 — расхождение не измерено (TLS-сертификат сервера не
+// действителен по локальным часам).
+type ClockSkewError struct {
+	Offset time.Duration
+
+	certificate bool
+}
+
+func (e *ClockSkewError) Error() string {
+	if e.certificate {
+		return "system clock appears to be wrong: the server certificate is not valid at the local time; enable automatic time sync"
+	}
+	return fmt.Sprintf("system clock differs from the server by %s; enable automatic time sync", absDuration(e.Offset).Round(time.Second))
+}
+
+func (e *ClockSkewError) Is(target error) bool {
+	return target == ErrClockSkew
+}
+
+// Clock — поправка локальных часов по времени сервера (заголовок Date
+// или /time). Нулевое значение готово к работе.
+type Clock struct {
+	offset atomic.Int64
+	known  atomic.Bool
+}
+
+// Now — текущее время сервера по оценке клиента.
+func (c *Clock) Now() time.Time {
+	return time.Now().Add(c.Offset())
+}
+
+func (c *Clock) Offset() time.Duration {
+	return time.Duration(c.offset.Load())
+}
+
+// Known — время сервера хотя бы раз получено.
+func (c *Clock) Known() bool {
+	return c.known.Load()
+}
+
+// Observe обновляет поправку по заголовку Date ответа.
+func (c *Clock) Observe(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	c.set(date)
+}
+
+func (c *Clock) set(serverTime time.Time) {
+	// Date имеет точность до секунды: мелкие расхождения не считаем смещением.
+	offset := time.Until(serverTime)
+	if absDuration(offset) < 2*time.Second {
+		offset = 0
+	}
+	c.offset.Store(int64(offset))
+	c.known.Store(true)
+}
+
+// clocks — поправки часов по серверам на время работы процесса: лаунчер
+// создаёт клиента на каждую операцию, а время сервера, измеренное одной
+// операцией, нужно и следующим.
+var clocks sync.Map // ключ — scheme://host сервера, значение — *Clock
+
+// serverClock — поправка часов для сервера клиента.
+func (c *Client) serverClock() *Clock {
+	c.clockOnce.Do(func() {
+		if c.clock == nil {
+			c.clock = clockFor(c.BaseURL)
+		}
+	})
+	return c.clock
+}
+
+func clockFor(baseURL string) *Clock {
+	key := strings.TrimRight(baseURL, "/")
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		key = strings.ToLower(u.Scheme + "://" + u.Host)
+	}
+	clock, _ := clocks.LoadOrStore(key, &Clock{})
+	return clock.(*Clock)
+}
+
+type signingTimeKey struct{}
+
+// appliedOffsetKey — поправка часов, с которой подписан запрос (в контексте
+// самого запроса): по ней unauthorizedError отличает неверные часы от
+// неверных данных входа.
+type appliedOffsetKey struct{}
+
+type appliedOffset struct {
+	offset   time.Duration
+	measured bool
+}
+
+// withSigningTime передаёт схеме авторизации время подписи с учётом поправки.
+func withSigningTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, signingTimeKey{}, t)
+}
+
+func signingTime(ctx context.Context) time.Time {
+	if t, ok := ctx.Value(signingTimeKey{}).(time.Time); ok {
+		return t
+	}
+	return time.Now()
+}
+
+// syncTime запрашивает время у /time, если ответ с 401 пришёл без Date.
+// Ответ — {"unix": 1705482000}, число секунд или любой ответ с Date.
+func (c *Client) syncTime(ctx context.Context) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.ResolveURL("/time"), nil)
+	if err != nil {
+		return
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		var body struct {
+			Unix int64 `json:"unix"`
+		}
+		if json.Unmarshal(data, &body) == nil && body.Unix > 0 {
+			c.serverClock().set(time.Unix(body.Unix, 0))
+			return
+		}
+		if sec, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil && sec > 0 {
+			c.serverClock().set(time.Unix(sec, 0))
+			return
+		}
+	}
+	c.serverClock().Observe(resp)
+}
+
+// unauthorizedError объясняет окончательный 401 на запрос req. Часы
+// виноваты, только если время сервера измерено и поправка на момент подписи
+// отличалась от нынешней больше допуска. Без времени сервера расхождение
+// не доказано; запрос, подписанный уже исправленным временем, и запрос без
+// времени в подписи (токены) отклонены из-за данных входа.
+func (c *Client) unauthorizedError(req *http.Request) error {
+	if req == nil || req.Header.Get("X-Timestamp") == "" {
+		return ErrUnauthorized
+	}
+	clock := c.serverClock()
+	if !clock.Known() {
+		return ErrUnauthorized
+	}
+	applied, _ := req.Context().Value(appliedOffsetKey{}).(appliedOffset)
+	if !applied.measured {
+		applied.offset = 0
+	}
+	if residual := clock.Offset() - applied.offset; absDuration(residual) > MaxClockSkew {
+		return &ClockSkewError{Offset: residual}
+	}
+	return ErrUnauthorized
+}
+
+// clockError распознаёт ошибку TLS из-за неверных локальных часов.
+func clockError(err error) error {
+	var certErr x509.CertificateInvalidError
+	if errors.As(err, &certErr) && certErr.Reason == x509.Expired {
+		return &ClockSkewError{certificate: true}
+	}
+	return err
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
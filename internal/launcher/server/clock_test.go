@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestUnauthorizedError(t *testing.T) {
+	const skew = 10 * time.Minute
+	tests := []struct {
+		name string
+		// known — время сервера измерено; offset — нынешняя поправка.
+		known  bool
+		offset time.Duration
+		// timed — время входит в подпись (HMAC); applied — поправка на момент подписи.
+		timed    bool
+		applied  *appliedOffset
+		wantSkew bool
+		want     time.Duration
+	}{
+		{name: "skew corrected before signing", known: true, offset: skew, timed: true, applied: &appliedOffset{offset: skew, measured: true}},
+		{name: "no skew", known: true, timed: true, applied: &appliedOffset{measured: true}},
+		{name: "skew within tolerance", known: true, offset: time.Minute, timed: true, applied: &appliedOffset{}},
+		{name: "skew measured after signing", known: true, offset: skew, timed: true, applied: &appliedOffset{}, wantSkew: true, want: skew},
+		{name: "offset moved after signing", known: true, offset: -skew, timed: true, applied: &appliedOffset{offset: time.Minute, measured: true}, wantSkew: true, want: -skew - time.Minute},
+		{name: "server time unknown", timed: true, applied: &appliedOffset{}},
+		{name: "token auth with skew", known: true, offset: skew, applied: &appliedOffset{}},
+		{name: "token auth without server time", applied: &appliedOffset{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{clock: &Clock{}}
+			if tt.known {
+				c.clock.offset.Store(int64(tt.offset))
+				c.clock.known.Store(true)
+			}
+			ctx := context.Background()
+			if tt.applied != nil {
+				ctx = context.WithValue(ctx, appliedOffsetKey{}, *tt.applied)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/manifest", nil).WithContext(ctx)
+			if tt.timed {
+				req.Header.Set("X-Timestamp", "1700000000")
+			}
+			err := c.unauthorizedError(req)
+			var skewErr *ClockSkewError
+			if got := errors.As(err, &skewErr); got != tt.wantSkew {
+				t.Fatalf("unauthorizedError() = %v, want clock skew: %v", err, tt.wantSkew)
+			}
+			if !tt.wantSkew {
+				if !errors.Is(err, ErrUnauthorized) {
+					t.Fatalf("unauthorizedError() = %v, want ErrUnauthorized", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrClockSkew) {
+				t.Fatalf("unauthorizedError() = %v, want ErrClockSkew", err)
+			}
+			if skewErr.Offset != tt.want {
+				t.Fatalf("ClockSkewError.Offset = %s, want %s", skewErr.Offset, tt.want)
+			}
+		})
+	}
+}
+
+// TestFetchUnauthorizedWithSkew — сервер с часами впереди на час отвечает 401
+// на любую подпись: после повтора с исправленным временем виноват секрет, а не часы.
+func TestFetchUnauthorizedWithSkew(t *testing.T) {
+	ahead := time.Hour
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Date", time.Now().Add(ahead).UTC().Format(http.TimeFormat))
+		w.Header().Set(SignatureVersionHeader, "2")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, Secret: "wrong-secret", Client: srv.Client()}
+	_, err := c.FetchLauncherRelease(context.Background(), "windows", "amd64", "")
+	if !errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrClockSkew) {
+		t.Fatalf("FetchLauncherRelease() = %v, want ErrUnauthorized", err)
+	}
+	if requests != 2 {
+		t.Fatalf("server got %d requests, want a retry with corrected time", requests)
+	}
+}
+
+// TestFetchUnauthorizedWithoutServerTime — сервер не сообщает время ни в Date,
+// ни через /time: расхождение часов не доказано, отказ — из-за данных входа.
+func TestFetchUnauthorizedWithoutServerTime(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// net/http ставит Date сам; пустое значение убирает заголовок.
+		w.Header()["Date"] = nil
+		if r.URL.Path == "/time" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set(SignatureVersionHeader, "2")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, Secret: "secret", Client: srv.Client()}
+	_, err := c.FetchLauncherRelease(context.Background(), "windows", "amd64", "")
+	if !errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrClockSkew) {
+		t.Fatalf("FetchLauncherRelease() = %v, want ErrUnauthorized", err)
+	}
+}
+
+// TestClockSharedPerServer — поправка, измеренная одним клиентом, действует
+// для следующих клиентов того же сервера: лаунчер создаёт их на каждую операцию.
+func TestClockSharedPerServer(t *testing.T) {
+	ahead := time.Hour
+	var stamps []int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts, _ := strconv.ParseInt(r.Header.Get("X-Timestamp"), 10, 64)
+		stamps = append(stamps, ts)
+		w.Header().Set("Date", time.Now().Add(ahead).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	for i := 0; i < 2; i++ {
+		c := &Client{BaseURL: srv.URL + "/", Secret: "secret", Client: srv.Client()}
+		if _, err := c.FetchLauncherRelease(context.Background(), "windows", "amd64", ""); err != nil {
+			t.Fatalf("FetchLauncherRelease() = %v", err)
+		}
+	}
+	if len(stamps) != 2 {
+		t.Fatalf("server got %d requests, want 2", len(stamps))
+	}
+	if skew := time.Duration(stamps[1]-time.Now().Unix()) * time.Second; absDuration(skew-ahead) > time.Minute {
+		t.Fatalf("second client signed with offset %s, want about %s", skew, ahead)
+	}
+	if other := clockFor("http://other.example"); other.Known() {
+		t.Fatal("clock of another server must not be measured")
+	}
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// maxDeltaChain — после стольких дельт подряд лаунчер запрашивает полный
+// манифест, чтобы кэш не рос и не перепроверялся бесконечно.
+const maxDeltaChain = 8
+
+var errDeltaBase = errors.New("manifest delta does not match cached version")
+
+// ManifestDelta — ответ GET /manifest?since=<version>: изменения файлов
+// относительно версии Since. Поля вне packages передаются целиком.
+type ManifestDelta struct {
+	Since        string                `json:"since"`
+	Project      string                `json:"project"`
+	Studio       string                `json:"studio"`
+	Version      string                `json:"version"`
+	GeneratedAt  string                `json:"generated_at"`
+	Dependencies Dependencies          `json:"dependencies"`
+	Sync         SyncPolicy            `json:"sync"`
+	Packages     map[string]GroupDelta `json:"packages"`
+	Channel      string                `json:"channel,omitempty"`
+	Channels     []string              `json:"channels,omitempty"`
+}
+
+// GroupDelta — изменения одной группы. Непустые Target, Overwrite и Sync
+// заменяют настройки группы; Deleted удаляет группу целиком.
+type GroupDelta struct {
+	Target    string        `json:"target,omitempty"`
+	Overwrite string        `json:"overwrite,omitempty"`
+	Sync      *SyncPolicy   `json:"sync,omitempty"`
+	Added     []FilePackage `json:"added,omitempty"`
+	Changed   []FilePackage `json:"changed,omitempty"`
+	Removed   []string      `json:"removed,omitempty"`
+	Deleted   bool          `json:"deleted,omitempty"`
+}
+
+// isDelta — ответ сервера является дельтой (есть поле since). Сервер без
+// поддержки дельт игнорирует ?since и возвращает полный манифест.
+func isDelta(data []byte) bool {
+	var probe struct {
+		Since *string `json:"since"`
+	}
+	return json.Unmarshal(data, &probe) == nil && probe.Since != nil
+}
+
+// ApplyDelta возвращает новый манифест: base с изменениями delta.
+func ApplyDelta(base *Manifest, delta *ManifestDelta) (*Manifest, error) {
+	if base == nil || delta.Since != base.Version {
+		return nil, errDeltaBase
+	}
+	out := &Manifest{
+		Project:      delta.Project,
+		Studio:       delta.Studio,
+		Version:      delta.Version,
+		GeneratedAt:  delta.GeneratedAt,
+		Dependencies: delta.Dependencies,
+		Sync:         delta.Sync,
+		Channel:      delta.Channel,
+		Channels:     delta.Channels,
+	}
+	groups := make(map[string]PackageGroup, len(base.Packages.Groups))
+	for _, group := range base.Packages.Groups {
+		group.Files = append([]FilePackage(nil), group.Files...)
+		groups[group.Name] = group
+	}
+	for name, change := range delta.Packages {
+		if change.Deleted {
+			delete(groups, name)
+			continue
+		}
+		group, ok := groups[name]
+		if !ok {
+			group = PackageGroup{Name: name, Target: name}
+		}
+		if change.Target != "" {
+			group.Target = change.Target
+		}
+		if change.Overwrite != "" {
+			group.Overwrite = change.Overwrite
+		}
+		if change.Sync != nil {
+			group.Sync = change.Sync
+		}
+		files, err := applyFileChanges(group.Files, change)
+		if err != nil {
+			return nil, fmt.Errorf("packages.%s: %w", name, err)
+		}
+		group.Files = files
+		groups[name] = group
+	}
+	for _, group := range groups {
+		if group.Name == "mods" {
+			out.Packages.Mods = group.Files
+		}
+		out.Packages.Groups = append(out.Packages.Groups, group)
+	}
+	sortGroups(out.Packages.Groups)
+	return out, nil
+}
+
+func applyFileChanges(files []FilePackage, change GroupDelta) ([]FilePackage, error) {
+	index := make(map[string]int, len(files))
+	for i, file := range files {
+		index[file.Path] = i
+	}
+	removed := make(map[string]bool, len(change.Removed))
+	for _, path := range change.Removed {
+		removed[path] = true
+	}
+	for _, file := range append(append([]FilePackage(nil), change.Added...), change.Changed...) {
+		if file.Path == "" {
+			return nil, errors.New("delta entry without path")
+		}
+		delete(removed, file.Path)
+		if i, ok := index[file.Path]; ok {
+			files[i] = file
+			continue
+		}
+		index[file.Path] = len(files)
+		files = append(files, file)
+	}
+	if len(removed) == 0 {
+		return files, nil
+	}
+	kept := files[:0]
+	for _, file := range files {
+		if !removed[file.Path] {
+			kept = append(kept, file)
+		}
+	}
+	return kept, nil
+}
+
+// sortGroups — "mods" первыми, остальные по имени: порядок синхронизации стабилен.
+func sortGroups(groups []PackageGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		if (groups[i].Name == "mods") != (groups[j].Name == "mods") {
+			return groups[i].Name == "mods"
+		}
+		return groups[i].Name < groups[j].Name
+	})
+}
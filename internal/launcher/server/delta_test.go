@@ -0,0 +1,132 @@
+package server
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestApplyDelta(t *testing.T) {
+	file := func(path, sha string) FilePackage {
+		return FilePackage{Path: path, Size: 1, Sha256: sha, URL: "/files/" + path}
+	}
+	base := &Manifest{
+		Project: "sc",
+		Version: "1",
+		Packages: ManifestPackages{
+			Mods: []FilePackage{file("a.jar", "aa"), file("b.jar", "bb")},
+			Groups: []PackageGroup{
+				{Name: "config", Target: "config", Files: []FilePackage{file("x.cfg", "xx")}},
+				{Name: "mods", Target: "mods", Files: []FilePackage{file("a.jar", "aa"), file("b.jar", "bb")}},
+			},
+		},
+	}
+	strict := &SyncPolicy{Mode: SyncStrict}
+
+	tests := []struct {
+		name    string
+		delta   ManifestDelta
+		groups  []PackageGroup
+		wantErr error
+	}{
+		{
+			name:    "wrong base version",
+			delta:   ManifestDelta{Since: "0", Version: "2"},
+			wantErr: errDeltaBase,
+		},
+		{
+			name:  "empty delta keeps groups",
+			delta: ManifestDelta{Since: "1", Version: "2"},
+			groups: []PackageGroup{
+				{Name: "mods", Target: "mods", Files: []FilePackage{file("a.jar", "aa"), file("b.jar", "bb")}},
+				{Name: "config", Target: "config", Files: []FilePackage{file("x.cfg", "xx")}},
+			},
+		},
+		{
+			name: "add change remove",
+			delta: ManifestDelta{Since: "1", Version: "2", Packages: map[string]GroupDelta{
+				"mods": {
+					Added:   []FilePackage{file("c.jar", "cc")},
+					Changed: []FilePackage{file("a.jar", "a2")},
+					Removed: []string{"b.jar"},
+				},
+			}},
+			groups: []PackageGroup{
+				{Name: "mods", Target: "mods", Files: []FilePackage{file("a.jar", "a2"), file("c.jar", "cc")}},
+				{Name: "config", Target: "config", Files: []FilePackage{file("x.cfg", "xx")}},
+			},
+		},
+		{
+			name: "changed wins over removed",
+			delta: ManifestDelta{Since: "1", Version: "2", Packages: map[string]GroupDelta{
+				"mods": {Changed: []FilePackage{file("b.jar", "b2")}, Removed: []string{"b.jar"}},
+			}},
+			groups: []PackageGroup{
+				{Name: "mods", Target: "mods", Files: []FilePackage{file("a.jar", "aa"), file("b.jar", "b2")}},
+				{Name: "config", Target: "config", Files: []FilePackage{file("x.cfg", "xx")}},
+			},
+		},
+		{
+			name: "delete group and add new one",
+			delta: ManifestDelta{Since: "1", Version: "2", Packages: map[string]GroupDelta{
+				"config":        {Deleted: true},
+				"resourcepacks": {Overwrite: OverwriteAlways, Sync: strict, Added: []FilePackage{file("r.zip", "rr")}},
+			}},
+			groups: []PackageGroup{
+				{Name: "mods", Target: "mods", Files: []FilePackage{file("a.jar", "aa"), file("b.jar", "bb")}},
+				{Name: "resourcepacks", Target: "resourcepacks", Overwrite: OverwriteAlways, Sync: strict, Files: []FilePackage{file("r.zip", "rr")}},
+			},
+		},
+		{
+			name: "replace group settings",
+			delta: ManifestDelta{Since: "1", Version: "2", Packages: map[string]GroupDelta{
+				"config": {Target: "cfg", Overwrite: OverwriteAlways},
+			}},
+			groups: []PackageGroup{
+				{Name: "mods", Target: "mods", Files: []FilePackage{file("a.jar", "aa"), file("b.jar", "bb")}},
+				{Name: "config", Target: "cfg", Overwrite: OverwriteAlways, Files: []FilePackage{file("x.cfg", "xx")}},
+			},
+		},
+		{
+			name: "entry without path",
+			delta: ManifestDelta{Since: "1", Version: "2", Packages: map[string]GroupDelta{
+				"mods": {Added: []FilePackage{{Sha256: "zz"}}},
+			}},
+			wantErr: errors.New("packages.mods: delta entry without path"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyDelta(base, &tt.delta)
+			if tt.wantErr != nil {
+				if err == nil || (!errors.Is(err, tt.wantErr) && err.Error() != tt.wantErr.Error()) {
+					t.Fatalf("ApplyDelta() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyDelta() error = %v", err)
+			}
+			if got.Version != tt.delta.Version {
+				t.Errorf("Version = %q, want %q", got.Version, tt.delta.Version)
+			}
+			if !reflect.DeepEqual(got.Packages.Groups, tt.groups) {
+				t.Errorf("Groups = %+v, want %+v", got.Packages.Groups, tt.groups)
+			}
+			var mods []FilePackage
+			for _, group := range tt.groups {
+				if group.Name == "mods" {
+					mods = group.Files
+				}
+			}
+			if !reflect.DeepEqual(got.Packages.Mods, mods) {
+				t.Errorf("Mods = %+v, want %+v", got.Packages.Mods, mods)
+			}
+		})
+	}
+
+	// Базовый манифест не меняется при применении дельты.
+	if len(base.Packages.Groups[1].Files) != 2 || base.Packages.Groups[1].Files[0].Sha256 != "aa" {
+		t.Errorf("base manifest was modified: %+v", base.Packages.Groups[1].Files)
+	}
+}
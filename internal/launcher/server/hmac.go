@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	SignatureV1 = 1
+	SignatureV2 = 2
+
+	// SignatureVersionHeader — версия подписи в запросе; сервер с поддержкой
+	// v2 возвращает его в ответе, подтверждая схему.
+	SignatureVersionHeader = "X-Signature-Version"
+)
+
+var errUnsignableBody = errors.New("request body cannot be re-read for signing")
+
+// signedHeaders — заголовки, которые входят в подпись v2, если заданы в запросе.
+var signedHeaders = []string{"host", "content-type", "content-length"}
+
+// HMACAuth — авторизация общим секретом. v1 подписывает METHOD\nPATH\nTIMESTAMP;
+// v2 — канонический запрос с запросом, хешем тела, nonce и заголовками.
+// Без явной версии клиент начинает с v2. Если сервер отклоняет подпись и не
+// знает заголовок X-Signature-Version, запрос повторяется с v1; на v1 клиент
+// переходит, только если сервер эту подпись принял или сам ответил версией 1.
+type HMACAuth struct {
+	Secret string
+	// Version — фиксированная версия подписи; 0 — согласовать с сервером.
+	Version int
+
+	mu         sync.Mutex
+	negotiated int
+	// probing — отклонённый запрос повторяется с подписью v1, версия ещё
+	// не согласована.
+	probing bool
+}
+
+func NewHMACAuth(secret string, version int) *HMACAuth {
+	return &HMACAuth{Secret: secret, Version: version}
+}
+
+func (a *HMACAuth) Authorize(ctx context.Context, req *http.Request) error {
+	if strings.TrimSpace(a.Secret) == "" {
+		return nil
+	}
+	ts := signingTime(ctx).Unix()
+	if a.version() == SignatureV1 {
+		req.Header.Set("X-Timestamp", strconv.FormatInt(ts, 10))
+		req.Header.Set("X-Signature", signRequest(a.Secret, req.Method, requestPath(req.URL), ts))
+		return nil
+	}
+	bodyHash, err := requestBodyHash(req)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	headers := make([]string, 0, len(signedHeaders))
+	for _, name := range signedHeaders {
+		if headerValue(req, name) != "" {
+			headers = append(headers, name)
+		}
+	}
+	req.Header.Set(SignatureVersionHeader, strconv.Itoa(SignatureV2))
+	req.Header.Set("X-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Nonce", hex.EncodeToString(nonce))
+	req.Header.Set("X-Content-Sha256", bodyHash)
+	req.Header.Set("X-Signed-Headers", strings.Join(headers, ";"))
+	canonical := canonicalRequest(req, headers, ts, req.Header.Get("X-Nonce"), bodyHash)
+	req.Header.Set("X-Signature", hmacHex(a.Secret, canonical))
+	return nil
+}
+
+// Negotiate разбирает ответ сервера на подписанный запрос. true — запрос
+// нужно подписать заново (v1) и повторить.
+func (a *HMACAuth) Negotiate(resp *http.Response) bool {
+	if a.Version != 0 || resp == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.negotiated != 0 {
+		return false
+	}
+	rejected := resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+	switch version := resp.Header.Get(SignatureVersionHeader); {
+	case version == strconv.Itoa(SignatureV1):
+		// Сервер прямо сообщает, что знает только v1.
+		a.negotiated, a.probing = SignatureV1, false
+		return rejected && !signedV1(resp.Request)
+	case version != "":
+		a.negotiated, a.probing = SignatureV2, false
+		return false
+	}
+	if signedV1(resp.Request) {
+		// Ответ на пробный запрос v1: старый сервер, только если подпись
+		// принята. Иначе дело не в версии (неверный секрет, часы) — остаёмся на v2.
+		a.probing = false
+		if resp.StatusCode < http.StatusBadRequest {
+			a.negotiated = SignatureV1
+		}
+		return false
+	}
+	// Отказ в подписи v2 без версии в ответе: возможно, старый сервер.
+	if rejected {
+		a.probing = true
+		return true
+	}
+	return false
+}
+
+// signedV1 — запрос подписан по v1 (без заголовка версии).
+func signedV1(req *http.Request) bool {
+	return req != nil && req.Header.Get("X-Signature") != "" && req.Header.Get(SignatureVersionHeader) == ""
+}
+
+func (a *HMACAuth) version() int {
+	if a.Version == SignatureV1 || a.Version == SignatureV2 {
+		return a.Version
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.negotiated != 0 {
+		return a.negotiated
+	}
+	if a.probing {
+		return SignatureV1
+	}
+	return SignatureV2
+}
+
+// canonicalRequest — строка подписи v2:
+//
+//	v2\nMETHOD\nPATH\nQUERY\nTIMESTAMP\nNONCE\nHEADERS\nBODY_SHA256
+//
+// QUERY — параметры, отсортированные по имени и значению; HEADERS — строки
+// "имя:значение" в порядке X-Signed-Headers, разделённые "\n".
+func canonicalRequest(req *http.Request, headers []string, ts int64, nonce, bodyHash string) string {
+	lines := make([]string, 0, len(headers))
+	for _, name := range headers {
+		lines = append(lines, name+":"+strings.TrimSpace(headerValue(req, name)))
+	}
+	return strings.Join([]string{
+		"v2",
+		req.Method,
+		requestPath(req.URL),
+		canonicalQuery(req.URL.Query()),
+		strconv.FormatInt(ts, 10),
+		nonce,
+		strings.Join(lines, "\n"),
+		bodyHash,
+	}, "\n")
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(values))
+	for _, key := range keys {
+		vals := append([]string(nil), values[key]...)
+		sort.Strings(vals)
+		for _, value := range vals {
+			parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func headerValue(req *http.Request, name string) string {
+	switch name {
+	case "host":
+		if req.Host != "" {
+			return strings.ToLower(req.Host)
+		}
+		return strings.ToLower(req.URL.Host)
+	case "content-length":
+		if req.ContentLength > 0 {
+			return strconv.FormatInt(req.ContentLength, 10)
+		}
+		return ""
+	default:
+		return req.Header.Get(name)
+	}
+}
+
+// requestBodyHash хеширует тело, не расходуя его: тело перечитывается через GetBody.
+func requestBodyHash(req *http.Request) (string, error) {
+	hasher := sha256.New()
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return "", errUnsignableBody
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(hasher, body)
+		body.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func requestPath(u *url.URL) string {
+	if !strings.HasPrefix(u.Path, "/") {
+		return "/" + u.Path
+	}
+	return u.Path
+}
+
+func signRequest(secret, method, reqPath string, timestamp int64) string {
+	return hmacHex(secret, method+"\n"+reqPath+"\n"+strconv.FormatInt(timestamp, 10))
+}
+
+func hmacHex(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
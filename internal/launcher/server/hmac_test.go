@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACAuthorizeV2(t *testing.T) {
+	const secret = "s3cret"
+	at := time.Unix(1705482000, 0)
+	emptyHash := hex.EncodeToString(sha256.New().Sum(nil))
+	bodyHash := sha256.Sum256([]byte(`{"user":"steve"}`))
+
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		body    string
+		headers string
+		query   string
+		lines   string
+		hash    string
+	}{
+		{
+			name:    "get with sorted query",
+			method:  http.MethodGet,
+			url:     "http://Example.com:8080/manifest?since=2&channel=beta&a=2&a=1",
+			headers: "host",
+			query:   "a=1&a=2&channel=beta&since=2",
+			lines:   "host:example.com:8080",
+			hash:    emptyHash,
+		},
+		{
+			name:    "escaped query",
+			method:  http.MethodGet,
+			url:     "http://example.com/download/mods/a%20b.jar?q=x+y",
+			headers: "host",
+			query:   "q=x+y",
+			lines:   "host:example.com",
+			hash:    emptyHash,
+		},
+		{
+			name:    "post with body",
+			method:  http.MethodPost,
+			url:     "http://example.com/auth/login",
+			body:    `{"user":"steve"}`,
+			headers: "host;content-type;content-length",
+			lines:   "host:example.com\ncontent-type:application/json\ncontent-length:16",
+			hash:    hex.EncodeToString(bodyHash[:]),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.body == "" {
+				req.Body, req.GetBody, req.ContentLength = http.NoBody, nil, 0
+			} else {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			auth := NewHMACAuth(secret, 0)
+			if err := auth.Authorize(withSigningTime(context.Background(), at), req); err != nil {
+				t.Fatalf("Authorize() error = %v", err)
+			}
+			nonce := req.Header.Get("X-Nonce")
+			if len(nonce) != 32 {
+				t.Fatalf("X-Nonce = %q, want 16 random bytes in hex", nonce)
+			}
+			want := map[string]string{
+				SignatureVersionHeader: "2",
+				"X-Timestamp":          "1705482000",
+				"X-Content-Sha256":     tt.hash,
+				"X-Signed-Headers":     tt.headers,
+			}
+			for name, value := range want {
+				if got := req.Header.Get(name); got != value {
+					t.Errorf("%s = %q, want %q", name, got, value)
+				}
+			}
+			path := req.URL.Path
+			canonical := strings.Join([]string{"v2", tt.method, path, tt.query, "1705482000", nonce, tt.lines, tt.hash}, "\n")
+			if got := req.Header.Get("X-Signature"); got != hmacHex(secret, canonical) {
+				t.Errorf("X-Signature does not match canonical request:\n%s", canonical)
+			}
+		})
+	}
+}
+
+func TestHMACAuthNegotiate(t *testing.T) {
+	// step — ответ сервера на запрос, подписанный текущей версией клиента.
+	type step struct {
+		status  int
+		version string
+		retry   bool
+	}
+	tests := []struct {
+		name    string
+		fixed   int
+		steps   []step
+		version int
+	}{
+		{name: "v2 server confirms", steps: []step{{status: http.StatusOK, version: "2"}, {status: http.StatusUnauthorized}}, version: SignatureV2},
+		{name: "v2 server rejects", steps: []step{{status: http.StatusUnauthorized, version: "2"}, {status: http.StatusUnauthorized}}, version: SignatureV2},
+		{
+			name: "old server accepts v1",
+			steps: []step{
+				{status: http.StatusUnauthorized, retry: true},
+				{status: http.StatusOK},
+				{status: http.StatusUnauthorized},
+			},
+			version: SignatureV1,
+		},
+		{
+			name:    "old server forbids v2",
+			steps:   []step{{status: http.StatusForbidden, retry: true}, {status: http.StatusNoContent}},
+			version: SignatureV1,
+		},
+		{
+			name: "v1 retry rejected too",
+			steps: []step{
+				{status: http.StatusUnauthorized, retry: true},
+				{status: http.StatusUnauthorized},
+				// Версия не согласована: следующий отказ снова проверяется
+				// повтором с v1, им и будет подписан повтор.
+				{status: http.StatusUnauthorized, retry: true},
+			},
+			version: SignatureV1,
+		},
+		{
+			name:    "v1 retry fails for other reasons",
+			steps:   []step{{status: http.StatusUnauthorized, retry: true}, {status: http.StatusBadGateway}},
+			version: SignatureV2,
+		},
+		{name: "server asks for v1", steps: []step{{status: http.StatusUnauthorized, version: "1", retry: true}, {status: http.StatusUnauthorized}}, version: SignatureV1},
+		{name: "old server accepts v2 signature", steps: []step{{status: http.StatusOK}}, version: SignatureV2},
+		{name: "fixed version", fixed: SignatureV2, steps: []step{{status: http.StatusUnauthorized}}, version: SignatureV2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := NewHMACAuth("s3cret", tt.fixed)
+			for i, st := range tt.steps {
+				req, _ := http.NewRequest(http.MethodGet, "http://example.com/manifest", nil)
+				if err := auth.Authorize(context.Background(), req); err != nil {
+					t.Fatal(err)
+				}
+				resp := &http.Response{StatusCode: st.status, Header: http.Header{}, Request: req}
+				if st.version != "" {
+					resp.Header.Set(SignatureVersionHeader, st.version)
+				}
+				if got := auth.Negotiate(resp); got != st.retry {
+					t.Fatalf("step %d: Negotiate() = %v, want %v", i, got, st.retry)
+				}
+			}
+			if got := auth.version(); got != tt.version {
+				t.Errorf("version() = %d, want %d", got, tt.version)
+			}
+		})
+	}
+
+	t.Run("v1 signature", func(t *testing.T) {
+		auth := NewHMACAuth("s3cret", SignatureV1)
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/manifest?since=1", nil)
+		if err := auth.Authorize(withSigningTime(context.Background(), time.Unix(100, 0)), req); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := req.Header.Get("X-Signature"), hmacHex("s3cret", "GET\n/manifest\n100"); got != want {
+			t.Errorf("X-Signature = %q, want %q", got, want)
+		}
+		if req.Header.Get(SignatureVersionHeader) != "" || req.Header.Get("X-Nonce") != "" {
+			t.Error("v1 request carries v2 headers")
+		}
+	})
+}
@@ -1,5 +1,14 @@
 package server
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
 type Manifest struct {
 	Project      string           `json:"project"`
 	Studio       string           `json:"studio"`
@@ -7,6 +16,31 @@ type Manifest struct {
 	GeneratedAt  string           `json:"generated_at"`
 	Dependencies Dependencies     `json:"dependencies"`
 	Packages     ManifestPackages `json:"packages"`
+	Sync         SyncPolicy       `json:"sync"`
+	// Channel — канал, к которому относится манифест; Channels — каналы,
+	// которые сервер предлагает выбрать (stable, beta, dev).
+	Channel  string   `json:"channel,omitempty"`
+	Channels []string `json:"channels,omitempty"`
+	// Signed — подписанный исходник манифеста, из которого он получен; по
+	// нему сохранённую копию можно проверить заново (Client.OpenManifest).
+	Signed *SignedManifest `json:"-"`
+}
+
+// Режимы синхронизации mods/.
+const (
+	// SyncStrict — в mods/ остаются только файлы манифеста (по умолчанию).
+	SyncStrict = "strict"
+	// SyncAdditive — удаляются только файлы, которые ставил лаунчер и которых больше нет в манифесте.
+	SyncAdditive = "additive"
+	// SyncAllowlist — как strict, но файлы игрока, подходящие под Allow, не трогаются.
+	SyncAllowlist = "allowlist"
+)
+
+// SyncPolicy описывает, что делать с файлами в mods/, которых нет в манифесте.
+type SyncPolicy struct {
+	Mode string `json:"mode,omitempty"`
+	// Allow — glob-шаблоны путей относительно mods/ ("*minimap*.jar", "optional/**").
+	Allow []string `json:"allow,omitempty"`
 }
 
 type Dependencies struct {
@@ -16,14 +50,151 @@ type Dependencies struct {
 	JavaURLs      JavaURLs `json:"java_urls"`
 }
 
+// ManifestPackages — группы файлов сборки. В JSON это объект "имя группы" ->
+// либо массив файлов (старый формат, как "mods"), либо описание группы.
 type ManifestPackages struct {
-	Mods []FilePackage `json:"mods"`
+	// Mods — файлы группы "mods", оставлены для совместимости.
+	Mods   []FilePackage
+	Groups []PackageGroup
+}
+
+// Правила перезаписи файлов группы.
+const (
+	// OverwriteAlways — файл всегда приводится к версии из манифеста (по умолчанию).
+	OverwriteAlways = "always"
+	// OverwriteMissing — файл ставится, только если его нет.
+	OverwriteMissing = "missing"
+	// OverwriteMerge — обновляются только файлы, которые игрок не менял после установки.
+	OverwriteMerge = "merge"
+)
+
+type PackageGroup struct {
+	Name string `json:"name,omitempty"`
+	// Target — каталог относительно папки сборки; по умолчанию совпадает с Name.
+	Target    string `json:"target,omitempty"`
+	Overwrite string `json:"overwrite,omitempty"`
+	// Sync — политика для лишних файлов группы. Для "mods" по умолчанию
+	// берётся Manifest.Sync, для остальных групп — additive.
+	Sync  *SyncPolicy   `json:"sync,omitempty"`
+	Files []FilePackage `json:"files"`
+}
+
+func (p *ManifestPackages) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	p.Mods = nil
+	p.Groups = nil
+	for name, value := range raw {
+		group := PackageGroup{Name: name}
+		trimmed := bytes.TrimSpace(value)
+		if len(trimmed) > 0 && trimmed[0] == '[' {
+			if err := json.Unmarshal(trimmed, &group.Files); err != nil {
+				return fmt.Errorf("packages.%s: %w", name, err)
+			}
+		} else if err := json.Unmarshal(trimmed, &group); err != nil {
+			return fmt.Errorf("packages.%s: %w", name, err)
+		}
+		group.Name = name
+		if group.Target == "" {
+			group.Target = name
+		}
+		if name == "mods" {
+			p.Mods = group.Files
+		}
+		p.Groups = append(p.Groups, group)
+	}
+	sortGroups(p.Groups)
+	return nil
+}
+
+func (p ManifestPackages) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(p.Groups)+1)
+	for _, group := range p.Groups {
+		out[group.Name] = group
+	}
+	if _, ok := out["mods"]; !ok && len(p.Mods) > 0 {
+		out["mods"] = p.Mods
+	}
+	return json.Marshal(out)
+}
+
+// FileCount — число файлов во всех группах.
+func (p ManifestPackages) FileCount() int {
+	count := 0
+	for _, group := range p.Groups {
+		count += len(group.Files)
+	}
+	return count
+}
+
+// JavaRuntime — архив Java для платформы. OS и Arch — в терминах Go
+// (windows, linux, darwin; amd64, arm64, 386); пустые подходят любой.
+type JavaRuntime struct {
+	Major  int    `json:"major"`
+	OS     string `json:"os,omitempty"`
+	Arch   string `json:"arch,omitempty"`
+	URL    string `json:"url"`
+	Sha256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	// Archive — zip, tar, tar.gz, tar.xz или tar.zst; по умолчанию
+	// определяется по URL.
+	Archive string `json:"archive,omitempty"`
+}
+
+// JavaURLs — java_urls: список архивов Java. Старый объект
+// {"java_8": url, "java_17": url, ...} читается как архивы для windows/amd64
+// без хеша.
+type JavaURLs []JavaRuntime
+
+func (u *JavaURLs) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		var list []JavaRuntime
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return err
+		}
+		*u = list
+		return nil
+	}
+	var legacy map[string]string
+	if err := json.Unmarshal(trimmed, &legacy); err != nil {
+		return err
+	}
+	list := make([]JavaRuntime, 0, len(legacy))
+	for key, url := range legacy {
+		major, err := strconv.Atoi(strings.TrimPrefix(key, "java_"))
+		if err != nil || strings.TrimSpace(url) == "" {
+			continue
+		}
+		list = append(list, JavaRuntime{Major: major, OS: "windows", Arch: "amd64", URL: url})
+	}
+	slices.SortFunc(list, func(a, b JavaRuntime) int { return a.Major - b.Major })
+	*u = list
+	return nil
 }
 
-type JavaURLs struct {
-	Java8  string `json:"java_8"`
-	Java17 string `json:"java_17"`
-	Java21 string `json:"java_21"`
+// Find — архив Java major-версии для платформы; архив для конкретной
+// платформы важнее универсального.
+func (u JavaURLs) Find(major int, goos, arch string) *JavaRuntime {
+	var fallback *JavaRuntime
+	for i := range u {
+		rt := &u[i]
+		if rt.Major != major || strings.TrimSpace(rt.URL) == "" {
+			continue
+		}
+		if (rt.OS != "" && rt.OS != goos) || (rt.Arch != "" && rt.Arch != arch) {
+			continue
+		}
+		if rt.OS != "" && rt.Arch != "" {
+			return rt
+		}
+		if fallback == nil {
+			fallback = rt
+		}
+	}
+	return fallback
 }
 
 type FilePackage struct {
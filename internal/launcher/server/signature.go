@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// ManifestSignatureHeader — заголовок ответа /manifest с подписью тела.
+// Если заголовка нет, подпись берётся из соседнего файла <manifest>.sig.
+const ManifestSignatureHeader = "X-Manifest-Signature"
+
+// ManifestPublicKey — встроенный в сборку открытый ключ манифеста;
+// make build требует его в MANIFEST_PUBLIC_KEY и передаёт так:
+//
+//	go build -ldflags "-X shinecore/internal/launcher/server.ManifestPublicKey=RWQ..."
+//
+// Дополнительные ключи можно закрепить в server.json (manifest_public_keys).
+var ManifestPublicKey string
+
+var (
+	ErrManifestUnsigned  = errors.New("manifest is not signed")
+	ErrManifestSignature = errors.New("manifest signature is invalid")
+	ErrNoManifestKeys    = errors.New("no trusted manifest public keys configured")
+)
+
+const (
+	minisignAlgEd       = "Ed" // подпись самого сообщения
+	minisignAlgPrehash  = "ED" // подпись BLAKE2b-хеша сообщения
+	minisignKeyIDLength = 8
+)
+
+// PublicKey — доверенный ключ Ed25519. Для ключей minisign хранит
+// идентификатор ключа, чтобы сверять его с подписью.
+type PublicKey struct {
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// ParsePublicKey принимает ключ Ed25519 в base64 (32 байта), ключ minisign
+// ("RW...") или содержимое .pub-файла minisign целиком.
+func ParsePublicKey(raw string) (PublicKey, error) {
+	line := lastDataLine(raw)
+	if line == "" {
+		return PublicKey{}, errors.New("empty public key")
+	}
+	data, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("decode public key: %w", err)
+	}
+	switch len(data) {
+	case ed25519.PublicKeySize:
+		return PublicKey{key: ed25519.PublicKey(data)}, nil
+	case 2 + minisignKeyIDLength + ed25519.PublicKeySize:
+		if string(data[:2]) != minisignAlgEd {
+			return PublicKey{}, errors.New("unsupported minisign key algorithm")
+		}
+		return PublicKey{id: data[2:10], key: ed25519.PublicKey(data[10:])}, nil
+	default:
+		return PublicKey{}, fmt.Errorf("invalid public key length %d", len(data))
+	}
+}
+
+// VerifyManifest проверяет подпись тела манифеста хотя бы одним из ключей.
+// signature — подпись Ed25519 в base64 или содержимое .minisig-файла.
+func VerifyManifest(data []byte, signature string, keys []PublicKey) error {
+	if len(keys) == 0 {
+		return ErrNoManifestKeys
+	}
+	signature = strings.TrimSpace(signature)
+	if signature == "" {
+		return ErrManifestUnsigned
+	}
+	if strings.Contains(signature, "\n") {
+		return verifyMinisign(data, signature, keys)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrManifestSignature, err)
+	}
+	switch len(sig) {
+	case ed25519.SignatureSize:
+		for _, key := range keys {
+			if ed25519.Verify(key.key, data, sig) {
+				return nil
+			}
+		}
+		return ErrManifestSignature
+	case 2 + minisignKeyIDLength + ed25519.SignatureSize:
+		// Строка подписи minisign без доверенного комментария: его подпись
+		// обязательна, поэтому нужен файл .minisig целиком.
+		return fmt.Errorf("%w: minisign signature without trusted comment", ErrManifestSignature)
+	default:
+		return fmt.Errorf("%w: invalid signature length %d", ErrManifestSignature, len(sig))
+	}
+}
+
+// verifyMinisign разбирает формат minisign:
+//
+//	untrusted comment: ...
+//	<base64: алгоритм, id ключа, подпись>
+//	trusted comment: ...
+//	<base64: подпись подписи и доверенного комментария>
+func verifyMinisign(data []byte, signature string, keys []PublicKey) error {
+	lines := strings.Split(strings.ReplaceAll(signature, "\r\n", "\n"), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "untrusted comment:") {
+		return fmt.Errorf("%w: malformed minisign signature", ErrManifestSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+minisignKeyIDLength+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed minisign signature", ErrManifestSignature)
+	}
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return fmt.Errorf("%w: minisign signature without trusted comment", ErrManifestSignature)
+	}
+	trusted := []byte(strings.TrimPrefix(lines[2], "trusted comment: "))
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed trusted comment signature", ErrManifestSignature)
+	}
+	return verifyMinisignSig(data, sig, trusted, global, keys)
+}
+
+func verifyMinisignSig(data, sig, trusted, global []byte, keys []PublicKey) error {
+	switch string(sig[:2]) {
+	case minisignAlgEd:
+	case minisignAlgPrehash:
+		// По умолчанию minisign подписывает BLAKE2b-512 сообщения.
+		sum := blake2b.Sum512(data)
+		data = sum[:]
+	default:
+		return fmt.Errorf("%w: unsupported signature algorithm", ErrManifestSignature)
+	}
+	keyID, value := sig[2:10], sig[10:]
+	for _, key := range keys {
+		if key.id != nil && !bytes.Equal(key.id, keyID) {
+			continue
+		}
+		if !ed25519.Verify(key.key, data, value) {
+			continue
+		}
+		if !ed25519.Verify(key.key, append(append([]byte{}, value...), trusted...), global) {
+			continue
+		}
+		return nil
+	}
+	return ErrManifestSignature
+}
+
+// lastDataLine отбрасывает строки комментариев (.pub-файл minisign).
+func lastDataLine(raw string) string {
+	line := ""
+	for _, l := range strings.Split(raw, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "untrusted comment:") {
+			continue
+		}
+		line = l
+	}
+	return line
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+type testKey struct {
+	id   []byte
+	pub  ed25519.PublicKey
+	priv ed25519.PrivateKey
+}
+
+func newTestKey(t *testing.T, id string) testKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{id: []byte(id), pub: pub, priv: priv}
+}
+
+// minisignPub — открытый ключ в формате minisign ("RW...").
+func (k testKey) minisignPub() string {
+	data := append(append([]byte(minisignAlgEd), k.id...), k.pub...)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func (k testKey) rawPub() string {
+	return base64.StdEncoding.EncodeToString(k.pub)
+}
+
+func (k testKey) rawSig(data []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(k.priv, data))
+}
+
+func (k testKey) minisigLine(alg string, data []byte) (string, []byte) {
+	if alg == minisignAlgPrehash {
+		sum := blake2b.Sum512(data)
+		data = sum[:]
+	}
+	value := ed25519.Sign(k.priv, data)
+	sig := append(append([]byte(alg), k.id...), value...)
+	return base64.StdEncoding.EncodeToString(sig), value
+}
+
+// minisig — файл .minisig целиком с доверенным комментарием, как его пишет
+// minisign -S -l.
+func (k testKey) minisig(data []byte, trusted string) string {
+	return k.minisigAlg(minisignAlgEd, data, trusted)
+}
+
+// minisigAlg — файл .minisig с заданным алгоритмом; minisign -S без -l
+// пишет ED (подпись BLAKE2b-хеша).
+func (k testKey) minisigAlg(alg string, data []byte, trusted string) string {
+	line, value := k.minisigLine(alg, data)
+	global := ed25519.Sign(k.priv, append(append([]byte{}, value...), trusted...))
+	return "untrusted comment: signature from minisign secret key\n" + line + "\n" +
+		"trusted comment: " + trusted + "\n" + base64.StdEncoding.EncodeToString(global) + "\n"
+}
+
+func mustParseKeys(t *testing.T, raw ...string) []PublicKey {
+	t.Helper()
+	keys := make([]PublicKey, 0, len(raw))
+	for _, value := range raw {
+		key, err := ParsePublicKey(value)
+		if err != nil {
+			t.Fatalf("parse key %q: %v", value, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestVerifyManifest(t *testing.T) {
+	data := []byte(`{"version":"1.0.0","minecraft":{"version":"1.20.1"}}`)
+	tampered := []byte(`{"version":"1.0.0","minecraft":{"version":"1.20.2"}}`)
+	signer := newTestKey(t, "signer01")
+	other := newTestKey(t, "other001")
+
+	prehashed := signer.minisigAlg(minisignAlgPrehash, data, "timestamp:1700000000")
+	bareLine, _ := signer.minisigLine(minisignAlgEd, data)
+	goodFile := signer.minisig(data, "timestamp:1700000000")
+	// Файл без доверенного комментария и его подписи.
+	noTrusted := strings.Join(strings.SplitN(goodFile, "\n", 3)[:2], "\n") + "\n"
+	// Подменённый доверенный комментарий не сходится с глобальной подписью.
+	forgedComment := signer.minisig(data, "timestamp:1700000000")
+	forgedComment = replaceLine(forgedComment, 2, "trusted comment: timestamp:1800000000")
+	// Ключ с тем же значением, но другим id: подпись принадлежит не ему.
+	wrongID := testKey{id: []byte("wrongid1"), pub: signer.pub, priv: signer.priv}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		signature string
+		keys      []PublicKey
+		want      error
+	}{
+		{"raw ed25519", data, signer.rawSig(data), mustParseKeys(t, signer.rawPub()), nil},
+		{"raw ed25519 with minisign key", data, signer.rawSig(data), mustParseKeys(t, signer.minisignPub()), nil},
+		{"second trusted key", data, signer.rawSig(data), mustParseKeys(t, other.rawPub(), signer.rawPub()), nil},
+		{"minisign file", data, goodFile, mustParseKeys(t, signer.minisignPub()), nil},
+		{"minisign file with crlf", data, crlf(goodFile), mustParseKeys(t, signer.minisignPub()), nil},
+		{"prehashed minisign", data, prehashed, mustParseKeys(t, signer.minisignPub()), nil},
+		{"prehashed minisign with crlf", data, crlf(prehashed), mustParseKeys(t, signer.minisignPub()), nil},
+		{"tampered body", tampered, signer.rawSig(data), mustParseKeys(t, signer.rawPub()), ErrManifestSignature},
+		{"tampered body minisign", tampered, goodFile, mustParseKeys(t, signer.minisignPub()), ErrManifestSignature},
+		{"untrusted key", data, signer.rawSig(data), mustParseKeys(t, other.rawPub()), ErrManifestSignature},
+		{"minisign key id mismatch", data, goodFile, mustParseKeys(t, wrongID.minisignPub()), ErrManifestSignature},
+		{"forged trusted comment", data, forgedComment, mustParseKeys(t, signer.minisignPub()), ErrManifestSignature},
+		{"tampered body prehashed", tampered, prehashed, mustParseKeys(t, signer.minisignPub()), ErrManifestSignature},
+		{"prehashed signature under legacy algorithm", data, replaceLine(prehashed, 1, legacyLine(t, prehashed)), mustParseKeys(t, signer.minisignPub()), ErrManifestSignature},
+		{"bare minisign line", data, bareLine, mustParseKeys(t, signer.minisignPub()), ErrManifestSignature},
+		{"minisign without trusted comment", data, noTrusted, mustParseKeys(t, signer.minisignPub()), ErrManifestSignature},
+		{"malformed minisign", data, "untrusted comment: x\nnot-base64\n", mustParseKeys(t, signer.minisignPub()), ErrManifestSignature},
+		{"not base64", data, "%%%", mustParseKeys(t, signer.rawPub()), ErrManifestSignature},
+		{"wrong length", data, base64.StdEncoding.EncodeToString([]byte("short")), mustParseKeys(t, signer.rawPub()), ErrManifestSignature},
+		{"unsigned", data, "  \n", mustParseKeys(t, signer.rawPub()), ErrManifestUnsigned},
+		{"no keys", data, signer.rawSig(data), nil, ErrNoManifestKeys},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyManifest(tt.data, tt.signature, tt.keys)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("VerifyManifest() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("VerifyManifest() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key := newTestKey(t, "keyid123")
+	tests := []struct {
+		name    string
+		raw     string
+		wantID  bool
+		wantErr bool
+	}{
+		{"raw ed25519", key.rawPub(), false, false},
+		{"minisign", key.minisignPub(), true, false},
+		{"minisign pub file", "untrusted comment: minisign public key 1\n" + key.minisignPub() + "\n", true, false},
+		{"empty", " \n", false, true},
+		{"not base64", "RW%%%", false, true},
+		{"wrong length", base64.StdEncoding.EncodeToString([]byte("short")), false, true},
+		{"unsupported algorithm", base64.StdEncoding.EncodeToString(append([]byte("XX12345678"), key.pub...)), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePublicKey(tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ParsePublicKey() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePublicKey() = %v", err)
+			}
+			if !got.key.Equal(key.pub) {
+				t.Fatal("ParsePublicKey() returned a different key")
+			}
+			if (got.id != nil) != tt.wantID {
+				t.Fatalf("ParsePublicKey() id = %x, want id: %v", got.id, tt.wantID)
+			}
+		})
+	}
+}
+
+func replaceLine(text string, n int, line string) string {
+	lines := strings.Split(text, "\n")
+	lines[n] = line
+	return strings.Join(lines, "\n")
+}
+
+func crlf(text string) string {
+	return strings.ReplaceAll(text, "\n", "\r\n")
+}
+
+// legacyLine выдаёт строку подписи из файла .minisig за подпись Ed: подпись
+// хеша не должна проходить как подпись самого сообщения.
+func legacyLine(t *testing.T, minisig string) string {
+	t.Helper()
+	sig, err := base64.StdEncoding.DecodeString(strings.Split(minisig, "\n")[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(sig, minisignAlgEd)
+	return base64.StdEncoding.EncodeToString(sig)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LauncherRelease — сборка лаунчера из GET /launcher/latest?os=&arch=&channel=.
+// URL — абсолютный или относительно сервера обновлений; Signature — подпись
+// самого бинарника ключом обновлений, встроенным в сборку.
+type LauncherRelease struct {
+	Version   string `json:"version"`
+	URL       string `json:"url"`
+	Sha256    string `json:"sha256"`
+	Size      int64  `json:"size"`
+	Signature string `json:"signature"`
+	Notes     string `json:"notes,omitempty"`
+}
+
+var (
+	ErrNoUpdateKey       = errors.New("launcher update public key is not built in")
+	errReleaseIncomplete = errors.New("launcher release is missing version, url, sha256 or signature")
+)
+
+// FetchLauncherRelease возвращает последнюю сборку лаунчера для платформы;
+// nil без ошибки — сервер не раздаёт обновления лаунчера (404).
+func (c *Client) FetchLauncherRelease(ctx context.Context, goos, arch, channel string) (*LauncherRelease, error) {
+	releaseURL := strings.TrimRight(c.BaseURL, "/") + "/launcher/latest"
+	releaseURL = withQuery(releaseURL, "os", goos)
+	releaseURL = withQuery(releaseURL, "arch", arch)
+	if channel != "" {
+		releaseURL = withQuery(releaseURL, "channel", channel)
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	resp, err := c.do(reqCtx, http.MethodGet, releaseURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusNoContent:
+		return nil, nil
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("launcher release unavailable: %w", c.unauthorizedError(resp.Request))
+	default:
+		return nil, errors.New("launcher release unavailable: server error " + resp.Status)
+	}
+	release := &LauncherRelease{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(release); err != nil {
+		return nil, err
+	}
+	if release.Version == "" || release.URL == "" || release.Sha256 == "" || strings.TrimSpace(release.Signature) == "" {
+		return nil, errReleaseIncomplete
+	}
+	release.Version = strings.TrimPrefix(release.Version, "v")
+	return release, nil
+}
+
+// VerifyLauncherSignature проверяет подпись сборки лаунчера только ключом
+// publicKey из сборки: ключи манифеста из server.json здесь не действуют,
+// иначе добавленный игроком сервер мог бы подписать свой бинарник.
+func VerifyLauncherSignature(data []byte, signature, publicKey string) error {
+	if strings.TrimSpace(publicKey) == "" {
+		return ErrNoUpdateKey
+	}
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	return VerifyManifest(data, signature, []PublicKey{key})
+}
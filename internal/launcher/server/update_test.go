@@ -0,0 +1,36 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVerifyLauncherSignature(t *testing.T) {
+	update := newTestKey(t, "update01")
+	manifest := newTestKey(t, "profile1")
+	binary := []byte("launcher binary")
+
+	tests := []struct {
+		name      string
+		signature string
+		key       string
+		wantErr   error
+	}{
+		{name: "built-in key", signature: update.rawSig(binary), key: update.minisignPub()},
+		{name: "minisign file", signature: update.minisig(binary, "file:ShineCore.exe"), key: update.minisignPub()},
+		{name: "signed by another key", signature: manifest.rawSig(binary), key: update.minisignPub(), wantErr: ErrManifestSignature},
+		{name: "tampered binary", signature: update.rawSig([]byte("other binary")), key: update.rawPub(), wantErr: ErrManifestSignature},
+		{name: "no built-in key", signature: update.rawSig(binary), wantErr: ErrNoUpdateKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyLauncherSignature(binary, tt.signature, tt.key)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("VerifyLauncherSignature() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyLauncherSignature() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"shinecore/internal/launcher/config"
+	"shinecore/internal/launcher/server"
+)
+
+// defaultChannel — канал сервера, который не объявляет каналы в манифесте.
+const defaultChannel = "stable"
+
+// loadServerProfile — выбранный профиль сервера из server.json.
+func loadServerProfile() (*config.ServerProfile, error) {
+	serverCfg, err := config.LoadServer("")
+	if err != nil {
+		return nil, err
+	}
+	return serverCfg.Profile("")
+}
+
+// ServerProfiles возвращает реестр профилей серверов.
+func (l *Launcher) ServerProfiles() (*config.ServerConfig, error) {
+	return config.LoadServer("")
+}
+
+// SaveServerProfile добавляет профиль или обновляет профиль с тем же ID.
+func (l *Launcher) SaveServerProfile(profile config.ServerProfile) (*config.ServerProfile, error) {
+	serverCfg, err := config.LoadServer("")
+	if err != nil {
+		return nil, err
+	}
+	saved, err := serverCfg.PutProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	result := *saved
+	if err := serverCfg.Save(""); err != nil {
+		return nil, err
+	}
+	slog.Info("launcher: server profile saved", "profile", result.ID, "server", result.ServerBaseURL)
+	return &result, nil
+}
+
+// RemoveServerProfile удаляет профиль и его сохранённый вход.
+func (l *Launcher) RemoveServerProfile(id string) error {
+	serverCfg, err := config.LoadServer("")
+	if err != nil {
+		return err
+	}
+	if err := serverCfg.RemoveProfile(id); err != nil {
+		return err
+	}
+	if err := serverCfg.Save(""); err != nil {
+		return err
+	}
+	if path, err := config.ProfileCredentialsPath(id); err == nil {
+		_ = config.RemoveCredentials(path)
+	}
+	return nil
+}
+
+// SelectServerProfile переключает сервер сборки. Моды приводятся к манифесту
+// нового сервера при следующей синхронизации.
+func (l *Launcher) SelectServerProfile(id string) error {
+	serverCfg, err := config.LoadServer("")
+	if err != nil {
+		return err
+	}
+	if err := serverCfg.SelectProfile(id); err != nil {
+		return err
+	}
+	slog.Info("launcher: server profile selected", "profile", serverCfg.SelectedProfile)
+	return serverCfg.Save("")
+}
+
+// Channel — канал выбранного профиля: выбранный игроком, иначе канал
+// манифеста, иначе stable.
+func (l *Launcher) Channel(manifest *server.Manifest) string {
+	if profile, err := loadServerProfile(); err == nil && profile.Channel != "" {
+		return profile.Channel
+	}
+	if manifest != nil && manifest.Channel != "" {
+		return strings.ToLower(manifest.Channel)
+	}
+	return defaultChannel
+}
+
+// Channels — каналы, которые сервер выбранного профиля объявляет в манифесте,
+// и текущий канал. Сервер без каналов предлагает только текущий.
+func (l *Launcher) Channels(ctx context.Context) (channels []string, current string, err error) {
+	result, err := l.FetchManifest(ctx, "")
+	if err != nil {
+		return nil, l.Channel(nil), err
+	}
+	current = l.Channel(result.Manifest)
+	for _, channel := range result.Manifest.Channels {
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if channel != "" && !slices.Contains(channels, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	if len(channels) == 0 {
+		channels = []string{current}
+	}
+	return channels, current, nil
+}
+
+// SetChannel выбирает канал сборки в текущем профиле; канал должен быть
+// среди объявленных сервером.
+func (l *Launcher) SetChannel(ctx context.Context, channel string) error {
+	channel = strings.ToLower(strings.TrimSpace(channel))
+	if channel == "" {
+		return errors.New("channel is required")
+	}
+	channels, _, err := l.Channels(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(channels, channel) {
+		return errors.New("channel is not offered by the server: " + channel)
+	}
+	serverCfg, err := config.LoadServer("")
+	if err != nil {
+		return err
+	}
+	profile, err := serverCfg.Profile("")
+	if err != nil {
+		return err
+	}
+	profile.Channel = channel
+	slog.Info("launcher: channel selected", "profile", profile.ID, "channel", channel)
+	return serverCfg.Save("")
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// lockStaleAfter — блокировку старше этого оставил упавший процесс.
+	lockStaleAfter = 30 * time.Second
+	lockTimeout    = 10 * time.Second
+)
+
+var errLocked = errors.New("store: refs are locked by another process")
+
+// lockFile берёт межпроцессную блокировку: создаёт path, если его нет.
+// Возвращает функцию снятия блокировки. Пока блокировка держится, её mtime
+// обновляется, чтобы долгий GC не сочли упавшим процессом.
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_, _ = file.WriteString(strconv.Itoa(os.Getpid()))
+			_ = file.Close()
+			return holdLock(path), nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockStaleAfter {
+			_ = os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errLocked
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func holdLock(path string) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lockStaleAfter / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				_ = os.Chtimes(path, now, now)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		_ = os.Remove(path)
+	}
+}
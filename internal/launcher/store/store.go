@@ -0,0 +1,614 @@
+// Package store implements a content-addressed file store shared by all
+// installs: libraries, assets and installers are kept once under their hash
+// and hard-linked (or copied) into every directory that needs them. Objects
+// are read-only and re-verified before they are linked again.
+package store
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"shinecore/internal/launcher/download"
+)
+
+const (
+	refsFileName = "refs.json"
+	pinsFileName = "pins.json"
+	lockFileName = "refs.lock"
+	// objectMode — объекты только для чтения: жёсткая ссылка в папке
+	// установки не должна позволять править объект на месте.
+	objectMode fs.FileMode = 0o444
+)
+
+var ErrHashMismatch = errors.New("store: hash mismatch")
+
+// Store хранит объекты в <Root>/objects/<algo>/<xx>/<hash> и ведёт учёт ссылок:
+// какие пути в установках указывают на объект. Объекты без ссылок удаляет GC.
+//
+// Хранилищем могут одновременно пользоваться несколько процессов (интерфейс
+// и CLI), поэтому refs.json и pins.json не перезаписываются целиком: под
+// блокировкой refs.lock к их текущему содержимому применяются свои изменения.
+type Store struct {
+	Root string
+	// Verification — индекс проверенных файлов операции, открывшей
+	// хранилище: неизменённые объекты и ссылки по нему не хешируются заново.
+	Verification download.Verification
+
+	mu   sync.Mutex
+	refs map[string]map[string]struct{} // "<algo>/<hash>" -> пути
+	// added и removed — ссылки, добавленные и снятые с прошлого сохранения.
+	added   map[string]map[string]struct{}
+	removed map[string]map[string]struct{}
+	// pins — владелец -> объекты "<algo>/<hash>", которые GC не удаляет,
+	// даже если на них не ссылается ни один файл (например, файлы LKG-сборки).
+	pins map[string][]string
+	// pinChanges — изменённые закрепления; nil — закрепление снято.
+	pinChanges map[string][]string
+}
+
+// Object — объект хранилища по хешу.
+type Object struct {
+	Algo string
+	Hash string
+}
+
+func (o Object) key() string {
+	return o.Algo + "/" + strings.ToLower(o.Hash)
+}
+
+type GCResult struct {
+	RemovedObjects int   `json:"removed_objects"`
+	FreedBytes     int64 `json:"freed_bytes"`
+	KeptObjects    int   `json:"kept_objects"`
+}
+
+func Open(root string) (*Store, error) {
+	if strings.TrimSpace(root) == "" {
+		return nil, errors.New("store root is empty")
+	}
+	if err := os.MkdirAll(filepath.Join(root, "objects"), 0o755); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		Root:       root,
+		added:      map[string]map[string]struct{}{},
+		removed:    map[string]map[string]struct{}{},
+		pinChanges: map[string][]string{},
+	}
+	var err error
+	if s.pins, err = readPins(root); err != nil {
+		return nil, err
+	}
+	if s.refs, err = readRefs(root); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func readPins(root string) (map[string][]string, error) {
+	pins := map[string][]string{}
+	data, err := os.ReadFile(filepath.Join(root, pinsFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pins, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+func readRefs(root string) (map[string]map[string]struct{}, error) {
+	refs := map[string]map[string]struct{}{}
+	data, err := os.ReadFile(filepath.Join(root, refsFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return refs, nil
+		}
+		return nil, err
+	}
+	if len(data) == 0 {
+		return refs, nil
+	}
+	var stored map[string][]string
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	for key, paths := range stored {
+		set := make(map[string]struct{}, len(paths))
+		for _, p := range paths {
+			set[p] = struct{}{}
+		}
+		refs[key] = set
+	}
+	return refs, nil
+}
+
+// Close сохраняет таблицу ссылок.
+func (s *Store) Close() error {
+	return s.Flush()
+}
+
+// Flush сохраняет свои изменения ссылок и закреплений, не теряя записанных
+// тем временем другим процессом.
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.added) == 0 && len(s.removed) == 0 && len(s.pinChanges) == 0 {
+		return nil
+	}
+	unlock, err := lockFile(filepath.Join(s.Root, lockFileName))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.syncLocked()
+}
+
+// syncLocked перечитывает refs.json и pins.json, накладывает на них свои
+// изменения и сохраняет результат. Вызывается под s.mu и refs.lock.
+func (s *Store) syncLocked() error {
+	pins, err := readPins(s.Root)
+	if err != nil {
+		return err
+	}
+	if len(s.pinChanges) > 0 {
+		for owner, keys := range s.pinChanges {
+			if keys == nil {
+				delete(pins, owner)
+			} else {
+				pins[owner] = keys
+			}
+		}
+		data, err := json.MarshalIndent(pins, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(s.Root, pinsFileName), data); err != nil {
+			return err
+		}
+		s.pinChanges = map[string][]string{}
+	}
+	s.pins = pins
+
+	refs, err := readRefs(s.Root)
+	if err != nil {
+		return err
+	}
+	if len(s.added) == 0 && len(s.removed) == 0 {
+		s.refs = refs
+		return nil
+	}
+	for key, paths := range s.added {
+		set, ok := refs[key]
+		if !ok {
+			set = map[string]struct{}{}
+			refs[key] = set
+		}
+		for p := range paths {
+			set[p] = struct{}{}
+		}
+	}
+	for key, paths := range s.removed {
+		for p := range paths {
+			delete(refs[key], p)
+		}
+		if len(refs[key]) == 0 {
+			delete(refs, key)
+		}
+	}
+	out := make(map[string][]string, len(refs))
+	for key, set := range refs {
+		paths := make([]string, 0, len(set))
+		for p := range set {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		out[key] = paths
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(s.Root, refsFileName), data); err != nil {
+		return err
+	}
+	s.refs = refs
+	s.added = map[string]map[string]struct{}{}
+	s.removed = map[string]map[string]struct{}{}
+	return nil
+}
+
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	_ = os.Remove(path)
+	return os.Rename(tmp, path)
+}
+
+// Pin закрепляет за владельцем набор объектов, заменяя прежний; пустой
+// набор снимает закрепление.
+func (s *Store) Pin(owner string, objects []Object) {
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		keys = append(keys, obj.key())
+	}
+	sort.Strings(keys)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(keys) == 0 {
+		delete(s.pins, owner)
+		s.pinChanges[owner] = nil
+		return
+	}
+	s.pins[owner] = keys
+	s.pinChanges[owner] = keys
+}
+
+// Keep копирует файл src в хранилище, не заменяя src ссылкой: файл может
+// меняться игроком, а объект хранилища должен остаться прежним.
+func (s *Store) Keep(algo, sum, src string) error {
+	obj, err := s.objectPath(algo, sum)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(obj); err == nil {
+		if ok, err := s.intact(algo, sum, obj); err != nil || ok {
+			return err
+		}
+	}
+	got, err := fileHash(algo, src)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(got, sum) {
+		return ErrHashMismatch
+	}
+	if err := copyFile(src, obj); err != nil {
+		return err
+	}
+	seal(obj)
+	return nil
+}
+
+// CopyTo кладёт в dst копию объекта (не жёсткую ссылку). false — объекта нет.
+func (s *Store) CopyTo(algo, sum, dst string) (bool, error) {
+	obj, err := s.objectPath(algo, sum)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(obj); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if ok, err := s.intact(algo, sum, obj); err != nil || !ok {
+		return false, err
+	}
+	if err := copyFile(obj, dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) pinned() map[string]struct{} {
+	out := map[string]struct{}{}
+	for _, keys := range s.pins {
+		for _, key := range keys {
+			out[key] = struct{}{}
+		}
+	}
+	return out
+}
+
+func (s *Store) Has(algo, sum string) bool {
+	path, err := s.objectPath(algo, sum)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Link материализует объект в dst и записывает ссылку. Возвращает false,
+// если объекта в хранилище нет или он повреждён (тогда он удаляется).
+func (s *Store) Link(algo, sum, dst string) (bool, error) {
+	obj, err := s.objectPath(algo, sum)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(obj); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if ok, err := s.intact(algo, sum, obj); err != nil || !ok {
+		return false, err
+	}
+	seal(obj)
+	objInfo, err := os.Stat(obj)
+	if err != nil {
+		return false, err
+	}
+	if dstInfo, err := os.Stat(dst); err == nil && os.SameFile(objInfo, dstInfo) {
+		s.addRef(algo, sum, dst)
+		return true, nil
+	}
+	if err := materialize(obj, dst); err != nil {
+		return false, err
+	}
+	s.addRef(algo, sum, dst)
+	return true, nil
+}
+
+// Put проверяет хеш уже скачанного файла src, переносит его в хранилище
+// и оставляет на месте src ссылку на объект.
+func (s *Store) Put(algo, sum, src string) error {
+	obj, err := s.objectPath(algo, sum)
+	if err != nil {
+		return err
+	}
+	got, err := fileHash(algo, src)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(got, sum) {
+		return ErrHashMismatch
+	}
+	if _, err := os.Stat(obj); err == nil {
+		ok, err := s.intact(algo, sum, obj)
+		if err != nil {
+			return err
+		}
+		if ok {
+			seal(obj)
+			if err := materialize(obj, src); err != nil {
+				return err
+			}
+			s.addRef(algo, sum, src)
+			return nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(obj), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, obj); err != nil {
+		// Другой том: копируем в хранилище, исходный файл остаётся как есть.
+		if err := copyFile(src, obj); err != nil {
+			return err
+		}
+		seal(obj)
+		s.addRef(algo, sum, src)
+		return nil
+	}
+	seal(obj)
+	if err := materialize(obj, src); err != nil {
+		return err
+	}
+	s.addRef(algo, sum, src)
+	return nil
+}
+
+// GC удаляет устаревшие ссылки (файл удалён или заменён другим содержимым)
+// и объекты, на которые больше никто не ссылается.
+func (s *Store) GC() (GCResult, error) {
+	var result GCResult
+	objectsDir := filepath.Join(s.Root, "objects")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Блокировка держится всю сборку: ссылки, записанные другим процессом,
+	// видны GC, и его объекты не удаляются.
+	unlock, err := lockFile(filepath.Join(s.Root, lockFileName))
+	if err != nil {
+		return result, err
+	}
+	defer unlock()
+	if err := s.syncLocked(); err != nil {
+		return result, err
+	}
+	pinned := s.pinned()
+	err = filepath.WalkDir(objectsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(objectsDir, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) != 3 {
+			return nil
+		}
+		key := parts[0] + "/" + parts[2]
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		for ref := range s.refs[key] {
+			if !s.refAlive(info, parts[0], parts[2], ref) {
+				s.dropRef(key, ref)
+			}
+		}
+		if _, ok := pinned[key]; ok || len(s.refs[key]) > 0 {
+			result.KeptObjects++
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		delete(s.refs, key)
+		result.RemovedObjects++
+		result.FreedBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	for key, set := range s.refs {
+		if len(set) == 0 {
+			delete(s.refs, key)
+		}
+	}
+	return result, s.syncLocked()
+}
+
+func (s *Store) addRef(algo, sum, path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	key := algo + "/" + strings.ToLower(sum)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addPath(s.refs, key, path)
+	addPath(s.added, key, path)
+	delete(s.removed[key], path)
+}
+
+// dropRef снимает ссылку; вызывается под s.mu.
+func (s *Store) dropRef(key, path string) {
+	delete(s.refs[key], path)
+	delete(s.added[key], path)
+	addPath(s.removed, key, path)
+}
+
+func addPath(refs map[string]map[string]struct{}, key, path string) {
+	set, ok := refs[key]
+	if !ok {
+		set = map[string]struct{}{}
+		refs[key] = set
+	}
+	set[path] = struct{}{}
+}
+
+// intact проверяет хеш объекта; объект, не менявшийся с прошлой проверки,
+// по индексу s.Verification заново не хешируется (в глубоком режиме —
+// хешируется). Повреждённый объект удаляется: его заменит новая загрузка.
+func (s *Store) intact(algo, sum, obj string) (bool, error) {
+	ok, err := s.Verification.VerifyFile(obj, 0, download.Checksum{Algo: algo, Value: strings.ToLower(sum)})
+	if err != nil || ok {
+		return ok, err
+	}
+	slog.Warn("store: object is corrupt, removing", "object", obj)
+	if err := os.Remove(obj); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return false, nil
+}
+
+// seal снимает с объекта право записи. Удаление ссылки в Windows снимает
+// атрибут «только чтение» и с объекта, поэтому он ставится при каждом связывании.
+func seal(obj string) {
+	if err := os.Chmod(obj, objectMode); err != nil {
+		slog.Warn("store: make object read-only failed", "object", obj, "error", err)
+	}
+}
+
+func (s *Store) objectPath(algo, sum string) (string, error) {
+	sum = strings.ToLower(strings.TrimSpace(sum))
+	if _, err := newHash(algo); err != nil {
+		return "", err
+	}
+	if len(sum) < 8 || strings.Trim(sum, "0123456789abcdef") != "" {
+		return "", errors.New("store: invalid hash: " + sum)
+	}
+	return filepath.Join(s.Root, "objects", algo, sum[:2], sum), nil
+}
+
+// refAlive — ссылка жива, если по пути лежит тот же файл (жёсткая ссылка)
+// или копия с тем же хешем.
+func (s *Store) refAlive(objInfo os.FileInfo, algo, sum, path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if os.SameFile(objInfo, info) {
+		return true
+	}
+	if info.Size() != objInfo.Size() {
+		return false
+	}
+	ok, _ := s.Verification.VerifyFile(path, 0, download.Checksum{Algo: algo, Value: sum})
+	return ok
+}
+
+func materialize(obj, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp := dst + ".link"
+	_ = os.Remove(tmp)
+	if err := os.Link(obj, tmp); err != nil {
+		// Хранилище на другом томе или ФС без жёстких ссылок.
+		if err := copyFile(obj, tmp); err != nil {
+			return err
+		}
+	}
+	_ = os.Remove(dst)
+	return os.Rename(tmp, dst)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	_ = os.Remove(dst)
+	return os.Rename(tmp, dst)
+}
+
+func fileHash(algo, path string) (string, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func newHash(algo string) (hash.Hash, error) {
+	return download.NewHash(algo)
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"shinecore/internal/launcher/download"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// put кладёт в хранилище файл с содержимым data по пути path.
+func put(t *testing.T, s *Store, path, data string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256Hex(data)
+	if err := s.Put(download.AlgoSHA256, sum, path); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	return sum
+}
+
+func replace(t *testing.T, path, data string) {
+	t.Helper()
+	_ = os.Remove(path)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGC(t *testing.T) {
+	tests := []struct {
+		name string
+		// change меняет установку после Put; ref — путь ссылки.
+		change  func(t *testing.T, s *Store, ref, sum string)
+		pin     bool
+		removed bool
+	}{
+		{name: "hard link kept", change: func(*testing.T, *Store, string, string) {}},
+		{
+			name: "copy with same hash kept",
+			change: func(t *testing.T, s *Store, ref, sum string) {
+				replace(t, ref, "library-v1")
+			},
+		},
+		{
+			name: "replaced with same size removed",
+			change: func(t *testing.T, s *Store, ref, sum string) {
+				replace(t, ref, "library-v2")
+			},
+			removed: true,
+		},
+		{
+			name: "deleted file removed",
+			change: func(t *testing.T, s *Store, ref, sum string) {
+				_ = os.Remove(ref)
+			},
+			removed: true,
+		},
+		{
+			name: "pinned object kept without refs",
+			change: func(t *testing.T, s *Store, ref, sum string) {
+				_ = os.Remove(ref)
+			},
+			pin: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := Open(filepath.Join(dir, "store"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ref := filepath.Join(dir, "game", "libraries", "lib.jar")
+			sum := put(t, s, ref, "library-v1")
+			if tt.pin {
+				s.Pin("lkg/main", []Object{{Algo: download.AlgoSHA256, Hash: sum}})
+			}
+			tt.change(t, s, ref, sum)
+			if err := s.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			result, err := s.GC()
+			if err != nil {
+				t.Fatalf("GC() error = %v", err)
+			}
+			if got := result.RemovedObjects == 1; got != tt.removed {
+				t.Errorf("GC() = %+v, removed = %v, want %v", result, got, tt.removed)
+			}
+			if got := s.Has(download.AlgoSHA256, sum); got == tt.removed {
+				t.Errorf("Has() after GC = %v, want %v", got, !tt.removed)
+			}
+		})
+	}
+}
+
+func TestLinkVerifiesObject(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := put(t, s, filepath.Join(dir, "a", "lib.jar"), "library")
+	obj, err := s.objectPath(download.AlgoSHA256, sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != objectMode {
+			t.Errorf("object mode = %v, want %v", info.Mode().Perm(), objectMode)
+		}
+	}
+
+	dst := filepath.Join(dir, "b", "lib.jar")
+	if ok, err := s.Link(download.AlgoSHA256, sum, dst); err != nil || !ok {
+		t.Fatalf("Link() = %v, %v; want linked", ok, err)
+	}
+
+	// Объект испорчен записью на месте: Link не должен его раздавать.
+	if err := os.Chmod(obj, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(obj, []byte("corrupt"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "c", "lib.jar")
+	if ok, err := s.Link(download.AlgoSHA256, sum, other); err != nil || ok {
+		t.Fatalf("Link() of corrupt object = %v, %v; want not linked", ok, err)
+	}
+	if s.Has(download.AlgoSHA256, sum) {
+		t.Error("corrupt object was kept")
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Error("corrupt object was linked")
+	}
+	if ok, err := s.CopyTo(download.AlgoSHA256, sum, other); err != nil || ok {
+		t.Errorf("CopyTo() of missing object = %v, %v", ok, err)
+	}
+}
+
+func TestFlushMergesProcesses(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "store")
+	gui, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guiSum := put(t, gui, filepath.Join(dir, "gui", "a.jar"), "gui file")
+	cliSum := put(t, cli, filepath.Join(dir, "cli", "b.jar"), "cli file")
+	gui.Pin("lkg/gui", []Object{{Algo: download.AlgoSHA256, Hash: guiSum}})
+	cli.Pin("lkg/cli", []Object{{Algo: download.AlgoSHA256, Hash: cliSum}})
+	for _, s := range []*Store{cli, gui} {
+		if err := s.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reopened, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sum := range []string{guiSum, cliSum} {
+		if len(reopened.refs[download.AlgoSHA256+"/"+sum]) != 1 {
+			t.Errorf("ref of %s lost: %v", sum[:8], reopened.refs)
+		}
+	}
+	if len(reopened.pins) != 2 {
+		t.Errorf("pins = %v, want both owners", reopened.pins)
+	}
+
+	// GC процесса, открывшего хранилище раньше, видит ссылки другого.
+	late := put(t, cli, filepath.Join(dir, "cli", "c.jar"), "late file")
+	if err := cli.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gui.GC(); err != nil {
+		t.Fatal(err)
+	}
+	if !gui.Has(download.AlgoSHA256, late) {
+		t.Error("GC removed an object referenced by another process")
+	}
+}
@@ -0,0 +1,208 @@
+package launcher
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"shinecore/internal/launcher/download"
+	"shinecore/internal/launcher/server"
+)
+
+const (
+	stateDirName      = ".shinecore"
+	syncStateFileName = "sync.json"
+)
+
+// syncState — локальная запись о файлах, которые поставил лаунчер.
+// Лежит в <instance>/.shinecore/sync.json; по ней additive и allowlist
+// отличают свои файлы от добавленных игроком, а merge — изменённые игроком.
+type syncState struct {
+	// Files — ключ fileKey пути относительно папки сборки ("mods/x.jar").
+	Files map[string]ownedFile `json:"files"`
+	// ManifestVersion — версия манифеста, полностью применённая последней
+	// успешной синхронизацией; от неё запрашивается дельта манифеста.
+	ManifestVersion string `json:"manifest_version,omitempty"`
+	UpdatedAt       string `json:"updated_at,omitempty"`
+}
+
+type ownedFile struct {
+	Size int64 `json:"size"`
+	// Sha256 — хеш файла в момент установки лаунчером.
+	Sha256 string `json:"sha256,omitempty"`
+}
+
+// sameContent — содержимое файла совпадает с хешем sha. Неизменённый с
+// прошлой проверки файл заново не хешируется: это решает индекс
+// проверенных файлов операции ctx.
+func sameContent(ctx context.Context, path, sha string) bool {
+	if strings.TrimSpace(sha) == "" {
+		return false
+	}
+	same, _ := download.VerifyFile(ctx, path, 0, download.SHA256(sha))
+	return same
+}
+
+// group возвращает записи группы с ключами относительно её каталога.
+func (s *syncState) group(target string) map[string]ownedFile {
+	prefix := fileKey(target) + "/"
+	out := map[string]ownedFile{}
+	for key, file := range s.Files {
+		if strings.HasPrefix(key, prefix) {
+			out[strings.TrimPrefix(key, prefix)] = file
+		}
+	}
+	return out
+}
+
+// setGroup заменяет записи группы.
+func (s *syncState) setGroup(target string, files map[string]ownedFile) {
+	prefix := fileKey(target) + "/"
+	for key := range s.Files {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.Files, key)
+		}
+	}
+	for key, file := range files {
+		s.Files[prefix+key] = file
+	}
+}
+
+func syncStatePath(instDir string) string {
+	return filepath.Join(instDir, stateDirName, syncStateFileName)
+}
+
+// loadSyncState читает запись; отсутствующий или повреждённый файл — пустая запись.
+func loadSyncState(instDir string) *syncState {
+	state := &syncState{Files: map[string]ownedFile{}}
+	data, err := os.ReadFile(syncStatePath(instDir))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("sync: read state failed", "error", err)
+		}
+		return state
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		slog.Warn("sync: state is corrupt, starting over", "error", err)
+		return &syncState{Files: map[string]ownedFile{}}
+	}
+	if state.Files == nil {
+		state.Files = map[string]ownedFile{}
+	}
+	return state
+}
+
+func (s *syncState) save(instDir string) error {
+	s.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := syncStatePath(instDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	_ = os.Remove(path)
+	return os.Rename(tmp, path)
+}
+
+// groupPolicy — политика лишних файлов группы: своя, для "mods" —
+// общая из манифеста, для остальных — additive.
+func groupPolicy(manifest *server.Manifest, group server.PackageGroup) server.SyncPolicy {
+	if group.Sync != nil {
+		return *group.Sync
+	}
+	if group.Name == "mods" {
+		return manifest.Sync
+	}
+	return server.SyncPolicy{Mode: server.SyncAdditive}
+}
+
+// groupExtras — файлы группы, которые нужно удалить по политике.
+// Ключи expected, local и owned — fileKey относительно каталога группы,
+// local — ключ -> полный путь. Свои файлы, изменённые игроком, в режимах
+// additive и allowlist не удаляются.
+func groupExtras(ctx context.Context, policy server.SyncPolicy, expected map[string]server.FilePackage, local map[string]string, owned map[string]ownedFile) []string {
+	mode := syncMode(policy)
+	extras := make([]string, 0)
+	for key, fullPath := range local {
+		if _, ok := expected[key]; ok {
+			continue
+		}
+		file, ours := owned[key]
+		switch mode {
+		case server.SyncAdditive, server.SyncAllowlist:
+			if !ours && (mode == server.SyncAdditive || matchAny(policy.Allow, key)) {
+				continue
+			}
+			if ours && file.Sha256 != "" && !sameContent(ctx, fullPath, file.Sha256) {
+				continue
+			}
+		}
+		extras = append(extras, fullPath)
+	}
+	return extras
+}
+
+func syncMode(policy server.SyncPolicy) string {
+	mode := strings.ToLower(strings.TrimSpace(policy.Mode))
+	switch mode {
+	case "":
+		return server.SyncStrict
+	case server.SyncStrict, server.SyncAdditive, server.SyncAllowlist:
+		return mode
+	default:
+		// Неизвестный режим из более нового манифеста: не удаляем чужие файлы.
+		slog.Warn("sync: unknown mode, using additive", "mode", policy.Mode)
+		return server.SyncAdditive
+	}
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(fileKey(strings.TrimSpace(pattern)), key) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob сопоставляет путь со слешами с шаблоном path.Match,
+// дополнительно поддерживая "**" как любое число каталогов.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,126 @@
+package launcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"shinecore/internal/launcher/server"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFiles создаёт файлы в dir и возвращает ключ -> полный путь.
+func writeFiles(t *testing.T, dir string, files map[string]string) map[string]string {
+	t.Helper()
+	local := map[string]string{}
+	for key, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		local[key] = path
+	}
+	return local
+}
+
+func TestSyncMode(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{mode: "", want: server.SyncStrict},
+		{mode: "strict", want: server.SyncStrict},
+		{mode: " Additive ", want: server.SyncAdditive},
+		{mode: "ALLOWLIST", want: server.SyncAllowlist},
+		{mode: "mirror", want: server.SyncAdditive},
+	}
+	for _, tt := range tests {
+		if got := syncMode(server.SyncPolicy{Mode: tt.mode}); got != tt.want {
+			t.Errorf("syncMode(%q) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		key      string
+		want     bool
+	}{
+		{patterns: []string{"*minimap*.jar"}, key: "xaero-minimap-1.0.jar", want: true},
+		{patterns: []string{"*minimap*.jar"}, key: "optional/minimap.jar", want: false},
+		{patterns: []string{"optional/**"}, key: "optional/minimap.jar", want: true},
+		{patterns: []string{"optional/**"}, key: "optional/a/b/c.jar", want: true},
+		{patterns: []string{"optional/**"}, key: "other/minimap.jar", want: false},
+		{patterns: []string{"**/*.cfg"}, key: "client.cfg", want: true},
+		{patterns: []string{"**/*.cfg"}, key: "a/b/client.cfg", want: true},
+		{patterns: []string{"a/**/c.jar"}, key: "a/c.jar", want: true},
+		{patterns: []string{"a/**/c.jar"}, key: "a/b/c.jar.disabled", want: false},
+		{patterns: []string{" Optional/X.jar "}, key: "optional/x.jar", want: true},
+		{patterns: []string{"[", "x.jar"}, key: "x.jar", want: true},
+		{patterns: []string{"["}, key: "[", want: false},
+		{patterns: nil, key: "x.jar", want: false},
+	}
+	for _, tt := range tests {
+		if got := matchAny(tt.patterns, tt.key); got != tt.want {
+			t.Errorf("matchAny(%q, %q) = %v, want %v", tt.patterns, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestGroupExtras(t *testing.T) {
+	dir := t.TempDir()
+	local := writeFiles(t, dir, map[string]string{
+		"keep.jar":       "keep",
+		"ours.jar":       "ours",
+		"edited.jar":     "edited by player",
+		"player.jar":     "player",
+		"optional/x.jar": "optional",
+	})
+	expected := map[string]server.FilePackage{"keep.jar": {Path: "keep.jar", Sha256: sha256Hex("keep")}}
+	owned := map[string]ownedFile{
+		"keep.jar":   {Sha256: sha256Hex("keep")},
+		"ours.jar":   {Sha256: sha256Hex("ours")},
+		"edited.jar": {Sha256: sha256Hex("edited")},
+	}
+
+	tests := []struct {
+		name   string
+		policy server.SyncPolicy
+		want   []string
+	}{
+		{name: "default is strict", want: []string{"edited.jar", "optional/x.jar", "ours.jar", "player.jar"}},
+		{name: "strict", policy: server.SyncPolicy{Mode: server.SyncStrict}, want: []string{"edited.jar", "optional/x.jar", "ours.jar", "player.jar"}},
+		{name: "additive removes only unchanged own files", policy: server.SyncPolicy{Mode: server.SyncAdditive}, want: []string{"ours.jar"}},
+		{name: "allowlist keeps allowed player files", policy: server.SyncPolicy{Mode: server.SyncAllowlist, Allow: []string{"optional/**"}}, want: []string{"ours.jar", "player.jar"}},
+		{name: "allow does not protect own files", policy: server.SyncPolicy{Mode: server.SyncAllowlist, Allow: []string{"*.jar"}}, want: []string{"optional/x.jar", "ours.jar"}},
+		{name: "unknown mode is additive", policy: server.SyncPolicy{Mode: "mirror"}, want: []string{"ours.jar"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, fullPath := range groupExtras(context.Background(), tt.policy, expected, local, owned) {
+				rel, err := filepath.Rel(dir, fullPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, fileKey(rel))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("groupExtras() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"shinecore/internal/build"
+	"shinecore/internal/launcher/download"
+	"shinecore/internal/launcher/server"
+	"shinecore/internal/selfupdate"
+)
+
+var errUpdatesDisabled = errors.New("launcher self-update is not configured in this build")
+
+// updateServer — клиент встроенного сервера обновлений. Профили из
+// server.json для самообновления не используются: добавленный игроком
+// сервер не должен раздавать сборки лаунчера.
+func updateServer(client *http.Client) *server.Client {
+	return &server.Client{BaseURL: build.UpdateURL, Client: client}
+}
+
+// LauncherUpdate — сборка лаунчера новее работающей; nil — обновлений нет.
+// Версии, которые уже не смогли запуститься, не предлагаются.
+func (l *Launcher) LauncherUpdate(ctx context.Context, updater *selfupdate.Updater) (*server.LauncherRelease, error) {
+	if !build.UpdatesEnabled() {
+		return nil, nil
+	}
+	srv := updateServer(newAPIClient())
+	release, err := srv.FetchLauncherRelease(ctx, build.OS(), build.Arch(), build.Release)
+	if err != nil || release == nil {
+		return nil, err
+	}
+	if !selfupdate.Newer(release.Version, build.Version) {
+		return nil, nil
+	}
+	if updater.IsBad(release.Version) {
+		slog.Info("launcher: skipping launcher update that failed to start before", "version", release.Version)
+		return nil, nil
+	}
+	return release, nil
+}
+
+// StageLauncherUpdate скачивает сборку лаунчера рядом с текущей, проверяет
+// хеш и подпись и готовит её к замене при перезапуске.
+func (l *Launcher) StageLauncherUpdate(ctx context.Context, updater *selfupdate.Updater, release *server.LauncherRelease, onProgress func(ProgressEvent)) error {
+	if !build.UpdatesEnabled() {
+		return errUpdatesDisabled
+	}
+	client := newHTTPClient()
+	tracker := newProgressTracker(onProgress)
+	sched := newScheduler(client, tracker)
+	srv := updateServer(client)
+
+	dst := updater.StagedPath()
+	// Остаток прошлой попытки может быть другой сборкой.
+	_ = os.Remove(dst)
+	tracker.SetTotal("launcher", 1)
+	group := sched.NewGroup(ctx, func(download.Job) { tracker.Increment("launcher") })
+	group.Add(download.Job{
+		URL: srv.ResolveURL(release.URL),
+		Request: func(ctx context.Context) (*http.Request, error) {
+			return buildDownloadRequest(ctx, srv, release.URL)
+		},
+		Dst:      dst,
+		Size:     release.Size,
+		Checksum: download.SHA256(release.Sha256),
+		Priority: download.PriorityHigh,
+	})
+	if err := group.Wait(); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		return err
+	}
+	if err := server.VerifyLauncherSignature(data, release.Signature, build.UpdatePublicKey); err != nil {
+		_ = os.Remove(dst)
+		slog.Error("launcher: launcher update rejected by signature check", "version", release.Version, "error", err)
+		return err
+	}
+	return updater.Stage(release.Version)
+}
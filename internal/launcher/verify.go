@@ -0,0 +1,537 @@
+package launcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shinecore/internal/launcher/config"
+	"shinecore/internal/launcher/download"
+	"shinecore/internal/launcher/java"
+	"shinecore/internal/launcher/launch"
+	"shinecore/internal/launcher/mojang"
+	"shinecore/internal/launcher/server"
+)
+
+// Виды проверяемых файлов.
+const (
+	FileVersion = "version"
+	FileClient  = "client"
+	FileLibrary = "library"
+	FileNative  = "native"
+	FileAsset   = "asset"
+	FileMod     = "mod"
+	FilePackage = "package" // файлы остальных групп манифеста
+	FileJava    = "java"
+)
+
+// FileIssue — проблема с одним файлом установки.
+type FileIssue struct {
+	Kind string `json:"kind"`
+	// Group — группа манифеста для модов и прочих файлов сборки.
+	Group string `json:"group,omitempty"`
+	Path  string `json:"path"`
+	// Detail поясняет, что не так (например, "sha1 mismatch").
+	Detail string `json:"detail,omitempty"`
+
+	fix *download.Job // как перекачать файл; nil — только переустановкой
+}
+
+type VerifyReport struct {
+	Instance  string      `json:"instance"`
+	VersionID string      `json:"version_id"`
+	Checked   int         `json:"checked"`
+	Missing   []FileIssue `json:"missing"`
+	Corrupt   []FileIssue `json:"corrupt"`
+	Extra     []FileIssue `json:"extra"`
+	// Skipped — что не удалось проверить (например, моды без манифеста).
+	Skipped  []string `json:"skipped,omitempty"`
+	Repaired int      `json:"repaired,omitempty"`
+
+	// Проверенный рантайм Java Mojang: после перекачки его файлов нужно
+	// вернуть права и ссылки.
+	javaDir      string
+	javaManifest *java.RuntimeManifest
+}
+
+// OK — установка полностью совпадает с ожидаемой.
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Corrupt) == 0 && len(r.Extra) == 0
+}
+
+// Verify проверяет установку экземпляра по хешам: цепочку версий, клиентский
+// jar, библиотеки, нативные библиотеки, ассеты, моды и Java. Ничего не меняет.
+func (l *Launcher) Verify(ctx context.Context, instanceID string, onProgress func(ProgressEvent)) (*VerifyReport, error) {
+	cfg, inst, err := l.loadInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	ctx, closeIndex := l.withIndex(ctx, cfg)
+	defer closeIndex()
+	probes, closeProbes := l.openJavaProbes(cfg)
+	defer closeProbes()
+	serverCfg, err := loadServerProfile()
+	if err != nil {
+		return nil, err
+	}
+	client := newHTTPClient()
+	srv := newServerClient(serverCfg, inst, client)
+	return verifyInstance(ctx, cfg, inst, client, srv, probes, newProgressTracker(onProgress))
+}
+
+// Repair проверяет установку и перекачивает только повреждённые и
+// отсутствующие файлы; лишние моды удаляются. Если сломано то, что нельзя
+// скачать по отдельности (JSON версий, сгенерированные Forge библиотеки),
+// выполняется полная установка. Возвращает отчёт повторной проверки.
+func (l *Launcher) Repair(ctx context.Context, instanceID string, onProgress func(ProgressEvent)) (*VerifyReport, error) {
+	cfg, inst, err := l.loadInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	ctx, closeIndex := l.withIndex(ctx, cfg)
+	defer closeIndex()
+	probes, closeProbes := l.openJavaProbes(cfg)
+	defer closeProbes()
+	serverCfg, err := loadServerProfile()
+	if err != nil {
+		return nil, err
+	}
+	client := newHTTPClient()
+	tracker := newProgressTracker(onProgress)
+	srv := newServerClient(serverCfg, inst, client)
+	report, err := verifyInstance(ctx, cfg, inst, client, srv, probes, tracker)
+	if err != nil {
+		return nil, err
+	}
+	if report.OK() {
+		return report, nil
+	}
+	slog.Info("launcher: repair start", "instance", inst.ID,
+		"missing", len(report.Missing), "corrupt", len(report.Corrupt), "extra", len(report.Extra))
+
+	needInstall := false
+	var jobs, javaJobs []download.Job
+	for _, issue := range append(append([]FileIssue{}, report.Missing...), report.Corrupt...) {
+		if issue.fix == nil {
+			needInstall = true
+			continue
+		}
+		// Повреждённый файл удаляем, иначе докачка продолжила бы его.
+		_ = os.Remove(issue.Path)
+		jobs = append(jobs, *issue.fix)
+		if issue.Kind == FileJava {
+			javaJobs = append(javaJobs, *issue.fix)
+		}
+	}
+	repaired := 0
+	for _, issue := range report.Extra {
+		if err := os.Remove(issue.Path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		repaired++
+	}
+
+	if needInstall {
+		if _, err := l.Install(ctx, inst.ID, onProgress); err != nil {
+			return nil, err
+		}
+		// Установленный рантайм Java установка не трогает: его файлы
+		// перекачиваем сами.
+		jobs = javaJobs
+	}
+	if len(jobs) > 0 {
+		cache, err := openStore(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := cache.Close(); err != nil {
+				slog.Warn("launcher: save store refs failed", "error", err)
+			}
+		}()
+		sched := newScheduler(client, tracker)
+		tracker.SetTotal("repair", len(jobs))
+		group := sched.NewGroup(ctx, func(download.Job) { tracker.Increment("repair") })
+		for _, job := range jobs {
+			// Файлы Java не кладём в общее хранилище, как и при установке:
+			// права на жёсткой ссылке поменялись бы и у объекта хранилища.
+			inJava := report.javaDir != "" && strings.HasPrefix(job.Dst, report.javaDir+string(filepath.Separator))
+			if job.Checksum.IsZero() || job.Checksum.Algo != download.AlgoSHA1 || inJava {
+				job.Cache = nil
+			} else {
+				job.Cache = cache
+			}
+			group.Add(job)
+		}
+		if err := group.Wait(); err != nil {
+			return nil, err
+		}
+		repaired += len(jobs)
+		if len(javaJobs) > 0 && report.javaManifest != nil {
+			if err := report.javaManifest.Finish(report.javaDir); err != nil {
+				return nil, err
+			}
+		}
+		if report.VersionID != "" {
+			if _, err := launch.PrepareNatives(cfg.InstallDir, report.VersionID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	after, err := verifyInstance(ctx, cfg, inst, client, srv, probes, tracker)
+	if err != nil {
+		return nil, err
+	}
+	after.Repaired = repaired
+	slog.Info("launcher: repair complete", "instance", inst.ID, "repaired", repaired, "ok", after.OK())
+	return after, nil
+}
+
+// verifier собирает отчёт; каждый файл проверяется один раз.
+type verifier struct {
+	ctx     context.Context
+	report  *VerifyReport
+	seen    map[string]struct{}
+	tracker *progressTracker
+}
+
+func verifyInstance(ctx context.Context, cfg *config.Config, inst *config.Instance, client *http.Client, srv *server.Client, probes *java.ProbeCache, tracker *progressTracker) (*VerifyReport, error) {
+	v := &verifier{
+		ctx:     ctx,
+		report:  &VerifyReport{Instance: inst.ID, VersionID: resolveVersionID(inst)},
+		seen:    map[string]struct{}{},
+		tracker: tracker,
+	}
+	baseDir := cfg.InstallDir
+
+	chain, err := loadVersionChain(baseDir, v.report.VersionID)
+	if err != nil {
+		var broken *versionFileError
+		if !errors.As(err, &broken) {
+			return nil, err
+		}
+		issue := FileIssue{Kind: FileVersion, Path: broken.path}
+		if broken.err == nil {
+			v.add(&v.report.Missing, issue, nil)
+		} else {
+			issue.Detail = broken.err.Error()
+			v.add(&v.report.Corrupt, issue, nil)
+		}
+	}
+	for _, meta := range chain {
+		if err := v.checkVersion(baseDir, meta); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest, err := instanceManifest(ctx, srv, inst.Dir)
+	if err != nil {
+		slog.Info("launcher: verify without manifest", "error", err)
+		v.report.Skipped = append(v.report.Skipped, "packages: manifest unavailable")
+		manifest = nil
+	} else if err := v.checkPackages(inst.Dir, srv, manifest); err != nil {
+		return nil, err
+	}
+
+	required := resolveRequiredJava(baseDir, inst, manifest)
+	runtimeInstalled := false
+	if required.Component != "" {
+		runtimeDir := javaRuntimeDir(baseDir, required.Component)
+		if version := java.InstalledRuntime(runtimeDir); version != "" {
+			runtimeInstalled = true
+			if err := v.checkJavaRuntime(client, runtimeDir, required.Component, version); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Файлы установленного рантайма уже проверены по одному: отдельная
+	// запись «Java не найдена» для него не нужна.
+	if javaPath, err := findJava(probes, baseDir, inst, required); err != nil {
+		v.report.Corrupt = append(v.report.Corrupt, FileIssue{Kind: FileJava, Path: inst.JavaPath, Detail: err.Error()})
+	} else if required.MajorVersion > 0 && javaPath == "" && !runtimeInstalled {
+		path := javaVersionDir(baseDir, required.MajorVersion)
+		if required.Component != "" {
+			path = javaRuntimeDir(baseDir, required.Component)
+		}
+		v.report.Missing = append(v.report.Missing, FileIssue{
+			Kind:   FileJava,
+			Path:   path,
+			Detail: fmt.Sprintf("java %d runtime not found", required.MajorVersion),
+		})
+	}
+	return v.report, ctx.Err()
+}
+
+func (v *verifier) checkVersion(baseDir string, meta *mojang.VersionMetadata) error {
+	if meta.InheritsFrom == "" && meta.Downloads.Client.URL != "" {
+		info := meta.Downloads.Client
+		dst := filepath.Join(baseDir, "versions", meta.ID, meta.ID+".jar")
+		if err := v.checkFile(FileClient, dst, info.Size, download.SHA1(info.Sha1), info.URL); err != nil {
+			return err
+		}
+	}
+	for _, lib := range meta.Libraries {
+		if !mojang.AllowLibrary(lib.Rules) {
+			continue
+		}
+		if lib.Downloads != nil && lib.Downloads.Artifact != nil {
+			artifact := lib.Downloads.Artifact
+			dst := filepath.Join(baseDir, "libraries", filepath.FromSlash(artifact.Path))
+			if err := v.checkFile(FileLibrary, dst, artifact.Size, download.SHA1(artifact.Sha1), artifact.URL); err != nil {
+				return err
+			}
+		} else if path := mojang.LibraryPath(lib.Name); path != "" {
+			url := "https://libraries.minecraft.net/" + path
+			if strings.TrimSpace(lib.URL) != "" {
+				url = strings.TrimRight(lib.URL, "/") + "/" + path
+			}
+			dst := filepath.Join(baseDir, "libraries", filepath.FromSlash(path))
+			if err := v.checkFile(FileLibrary, dst, lib.Size, download.SHA1(lib.Sha1), url); err != nil {
+				return err
+			}
+		}
+		for _, native := range mojang.NativeArtifacts(lib) {
+			dst := filepath.Join(baseDir, "libraries", filepath.FromSlash(native.Path))
+			if err := v.checkFile(FileNative, dst, native.Size, download.SHA1(native.Sha1), native.URL); err != nil {
+				return err
+			}
+		}
+	}
+	if meta.AssetIndex.ID == "" {
+		return nil
+	}
+	return v.checkAssets(baseDir, meta.AssetIndex)
+}
+
+func (v *verifier) checkAssets(baseDir string, index mojang.AssetIndex) error {
+	indexPath := filepath.Join(baseDir, "assets", "indexes", index.ID+".json")
+	if _, seen := v.seen[indexPath]; seen {
+		return nil
+	}
+	before := len(v.report.Missing) + len(v.report.Corrupt)
+	if err := v.checkFile(FileAsset, indexPath, index.Size, download.SHA1(index.Sha1), index.URL); err != nil {
+		return err
+	}
+	if len(v.report.Missing)+len(v.report.Corrupt) > before {
+		// Без индекса объекты не проверить; после его загрузки Repair проверит их повторно.
+		v.report.Skipped = append(v.report.Skipped, "assets: index "+index.ID+" is broken")
+		return nil
+	}
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return err
+	}
+	var file mojang.AssetIndexFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	v.tracker.SetTotal("verify:assets", len(file.Objects))
+	for _, obj := range file.Objects {
+		if len(obj.Hash) < 2 {
+			continue
+		}
+		sub := obj.Hash[:2]
+		dst := filepath.Join(baseDir, "assets", "objects", sub, obj.Hash)
+		url := "https://resources.download.minecraft.net/" + sub + "/" + obj.Hash
+		if err := v.checkFile(FileAsset, dst, obj.Size, download.SHA1(obj.Hash), url); err != nil {
+			return err
+		}
+		v.tracker.Increment("verify:assets")
+	}
+	return nil
+}
+
+func (v *verifier) checkPackages(instDir string, srv *server.Client, manifest *server.Manifest) error {
+	state := loadSyncState(instDir)
+	for _, group := range manifest.Packages.Groups {
+		targetDir, err := groupDir(instDir, group)
+		if err != nil {
+			return err
+		}
+		expected, err := groupFiles(group)
+		if err != nil {
+			return err
+		}
+		kind := FilePackage
+		if group.Name == "mods" {
+			kind = FileMod
+		}
+		overwrite := overwriteMode(group)
+		step := "verify:" + group.Name
+		v.tracker.SetTotal(step, len(expected))
+		for _, file := range expected {
+			dst := filepath.Join(targetDir, filepath.FromSlash(file.Path))
+			url := srv.ResolveURL(file.URL)
+			issue := FileIssue{Kind: kind, Group: group.Name, Path: dst}
+			fix := &download.Job{
+				URL: url,
+				Request: func(ctx context.Context) (*http.Request, error) {
+					return srv.SignedRequest(ctx, http.MethodGet, url)
+				},
+				Dst:      dst,
+				Size:     file.Size,
+				Checksum: download.SHA256(file.Sha256),
+				Priority: download.PriorityNormal,
+			}
+			size, sum := fix.Size, fix.Checksum
+			if overwrite != server.OverwriteAlways {
+				// Изменения игрока в таких файлах допустимы: проверяем только
+				// наличие. Перекачанный файл всё равно сверяется с манифестом.
+				size, sum = 0, download.Checksum{}
+			}
+			if err := v.check(issue, size, sum, fix); err != nil {
+				return err
+			}
+			v.tracker.Increment(step)
+		}
+		local, err := listLocalFiles(targetDir)
+		if err != nil {
+			return err
+		}
+		for _, fullPath := range groupExtras(v.ctx, groupPolicy(manifest, group), expected, local, state.group(group.Target)) {
+			v.report.Extra = append(v.report.Extra, FileIssue{Kind: kind, Group: group.Name, Path: fullPath})
+		}
+	}
+	return nil
+}
+
+// checkJavaRuntime сверяет файлы рантайма Java Mojang с sha1 из его
+// манифеста. Для установок, где манифест не сохранён, он загружается заново,
+// если у Mojang всё ещё та же сборка.
+func (v *verifier) checkJavaRuntime(client *http.Client, dir, component, version string) error {
+	manifest, err := java.InstalledManifest(dir)
+	if os.IsNotExist(err) {
+		manifest, err = fetchJavaManifest(v.ctx, client, component, version)
+	}
+	if err != nil {
+		slog.Info("launcher: verify java without manifest", "dir", dir, "error", err)
+		v.report.Skipped = append(v.report.Skipped, "java: "+err.Error())
+		return nil
+	}
+	v.report.javaDir, v.report.javaManifest = dir, manifest
+	v.tracker.SetTotal("verify:java", len(manifest.Files))
+	for name, file := range manifest.Files {
+		raw := file.Downloads.Raw
+		if file.Type != "file" || raw == nil {
+			continue
+		}
+		path, err := manifest.Path(dir, name)
+		if err != nil {
+			return err
+		}
+		if err := v.checkFile(FileJava, path, raw.Size, download.SHA1(raw.Sha1), raw.URL); err != nil {
+			return err
+		}
+		v.tracker.Increment("verify:java")
+	}
+	return nil
+}
+
+// fetchJavaManifest — манифест сборки version компонента у Mojang.
+func fetchJavaManifest(ctx context.Context, client *http.Client, component, version string) (*java.RuntimeManifest, error) {
+	index, err := java.FetchRuntimeIndex(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := index.Lookup(java.Platform(), component)
+	if !ok {
+		return nil, errors.New("runtime " + component + " is not available for " + java.Platform())
+	}
+	if entry.Version.Name != version {
+		return nil, fmt.Errorf("installed runtime %s is %s, mojang now serves %s", component, version, entry.Version.Name)
+	}
+	manifest, _, err := java.FetchRuntimeManifest(ctx, client, entry)
+	return manifest, err
+}
+
+func (v *verifier) checkFile(kind, dst string, size int64, sum download.Checksum, url string) error {
+	var fix *download.Job
+	if strings.TrimSpace(url) != "" {
+		fix = &download.Job{URL: url, Dst: dst, Size: size, Checksum: sum, Priority: download.PriorityHigh}
+	}
+	return v.check(FileIssue{Kind: kind, Path: dst}, size, sum, fix)
+}
+
+// check сверяет файл с size и sum; fix — как его перекачать (со своим
+// хешем, даже если сам файл проверяется только на наличие).
+func (v *verifier) check(issue FileIssue, size int64, sum download.Checksum, fix *download.Job) error {
+	if err := v.ctx.Err(); err != nil {
+		return err
+	}
+	if _, seen := v.seen[issue.Path]; seen {
+		return nil
+	}
+	v.seen[issue.Path] = struct{}{}
+	v.report.Checked++
+	if _, err := os.Stat(issue.Path); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		v.add(&v.report.Missing, issue, fix)
+		return nil
+	}
+	ok, err := download.VerifyFile(v.ctx, issue.Path, size, sum)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		issue.Detail = "size mismatch"
+		if !sum.IsZero() {
+			issue.Detail = "size or " + sum.Algo + " mismatch"
+		}
+		v.add(&v.report.Corrupt, issue, fix)
+	}
+	return nil
+}
+
+func (v *verifier) add(list *[]FileIssue, issue FileIssue, fix *download.Job) {
+	issue.fix = fix
+	*list = append(*list, issue)
+}
+
+// versionFileError — JSON версии отсутствует (err == nil) или не читается.
+type versionFileError struct {
+	path string
+	err  error
+}
+
+func (e *versionFileError) Error() string {
+	if e.err == nil {
+		return "version metadata missing: " + e.path
+	}
+	return e.path + ": " + e.err.Error()
+}
+
+// loadVersionChain читает JSON версии и всех её родителей (inheritsFrom).
+func loadVersionChain(baseDir, versionID string) ([]*mojang.VersionMetadata, error) {
+	var chain []*mojang.VersionMetadata
+	visited := map[string]struct{}{}
+	for id := versionID; id != ""; {
+		if _, ok := visited[id]; ok {
+			return chain, errors.New("version inheritance loop: " + id)
+		}
+		visited[id] = struct{}{}
+		path := filepath.Join(baseDir, "versions", id, id+".json")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return chain, &versionFileError{path: path}
+			}
+			return chain, err
+		}
+		var meta mojang.VersionMetadata
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return chain, &versionFileError{path: path, err: err}
+		}
+		chain = append(chain, &meta)
+		id = meta.InheritsFrom
+	}
+	return chain, nil
+}
@@ -0,0 +1,75 @@
+package launcher
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"shinecore/internal/launcher/server"
+)
+
+func TestVerifierCheckPackages(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"mods/good.jar":    "good",
+		"mods/bad.jar":     "truncated",
+		"mods/extra.jar":   "player",
+		"config/a.cfg":     "edited by player",
+		"config/extra.cfg": "player",
+	})
+	mod := func(name, data string) server.FilePackage {
+		return server.FilePackage{Path: name, Size: int64(len(data)), Sha256: sha256Hex(data), URL: "mods/" + name}
+	}
+	cfg := func(name, data string) server.FilePackage {
+		return server.FilePackage{Path: name, Size: int64(len(data)), Sha256: sha256Hex(data), URL: "config/" + name}
+	}
+	manifest := &server.Manifest{Packages: server.ManifestPackages{Groups: []server.PackageGroup{
+		{Name: "mods", Target: "mods", Files: []server.FilePackage{mod("good.jar", "good"), mod("bad.jar", "bad"), mod("absent.jar", "absent")}},
+		// Правило missing: изменённый игроком файл не считается повреждённым.
+		{Name: "config", Target: "config", Overwrite: server.OverwriteMissing, Files: []server.FilePackage{cfg("a.cfg", "a"), cfg("b.cfg", "b")}},
+	}}}
+
+	v := &verifier{
+		ctx:     context.Background(),
+		report:  &VerifyReport{},
+		seen:    map[string]struct{}{},
+		tracker: newProgressTracker(nil),
+	}
+	srv := &server.Client{BaseURL: "https://example.com"}
+	if err := v.checkPackages(dir, srv, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := func(issues []FileIssue) []string {
+		var out []string
+		for _, issue := range issues {
+			rel, err := filepath.Rel(dir, issue.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, filepath.ToSlash(rel))
+		}
+		slices.Sort(out)
+		return out
+	}
+	report := v.report
+	if report.Checked != 5 {
+		t.Errorf("Checked = %d, want 5", report.Checked)
+	}
+	if got, want := paths(report.Missing), []string{"config/b.cfg", "mods/absent.jar"}; !slices.Equal(got, want) {
+		t.Errorf("Missing = %q, want %q", got, want)
+	}
+	if got, want := paths(report.Corrupt), []string{"mods/bad.jar"}; !slices.Equal(got, want) {
+		t.Errorf("Corrupt = %q, want %q", got, want)
+	}
+	// mods по умолчанию strict, остальные группы — additive.
+	if got, want := paths(report.Extra), []string{"mods/extra.jar"}; !slices.Equal(got, want) {
+		t.Errorf("Extra = %q, want %q", got, want)
+	}
+	for _, issue := range append(report.Missing, report.Corrupt...) {
+		if issue.fix == nil || issue.fix.Checksum.IsZero() || issue.fix.URL == "" {
+			t.Errorf("%s: repair job = %+v, want a verified download", issue.Path, issue.fix)
+		}
+	}
+}
@@ -11,8 +11,17 @@ import (
 var (
 	initOnce  sync.Once
 	logWriter io.Writer
+	console   io.Writer = os.Stdout
 )
 
+// SetConsole replaces the console half of the log output. Must be called before Init.
+func SetConsole(w io.Writer) {
+	if w == nil {
+		w = io.Discard
+	}
+	console = w
+}
+
 // Init configures logging to both console and file.
 func Init() {
 	writer := Writer()
@@ -22,7 +31,7 @@ func Init() {
 
 func Writer() io.Writer {
 	initOnce.Do(func() {
-		logWriter = io.MultiWriter(os.Stdout, openLogFile())
+		logWriter = io.MultiWriter(console, openLogFile())
 	})
 	if logWriter == nil {
 		return os.Stdout
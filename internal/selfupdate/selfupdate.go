@@ -0,0 +1,365 @@
+// Package selfupdate заменяет бинарник лаунчера новой сборкой.
+//
+// Новая сборка скачивается рядом с текущей (<exe>.new) и проверяется. При
+// перезапуске текущий бинарник переименовывается в <exe>.old, новый встаёт
+// на его место и запускается. Старый процесс ждёт, пока новый подтвердит
+// запуск (Confirm после загрузки интерфейса); если новый падает или молчит,
+// старый бинарник возвращается на место, а версия помечается сломанной.
+package selfupdate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	stateFileName = "update.json"
+	// trialEnv помечает процесс, который запущен наблюдателем после замены.
+	trialEnv = "SHINECORE_UPDATE_TRIAL"
+	// confirmTimeout — сколько наблюдатель ждёт подтверждения запуска.
+	confirmTimeout = 45 * time.Second
+	// maxTrialStarts — запусков без подтверждения, после которых новая сборка
+	// откатывается и без наблюдателя (например, если его процесс завершили).
+	maxTrialStarts = 2
+)
+
+// Состояния обновления.
+const (
+	StatusStaged     = "staged"
+	StatusTrial      = "trial"
+	StatusConfirmed  = "confirmed"
+	StatusRolledBack = "rolled_back"
+)
+
+var (
+	ErrNotStaged = errors.New("no launcher update is staged")
+	// ErrStartFailed — новая сборка не подтвердила запуск и откачена.
+	ErrStartFailed = errors.New("updated launcher failed to start, previous version restored")
+)
+
+// State — update.json в каталоге настроек лаунчера.
+type State struct {
+	Status   string `json:"status"`
+	Version  string `json:"version"`
+	Previous string `json:"previous"`
+	// Attempts — запуски новой сборки без подтверждения.
+	Attempts  int    `json:"attempts,omitempty"`
+	Error     string `json:"error,omitempty"`
+	UpdatedAt string `json:"updated_at"`
+	// Bad — версии, которые не смогли запуститься; повторно они не ставятся.
+	Bad []string `json:"bad,omitempty"`
+}
+
+// Updater — бинарник лаунчера и файл состояния обновления.
+type Updater struct {
+	Exe       string
+	StatePath string
+	// Version — версия работающего бинарника.
+	Version string
+}
+
+// New — обновление для работающего бинарника версии version.
+func New(version string) (*Updater, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Updater{Exe: exe, StatePath: filepath.Join(dir, "shinecore", stateFileName), Version: version}, nil
+}
+
+// StagedPath — куда скачивается новая сборка.
+func (u *Updater) StagedPath() string {
+	return u.Exe + ".new"
+}
+
+func (u *Updater) oldPath() string {
+	return u.Exe + ".old"
+}
+
+// Load читает состояние; отсутствующий или повреждённый файл — пустое.
+func (u *Updater) Load() *State {
+	state := &State{}
+	data, err := os.ReadFile(u.StatePath)
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		slog.Warn("selfupdate: state is corrupt, starting over", "error", err)
+		return &State{}
+	}
+	return state
+}
+
+func (u *Updater) save(state *State) error {
+	state.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(u.StatePath), 0o755); err != nil {
+		return err
+	}
+	tmp := u.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	_ = os.Remove(u.StatePath)
+	return os.Rename(tmp, u.StatePath)
+}
+
+// IsBad — версия уже не смогла запуститься на этой машине.
+func (u *Updater) IsBad(version string) bool {
+	return slices.Contains(u.Load().Bad, version)
+}
+
+// Stage отмечает скачанную и проверенную сборку в StagedPath как готовую
+// к установке при перезапуске.
+func (u *Updater) Stage(version string) error {
+	if _, err := os.Stat(u.StagedPath()); err != nil {
+		return ErrNotStaged
+	}
+	// Скачанный файл не исполняемый.
+	if err := os.Chmod(u.StagedPath(), 0o755); err != nil {
+		return err
+	}
+	state := u.Load()
+	state.Status = StatusStaged
+	state.Version = version
+	state.Previous = u.Version
+	state.Attempts = 0
+	state.Error = ""
+	slog.Info("selfupdate: update staged", "version", version, "path", u.StagedPath())
+	return u.save(state)
+}
+
+// Restart ставит подготовленную сборку на место текущей, запускает её
+// с аргументами args и ждёт подтверждения запуска. nil — новая сборка
+// работает, текущий процесс должен завершиться. ErrStartFailed — старая
+// сборка восстановлена, текущий процесс продолжает работу.
+func (u *Updater) Restart(args []string) error {
+	state := u.Load()
+	if state.Status != StatusStaged {
+		return ErrNotStaged
+	}
+	if _, err := os.Stat(u.StagedPath()); err != nil {
+		return ErrNotStaged
+	}
+	if err := u.swapIn(); err != nil {
+		return err
+	}
+	state.Status = StatusTrial
+	state.Attempts = 0
+	if err := u.save(state); err != nil {
+		u.restore()
+		return err
+	}
+
+	cmd := exec.Command(u.Exe, args...)
+	cmd.Env = append(os.Environ(), trialEnv+"=1")
+	if err := cmd.Start(); err != nil {
+		u.fail(state, fmt.Errorf("start: %w", err))
+		return ErrStartFailed
+	}
+	slog.Info("selfupdate: new launcher started, waiting for confirmation", "version", state.Version, "pid", cmd.Process.Pid)
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(confirmTimeout)
+	for {
+		select {
+		case err := <-exited:
+			// Процесс мог подтвердить запуск и штатно закрыться сразу после.
+			if u.Load().Status == StatusConfirmed {
+				return nil
+			}
+			if err == nil {
+				err = errors.New("exited before confirming start")
+			}
+			u.fail(state, err)
+			return ErrStartFailed
+		case <-deadline:
+			_ = cmd.Process.Kill()
+			<-exited
+			u.fail(state, fmt.Errorf("no start confirmation in %s", confirmTimeout))
+			return ErrStartFailed
+		case <-ticker.C:
+			if u.Load().Status == StatusConfirmed {
+				slog.Info("selfupdate: new launcher confirmed start", "version", state.Version)
+				return nil
+			}
+		}
+	}
+}
+
+// swapIn: <exe> -> <exe>.old, <exe>.new -> <exe>. Работающий бинарник
+// можно переименовать и в Windows.
+func (u *Updater) swapIn() error {
+	_ = os.Remove(u.oldPath())
+	if err := os.Rename(u.Exe, u.oldPath()); err != nil {
+		return fmt.Errorf("move current launcher aside: %w", err)
+	}
+	if err := os.Rename(u.StagedPath(), u.Exe); err != nil {
+		_ = os.Rename(u.oldPath(), u.Exe)
+		return fmt.Errorf("install new launcher: %w", err)
+	}
+	return nil
+}
+
+// restore возвращает <exe>.old на место; новая сборка удаляется.
+func (u *Updater) restore() {
+	if _, err := os.Stat(u.oldPath()); err != nil {
+		slog.Error("selfupdate: previous launcher is missing, cannot roll back", "error", err)
+		return
+	}
+	bad := u.Exe + ".bad"
+	_ = os.Remove(bad)
+	if err := os.Rename(u.Exe, bad); err != nil {
+		slog.Error("selfupdate: move failed launcher aside failed", "error", err)
+		return
+	}
+	if err := os.Rename(u.oldPath(), u.Exe); err != nil {
+		slog.Error("selfupdate: restore previous launcher failed", "error", err)
+		_ = os.Rename(bad, u.Exe)
+		return
+	}
+	// Работающий бинарник в Windows удалить нельзя: .bad удалит Startup.
+	_ = os.Remove(bad)
+}
+
+// fail откатывает новую сборку и помечает её версию сломанной.
+func (u *Updater) fail(state *State, cause error) {
+	slog.Error("selfupdate: new launcher failed to start, rolling back", "version", state.Version, "error", cause)
+	u.restore()
+	state.Status = StatusRolledBack
+	state.Error = cause.Error()
+	if !slices.Contains(state.Bad, state.Version) {
+		state.Bad = append(state.Bad, state.Version)
+	}
+	if err := u.save(state); err != nil {
+		slog.Warn("selfupdate: save state failed", "error", err)
+	}
+}
+
+// Startup вызывается до запуска интерфейса и убирает файлы прошлого
+// обновления. Новая сборка, которая запускается без наблюдателя и так и не
+// подтвердила запуск, на maxTrialStarts-й раз откатывается. true — запущена
+// прежняя сборка, текущий процесс должен завершиться.
+func (u *Updater) Startup(args []string) bool {
+	state := u.Load()
+	switch state.Status {
+	case StatusConfirmed, StatusRolledBack:
+		_ = os.Remove(u.oldPath())
+		_ = os.Remove(u.Exe + ".bad")
+		return false
+	case StatusTrial:
+	default:
+		return false
+	}
+	if state.Version != u.Version || os.Getenv(trialEnv) != "" {
+		return false
+	}
+	state.Attempts++
+	if state.Attempts < maxTrialStarts {
+		if err := u.save(state); err != nil {
+			slog.Warn("selfupdate: save state failed", "error", err)
+		}
+		return false
+	}
+	u.fail(state, errors.New("not confirmed after "+strconv.Itoa(state.Attempts)+" starts"))
+	cmd := exec.Command(u.Exe, args...)
+	if err := cmd.Start(); err != nil {
+		slog.Error("selfupdate: start previous launcher failed", "error", err)
+		return false
+	}
+	return true
+}
+
+// Confirm отмечает, что новая сборка запустилась; вызывается, когда
+// интерфейс загружен.
+func (u *Updater) Confirm() {
+	state := u.Load()
+	if state.Status != StatusTrial || state.Version != u.Version {
+		return
+	}
+	state.Status = StatusConfirmed
+	state.Attempts = 0
+	if err := u.save(state); err != nil {
+		slog.Warn("selfupdate: save state failed", "error", err)
+		return
+	}
+	slog.Info("selfupdate: launcher update confirmed", "version", u.Version, "previous", state.Previous)
+}
+
+// Newer — версия a новее b: числовые компоненты через точку, сборка без
+// суффикса (-beta.1) новее сборки с суффиксом.
+func Newer(a, b string) bool {
+	return compareVersions(strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")) > 0
+}
+
+func compareVersions(a, b string) int {
+	coreA, preA, _ := strings.Cut(a, "-")
+	coreB, preB, _ := strings.Cut(b, "-")
+	partsA := strings.Split(coreA, ".")
+	partsB := strings.Split(coreB, ".")
+	for i := 0; i < max(len(partsA), len(partsB)); i++ {
+		if c := compareParts(partAt(partsA, i), partAt(partsB, i)); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	partsA = strings.Split(preA, ".")
+	partsB = strings.Split(preB, ".")
+	for i := 0; i < max(len(partsA), len(partsB)); i++ {
+		if c := compareParts(partAt(partsA, i), partAt(partsB, i)); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func partAt(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return "0"
+}
+
+func compareParts(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+
+package system
+
+import "os/exec"
+
+func HideWindow(cmd *exec.Cmd) {}
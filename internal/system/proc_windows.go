@@ -0,0 +1,16 @@
+//go:build windows
+
+package system
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// HideWindow запускает процесс без консольного окна.
+func HideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"shinecore/internal/app"
 	"shinecore/internal/build"
 	"shinecore/internal/logging"
+	"shinecore/internal/selfupdate"
 )
 
 //go:embed frontend/dist
@@ -33,6 +34,11 @@ func main() {
 		"arch", build.Arch(),
 	)
 
+	// Finish or roll back a pending launcher update before the window opens
+	if updater, err := selfupdate.New(build.Version); err == nil && updater.Startup(os.Args[1:]) {
+		return
+	}
+
 	// Create the application instance
 	application := app.New()
 